@@ -0,0 +1,529 @@
+package googlebigquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+)
+
+const (
+	TableTypeTable            string = "TABLE"
+	TableTypeView             string = "VIEW"
+	TableTypeMaterializedView string = "MATERIALIZED_VIEW"
+	TableTypeExternal         string = "EXTERNAL"
+)
+
+// TableDDL returns the CREATE TABLE, CREATE VIEW, CREATE MATERIALIZED VIEW or CREATE EXTERNAL TABLE statement
+// that defines table, as returned by GetTable. The statement includes the columns with their descriptions,
+// partitioning, clustering and the options description, friendly_name, labels, expiration_timestamp,
+// partition_expiration_days, require_partition_filter and kms_key_name. Labels are sorted, so that the statement
+// only changes if the definition changes.
+//
+// Policy tags cannot be set in DDL and are left out, as are the column descriptions of a materialized view.
+// Legacy SQL views have no DDL equivalent.
+func TableDDL(table *Table) (string, *errortools.Error) {
+	if table == nil {
+		return "", errortools.ErrorMessage("Table must not be a nil pointer")
+	}
+
+	tableType := table.Type
+	if tableType == "" {
+		switch {
+		case table.View != nil:
+			tableType = TableTypeView
+		case table.MaterializedView != nil:
+			tableType = TableTypeMaterializedView
+		case table.ExternalDataConfiguration != nil:
+			tableType = TableTypeExternal
+		default:
+			tableType = TableTypeTable
+		}
+	}
+
+	var ddl *strings.Builder
+	var e *errortools.Error
+
+	switch tableType {
+	case TableTypeTable:
+		ddl, e = createTableDDL(table)
+	case TableTypeView:
+		ddl, e = createViewDDL(table)
+	case TableTypeMaterializedView:
+		ddl, e = createMaterializedViewDDL(table)
+	case TableTypeExternal:
+		ddl, e = createExternalTableDDL(table)
+	default:
+		return "", errortools.ErrorMessagef("table %s has unsupported type %s", table.TableReference.TableID, tableType)
+	}
+	if e != nil {
+		return "", errortools.ErrorMessagef("table %s: %s", table.TableReference.TableID, e.Message())
+	}
+
+	ddl.WriteString(";\n")
+
+	return ddl.String(), nil
+}
+
+func createTableDDL(table *Table) (*strings.Builder, *errortools.Error) {
+	var ddl strings.Builder
+	ddl.WriteString(fmt.Sprintf("CREATE TABLE %s", quoteTableReference(table.TableReference)))
+
+	if table.Schema != nil && len(table.Schema.Fields) > 0 {
+		columns, e := ddlColumns(table.Schema.Fields)
+		if e != nil {
+			return nil, e
+		}
+		ddl.WriteString(columns)
+	}
+
+	e := writePartitioningDDL(&ddl, table)
+	if e != nil {
+		return nil, e
+	}
+
+	options := ddlTableOptions(table)
+	if table.TimePartitioning != nil && table.TimePartitioning.ExpirationMS != nil {
+		days := float64(*table.TimePartitioning.ExpirationMS) / float64(24*time.Hour/time.Millisecond)
+		options = append(options, ddlOption{"partition_expiration_days", strconv.FormatFloat(days, 'f', -1, 64)})
+	}
+	if table.RequirePartitionFilter != nil && *table.RequirePartitionFilter {
+		options = append(options, ddlOption{"require_partition_filter", "true"})
+	}
+	if table.EncryptionConfiguration != nil && table.EncryptionConfiguration.KMSKeyName != "" {
+		options = append(options, ddlOption{"kms_key_name", ddlString(table.EncryptionConfiguration.KMSKeyName)})
+	}
+	writeOptionsDDL(&ddl, options)
+
+	return &ddl, nil
+}
+
+func createViewDDL(table *Table) (*strings.Builder, *errortools.Error) {
+	if table.View == nil {
+		return nil, errortools.ErrorMessage("view has no definition")
+	}
+	if table.View.UseLegacySQL != nil && *table.View.UseLegacySQL {
+		return nil, errortools.ErrorMessage("legacy SQL views cannot be defined in DDL")
+	}
+	if table.View.UserDefinedFunctionResources != nil && len(*table.View.UserDefinedFunctionResources) > 0 {
+		return nil, errortools.ErrorMessage("user-defined function resources cannot be defined in DDL")
+	}
+
+	var ddl strings.Builder
+	ddl.WriteString(fmt.Sprintf("CREATE VIEW %s", quoteTableReference(table.TableReference)))
+
+	// only the descriptions of the columns can be set, their names and types follow from the query
+	if table.Schema != nil && ddlHasDescriptions(table.Schema.Fields) {
+		columns := []string{}
+		for _, field := range table.Schema.Fields {
+			column := quoteIdentifier(field.Name)
+			if field.Description != "" {
+				column += fmt.Sprintf(" OPTIONS(description=%s)", ddlString(field.Description))
+			}
+			columns = append(columns, column)
+		}
+		ddl.WriteString(fmt.Sprintf("\n(\n  %s\n)", strings.Join(columns, ",\n  ")))
+	}
+
+	writeOptionsDDL(&ddl, ddlTableOptions(table))
+
+	ddl.WriteString("\nAS ")
+	ddl.WriteString(strings.TrimRight(strings.TrimSpace(table.View.Query), ";"))
+	// the terminator goes on a line of its own, in case the query ends in a line comment
+	ddl.WriteString("\n")
+
+	return &ddl, nil
+}
+
+func createMaterializedViewDDL(table *Table) (*strings.Builder, *errortools.Error) {
+	if table.MaterializedView == nil {
+		return nil, errortools.ErrorMessage("materialized view has no definition")
+	}
+
+	var ddl strings.Builder
+	ddl.WriteString(fmt.Sprintf("CREATE MATERIALIZED VIEW %s", quoteTableReference(table.TableReference)))
+
+	e := writePartitioningDDL(&ddl, table)
+	if e != nil {
+		return nil, e
+	}
+
+	options := ddlTableOptions(table)
+	if table.MaterializedView.EnableRefresh != nil {
+		options = append(options, ddlOption{"enable_refresh", strconv.FormatBool(*table.MaterializedView.EnableRefresh)})
+	}
+	if table.MaterializedView.RefreshIntervalMS != nil {
+		minutes := float64(*table.MaterializedView.RefreshIntervalMS) / float64(time.Minute/time.Millisecond)
+		options = append(options, ddlOption{"refresh_interval_minutes", strconv.FormatFloat(minutes, 'f', -1, 64)})
+	}
+	if table.EncryptionConfiguration != nil && table.EncryptionConfiguration.KMSKeyName != "" {
+		options = append(options, ddlOption{"kms_key_name", ddlString(table.EncryptionConfiguration.KMSKeyName)})
+	}
+	writeOptionsDDL(&ddl, options)
+
+	ddl.WriteString("\nAS ")
+	ddl.WriteString(strings.TrimRight(strings.TrimSpace(table.MaterializedView.Query), ";"))
+	// the terminator goes on a line of its own, in case the query ends in a line comment
+	ddl.WriteString("\n")
+
+	return &ddl, nil
+}
+
+func createExternalTableDDL(table *Table) (*strings.Builder, *errortools.Error) {
+	config := table.ExternalDataConfiguration
+	if config == nil {
+		return nil, errortools.ErrorMessage("external table has no external data configuration")
+	}
+
+	var ddl strings.Builder
+	ddl.WriteString(fmt.Sprintf("CREATE EXTERNAL TABLE %s", quoteTableReference(table.TableReference)))
+
+	// hive partitioning columns are not part of the column list
+	partitionColumns := map[string]bool{}
+	if config.HivePartitioningOptions != nil && config.HivePartitioningOptions.Fields != nil {
+		for _, field := range *config.HivePartitioningOptions.Fields {
+			partitionColumns[strings.ToLower(field)] = true
+		}
+	}
+
+	fields := []TableFieldSchema{}
+	partitionFields := []TableFieldSchema{}
+	if table.Schema != nil {
+		for _, field := range table.Schema.Fields {
+			if partitionColumns[strings.ToLower(field.Name)] {
+				partitionFields = append(partitionFields, field)
+			} else {
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	if len(fields) > 0 {
+		columns, e := ddlColumns(fields)
+		if e != nil {
+			return nil, e
+		}
+		ddl.WriteString(columns)
+	}
+
+	// WITH CONNECTION precedes WITH PARTITION COLUMNS in the CREATE EXTERNAL TABLE syntax
+	if config.ConnectionId != nil && *config.ConnectionId != "" {
+		ddl.WriteString(fmt.Sprintf("\nWITH CONNECTION %s", quoteIdentifier(ddlConnectionId(*config.ConnectionId))))
+	}
+
+	if config.HivePartitioningOptions != nil {
+		ddl.WriteString("\nWITH PARTITION COLUMNS")
+		if len(partitionFields) > 0 {
+			columns, e := ddlColumns(partitionFields)
+			if e != nil {
+				return nil, e
+			}
+			ddl.WriteString(columns)
+		}
+	}
+
+	options := ddlTableOptions(table)
+
+	if config.SourceFormat != nil {
+		options = append(options, ddlOption{"format", ddlString(*config.SourceFormat)})
+	}
+	if len(config.SourceURIs) > 0 {
+		options = append(options, ddlOption{"uris", ddlStringArray(config.SourceURIs)})
+	}
+	if config.Compression != nil {
+		options = append(options, ddlOption{"compression", ddlString(*config.Compression)})
+	}
+	if config.MaxBadRecords != nil {
+		options = append(options, ddlOption{"max_bad_records", strconv.FormatInt(*config.MaxBadRecords, 10)})
+	}
+	if config.IgnoreUnknownValues != nil {
+		options = append(options, ddlOption{"ignore_unknown_values", strconv.FormatBool(*config.IgnoreUnknownValues)})
+	}
+	if config.DecimalTargetTypes != nil && len(*config.DecimalTargetTypes) > 0 {
+		options = append(options, ddlOption{"decimal_target_types", ddlStringArray(*config.DecimalTargetTypes)})
+	}
+	if config.CSVOptions != nil {
+		csvOptions := config.CSVOptions
+		if csvOptions.FieldDelimiter != nil {
+			options = append(options, ddlOption{"field_delimiter", ddlString(*csvOptions.FieldDelimiter)})
+		}
+		if csvOptions.SkipLeadingRows != nil {
+			options = append(options, ddlOption{"skip_leading_rows", strconv.FormatInt(int64(*csvOptions.SkipLeadingRows), 10)})
+		}
+		if csvOptions.Quote != nil {
+			options = append(options, ddlOption{"quote", ddlString(*csvOptions.Quote)})
+		}
+		if csvOptions.AllowQuotedNewlines != nil {
+			options = append(options, ddlOption{"allow_quoted_newlines", strconv.FormatBool(*csvOptions.AllowQuotedNewlines)})
+		}
+		if csvOptions.AllowJaggedRows != nil {
+			options = append(options, ddlOption{"allow_jagged_rows", strconv.FormatBool(*csvOptions.AllowJaggedRows)})
+		}
+		if csvOptions.Encoding != nil {
+			options = append(options, ddlOption{"encoding", ddlString(*csvOptions.Encoding)})
+		}
+	}
+	if config.GoogleSheetsOptions != nil {
+		sheetsOptions := config.GoogleSheetsOptions
+		if sheetsOptions.Range != nil {
+			options = append(options, ddlOption{"sheet_range", ddlString(*sheetsOptions.Range)})
+		}
+		if sheetsOptions.SkipLeadingRows != nil {
+			options = append(options, ddlOption{"skip_leading_rows", strconv.FormatInt(int64(*sheetsOptions.SkipLeadingRows), 10)})
+		}
+	}
+	if config.ParquetOptions != nil {
+		if config.ParquetOptions.EnumAsString != nil {
+			options = append(options, ddlOption{"enum_as_string", strconv.FormatBool(*config.ParquetOptions.EnumAsString)})
+		}
+		if config.ParquetOptions.EnableListInference != nil {
+			options = append(options, ddlOption{"enable_list_inference", strconv.FormatBool(*config.ParquetOptions.EnableListInference)})
+		}
+	}
+	if config.BigtableOptions != nil {
+		// bigtable_options takes the options in their JSON representation
+		b, err := json.Marshal(config.BigtableOptions)
+		if err != nil {
+			return nil, errortools.ErrorMessage(err)
+		}
+		options = append(options, ddlOption{"bigtable_options", ddlString(string(b))})
+	}
+	if config.HivePartitioningOptions != nil {
+		hiveOptions := config.HivePartitioningOptions
+		if hiveOptions.SourceURIPrefix != nil {
+			options = append(options, ddlOption{"hive_partition_uri_prefix", ddlString(*hiveOptions.SourceURIPrefix)})
+		}
+		if hiveOptions.RequirePartitionFilter != nil && *hiveOptions.RequirePartitionFilter {
+			options = append(options, ddlOption{"require_hive_partition_filter", "true"})
+		}
+	}
+
+	writeOptionsDDL(&ddl, options)
+
+	return &ddl, nil
+}
+
+type ddlOption struct {
+	name  string
+	value string
+}
+
+// ddlTableOptions returns the options that all types of tables share
+func ddlTableOptions(table *Table) []ddlOption {
+	options := []ddlOption{}
+
+	if table.Description != nil && *table.Description != "" {
+		options = append(options, ddlOption{"description", ddlString(*table.Description)})
+	}
+	if table.FriendlyName != nil && *table.FriendlyName != "" {
+		options = append(options, ddlOption{"friendly_name", ddlString(*table.FriendlyName)})
+	}
+	if table.Labels != nil && len(*table.Labels) > 0 {
+		keys := []string{}
+		for key := range *table.Labels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		labels := []string{}
+		for _, key := range keys {
+			labels = append(labels, fmt.Sprintf("(%s, %s)", ddlString(key), ddlString((*table.Labels)[key])))
+		}
+		options = append(options, ddlOption{"labels", fmt.Sprintf("[%s]", strings.Join(labels, ", "))})
+	}
+	if table.ExpirationTime != nil {
+		expiration := time.UnixMilli(int64(*table.ExpirationTime)).UTC()
+		options = append(options, ddlOption{"expiration_timestamp", fmt.Sprintf("TIMESTAMP %s", ddlString(expiration.Format("2006-01-02 15:04:05.999 UTC")))})
+	}
+
+	return options
+}
+
+func writeOptionsDDL(ddl *strings.Builder, options []ddlOption) {
+	if len(options) == 0 {
+		return
+	}
+
+	ddl.WriteString("\nOPTIONS(")
+	for i, option := range options {
+		if i > 0 {
+			ddl.WriteString(",")
+		}
+		ddl.WriteString(fmt.Sprintf("\n  %s=%s", option.name, option.value))
+	}
+	ddl.WriteString("\n)")
+}
+
+func writePartitioningDDL(ddl *strings.Builder, table *Table) *errortools.Error {
+	if table.TimePartitioning != nil {
+		expression, e := ddlTimePartitionExpression(table)
+		if e != nil {
+			return e
+		}
+		ddl.WriteString(fmt.Sprintf("\nPARTITION BY %s", expression))
+	} else if table.RangePartitioning != nil {
+		r := table.RangePartitioning.Range
+		ddl.WriteString(fmt.Sprintf("\nPARTITION BY RANGE_BUCKET(%s, GENERATE_ARRAY(%s, %s, %s))", quoteIdentifier(table.RangePartitioning.Field), r.Start, r.End, r.Interval))
+	}
+
+	if table.Clustering != nil && len(table.Clustering.Fields) > 0 {
+		fields := []string{}
+		for _, field := range table.Clustering.Fields {
+			fields = append(fields, quoteIdentifier(field))
+		}
+		ddl.WriteString(fmt.Sprintf("\nCLUSTER BY %s", strings.Join(fields, ", ")))
+	}
+
+	return nil
+}
+
+func ddlTimePartitionExpression(table *Table) (string, *errortools.Error) {
+	partitionType := strings.ToUpper(table.TimePartitioning.Type)
+	if partitionType == "" {
+		partitionType = "DAY"
+	}
+	switch partitionType {
+	case "HOUR", "DAY", "MONTH", "YEAR":
+	default:
+		return "", errortools.ErrorMessagef("unsupported time partitioning type %s", table.TimePartitioning.Type)
+	}
+
+	if table.TimePartitioning.Field == nil || *table.TimePartitioning.Field == "" {
+		// ingestion time partitioning
+		if partitionType == "DAY" {
+			return "_PARTITIONDATE", nil
+		}
+		return fmt.Sprintf("TIMESTAMP_TRUNC(_PARTITIONTIME, %s)", partitionType), nil
+	}
+
+	field := *table.TimePartitioning.Field
+	fieldType := ""
+	if table.Schema != nil {
+		for _, f := range table.Schema.Fields {
+			if strings.EqualFold(f.Name, field) {
+				fieldType = normalizedFieldType(f.Type)
+				break
+			}
+		}
+	}
+
+	switch fieldType {
+	case "DATE":
+		if partitionType == "DAY" {
+			return quoteIdentifier(field), nil
+		}
+		return fmt.Sprintf("DATE_TRUNC(%s, %s)", quoteIdentifier(field), partitionType), nil
+	case "TIMESTAMP":
+		return fmt.Sprintf("TIMESTAMP_TRUNC(%s, %s)", quoteIdentifier(field), partitionType), nil
+	case "DATETIME":
+		return fmt.Sprintf("DATETIME_TRUNC(%s, %s)", quoteIdentifier(field), partitionType), nil
+	case "":
+		return "", errortools.ErrorMessagef("partitioning field %s is not in the schema", field)
+	}
+
+	return "", errortools.ErrorMessagef("partitioning field %s has unsupported type %s", field, fieldType)
+}
+
+func ddlColumns(fields []TableFieldSchema) (string, *errortools.Error) {
+	columns := []string{}
+	for _, field := range fields {
+		column, e := ddlColumn(field)
+		if e != nil {
+			return "", e
+		}
+		columns = append(columns, column)
+	}
+
+	return fmt.Sprintf("\n(\n  %s\n)", strings.Join(columns, ",\n  ")), nil
+}
+
+// ddlColumn returns the name of the field followed by its column schema
+func ddlColumn(field TableFieldSchema) (string, *errortools.Error) {
+	columnType, e := ddlColumnType(field)
+	if e != nil {
+		return "", e
+	}
+
+	column := fmt.Sprintf("%s %s", quoteIdentifier(field.Name), columnType)
+	if strings.ToUpper(field.Mode) == "REQUIRED" {
+		column += " NOT NULL"
+	}
+	if field.Description != "" {
+		column += fmt.Sprintf(" OPTIONS(description=%s)", ddlString(field.Description))
+	}
+
+	return column, nil
+}
+
+func ddlColumnType(field TableFieldSchema) (string, *errortools.Error) {
+	columnType := normalizedFieldType(field.Type)
+
+	switch columnType {
+	case "STRING", "BYTES":
+		if field.MaxLength != nil {
+			columnType += fmt.Sprintf("(%v)", int64(*field.MaxLength))
+		}
+	case "NUMERIC", "BIGNUMERIC":
+		if field.Precision != nil {
+			if field.Scale != nil {
+				columnType += fmt.Sprintf("(%v, %v)", int64(*field.Precision), int64(*field.Scale))
+			} else {
+				columnType += fmt.Sprintf("(%v)", int64(*field.Precision))
+			}
+		}
+	case "INT64", "FLOAT64", "BOOL", "TIMESTAMP", "DATE", "TIME", "DATETIME", "GEOGRAPHY", "JSON", "INTERVAL":
+	case "STRUCT":
+		nestedFields := []string{}
+		for _, nestedField := range field.Fields {
+			nestedField, e := ddlColumn(nestedField)
+			if e != nil {
+				return "", e
+			}
+			nestedFields = append(nestedFields, nestedField)
+		}
+		columnType = fmt.Sprintf("STRUCT<%s>", strings.Join(nestedFields, ", "))
+	default:
+		return "", errortools.ErrorMessagef("field %s has unsupported type %s", field.Name, field.Type)
+	}
+
+	if strings.ToUpper(field.Mode) == "REPEATED" {
+		columnType = fmt.Sprintf("ARRAY<%s>", columnType)
+	}
+
+	return columnType, nil
+}
+
+func ddlHasDescriptions(fields []TableFieldSchema) bool {
+	for _, field := range fields {
+		if field.Description != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ddlString returns s as a GoogleSQL string literal, whose escape sequences are a superset of Go's
+func ddlString(s string) string {
+	return strconv.Quote(s)
+}
+
+func ddlStringArray(values []string) string {
+	literals := []string{}
+	for _, value := range values {
+		literals = append(literals, ddlString(value))
+	}
+	return fmt.Sprintf("[%s]", strings.Join(literals, ", "))
+}
+
+// ddlConnectionId converts a connection id of the form projects/p/locations/l/connections/c to p.l.c
+func ddlConnectionId(connectionId string) string {
+	parts := strings.Split(connectionId, "/")
+	if len(parts) == 6 && parts[0] == "projects" && parts[2] == "locations" && parts[4] == "connections" {
+		return fmt.Sprintf("%s.%s.%s", parts[1], parts[3], parts[5])
+	}
+	return connectionId
+}
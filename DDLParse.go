@@ -0,0 +1,1392 @@
+package googlebigquery
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+	go_types "github.com/leapforce-libraries/go_types"
+)
+
+// ParseDDL parses a CREATE TABLE, CREATE VIEW or CREATE MATERIALIZED VIEW statement into the Table it defines,
+// the inverse of TableDDL. The following subset of the DDL is supported:
+//
+//	CREATE [OR REPLACE] TABLE [IF NOT EXISTS] name (column, ...) [PARTITION BY ...] [CLUSTER BY ...] [OPTIONS(...)]
+//	CREATE [OR REPLACE] VIEW [IF NOT EXISTS] name [(column [OPTIONS(description=...)], ...)] [OPTIONS(...)] AS query
+//	CREATE [OR REPLACE] MATERIALIZED VIEW [IF NOT EXISTS] name [PARTITION BY ...] [CLUSTER BY ...] [OPTIONS(...)] AS query
+//
+// Columns have a type, including parameterized, ARRAY and STRUCT types, and optionally NOT NULL and
+// OPTIONS(description=...). Tables can be partitioned by ingestion time, by a DATE, TIMESTAMP or DATETIME column
+// or by RANGE_BUCKET, and have the options description, friendly_name, labels, expiration_timestamp,
+// partition_expiration_days, require_partition_filter and kms_key_name. Materialized views also have the options
+// enable_refresh and refresh_interval_minutes. The schema of a view only holds the column descriptions.
+//
+// Other constructs, such as temporary and external tables, CREATE TABLE AS SELECT, default values and collation,
+// result in an error with the line and column of the construct.
+func ParseDDL(ddl string) (*Table, *errortools.Error) {
+	parser := ddlParser{source: ddl}
+
+	table, e := parser.parseCreate()
+	if e != nil {
+		return nil, e
+	}
+
+	return table, nil
+}
+
+type ddlTokenKind int
+
+const (
+	ddlTokenEOF ddlTokenKind = iota
+	ddlTokenIdentifier
+	ddlTokenQuotedIdentifier
+	ddlTokenString
+	ddlTokenNumber
+	ddlTokenSymbol
+)
+
+type ddlToken struct {
+	kind  ddlTokenKind
+	text  string // the value of identifiers and strings, the source of other tokens
+	start int
+	end   int
+}
+
+type ddlParser struct {
+	source string
+	offset int
+	peeked *ddlToken
+}
+
+// errorf returns an error at the position of token
+func (parser *ddlParser) errorf(token ddlToken, format string, a ...interface{}) *errortools.Error {
+	line := 1 + strings.Count(parser.source[:token.start], "\n")
+	column := token.start - strings.LastIndex(parser.source[:token.start], "\n")
+	return errortools.ErrorMessagef("line %v, column %v: %s", line, column, fmt.Sprintf(format, a...))
+}
+
+func (token ddlToken) String() string {
+	switch token.kind {
+	case ddlTokenEOF:
+		return "end of statement"
+	case ddlTokenQuotedIdentifier:
+		return quoteIdentifier(token.text)
+	case ddlTokenString:
+		return "string " + strconv.Quote(token.text)
+	}
+	return token.text
+}
+
+func (parser *ddlParser) peek() (ddlToken, *errortools.Error) {
+	if parser.peeked == nil {
+		token, e := parser.lex()
+		if e != nil {
+			return ddlToken{}, e
+		}
+		parser.peeked = &token
+	}
+	return *parser.peeked, nil
+}
+
+func (parser *ddlParser) next() (ddlToken, *errortools.Error) {
+	token, e := parser.peek()
+	parser.peeked = nil
+	return token, e
+}
+
+// isKeyword reports whether token is one of the keywords, which are case insensitive
+func (token ddlToken) isKeyword(keywords ...string) bool {
+	if token.kind != ddlTokenIdentifier {
+		return false
+	}
+	for _, keyword := range keywords {
+		if strings.EqualFold(token.text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func (token ddlToken) isSymbol(symbol string) bool {
+	return token.kind == ddlTokenSymbol && token.text == symbol
+}
+
+// acceptKeyword consumes the next token if it is the keyword
+func (parser *ddlParser) acceptKeyword(keyword string) (bool, *errortools.Error) {
+	token, e := parser.peek()
+	if e != nil {
+		return false, e
+	}
+	if !token.isKeyword(keyword) {
+		return false, nil
+	}
+	parser.peeked = nil
+	return true, nil
+}
+
+// acceptSymbol consumes the next token if it is the symbol
+func (parser *ddlParser) acceptSymbol(symbol string) (bool, *errortools.Error) {
+	token, e := parser.peek()
+	if e != nil {
+		return false, e
+	}
+	if !token.isSymbol(symbol) {
+		return false, nil
+	}
+	parser.peeked = nil
+	return true, nil
+}
+
+func (parser *ddlParser) expectKeyword(keywords ...string) *errortools.Error {
+	for _, keyword := range keywords {
+		token, e := parser.next()
+		if e != nil {
+			return e
+		}
+		if !token.isKeyword(keyword) {
+			return parser.errorf(token, "expected %s, got %s", keyword, token)
+		}
+	}
+	return nil
+}
+
+func (parser *ddlParser) expectSymbol(symbol string) *errortools.Error {
+	token, e := parser.next()
+	if e != nil {
+		return e
+	}
+	if !token.isSymbol(symbol) {
+		return parser.errorf(token, "expected %s, got %s", symbol, token)
+	}
+	return nil
+}
+
+func (parser *ddlParser) expectIdentifier(what string) (ddlToken, *errortools.Error) {
+	token, e := parser.next()
+	if e != nil {
+		return ddlToken{}, e
+	}
+	if token.kind != ddlTokenIdentifier && token.kind != ddlTokenQuotedIdentifier {
+		return ddlToken{}, parser.errorf(token, "expected %s, got %s", what, token)
+	}
+	return token, nil
+}
+
+// lex reads the next token, skipping whitespace and comments
+func (parser *ddlParser) lex() (ddlToken, *errortools.Error) {
+	source := parser.source
+
+	for parser.offset < len(source) {
+		c := source[parser.offset]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			parser.offset++
+		case c == '#' || strings.HasPrefix(source[parser.offset:], "--"):
+			end := strings.IndexByte(source[parser.offset:], '\n')
+			if end < 0 {
+				parser.offset = len(source)
+			} else {
+				parser.offset += end + 1
+			}
+		case strings.HasPrefix(source[parser.offset:], "/*"):
+			end := strings.Index(source[parser.offset+2:], "*/")
+			if end < 0 {
+				return ddlToken{}, parser.errorf(ddlToken{start: parser.offset}, "unterminated comment")
+			}
+			parser.offset += end + 4
+		default:
+			return parser.lexToken()
+		}
+	}
+
+	return ddlToken{kind: ddlTokenEOF, start: len(source), end: len(source)}, nil
+}
+
+func (parser *ddlParser) lexToken() (ddlToken, *errortools.Error) {
+	source := parser.source
+	start := parser.offset
+	c := source[start]
+
+	token := ddlToken{start: start}
+
+	switch {
+	case c == '`':
+		end := start + 1
+		for end < len(source) && source[end] != '`' {
+			if source[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(source) {
+			return ddlToken{}, parser.errorf(token, "unterminated quoted identifier")
+		}
+		token.kind = ddlTokenQuotedIdentifier
+		token.text = strings.NewReplacer("\\`", "`", "\\\\", "\\").Replace(source[start+1 : end])
+		parser.offset = end + 1
+	case c == '\'' || c == '"' || ((c == 'r' || c == 'R' || c == 'b' || c == 'B') && start+1 < len(source) && (source[start+1] == '\'' || source[start+1] == '"')):
+		return parser.lexString()
+	case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		end := start + 1
+		for end < len(source) && (source[end] == '_' || (source[end] >= 'a' && source[end] <= 'z') || (source[end] >= 'A' && source[end] <= 'Z') || (source[end] >= '0' && source[end] <= '9')) {
+			end++
+		}
+		token.kind = ddlTokenIdentifier
+		token.text = source[start:end]
+		parser.offset = end
+	case (c >= '0' && c <= '9') || (c == '.' && start+1 < len(source) && source[start+1] >= '0' && source[start+1] <= '9'):
+		end := start
+		for end < len(source) && ((source[end] >= '0' && source[end] <= '9') || source[end] == '.') {
+			end++
+		}
+		if end < len(source) && (source[end] == 'e' || source[end] == 'E') {
+			end++
+			if end < len(source) && (source[end] == '+' || source[end] == '-') {
+				end++
+			}
+			for end < len(source) && source[end] >= '0' && source[end] <= '9' {
+				end++
+			}
+		}
+		token.kind = ddlTokenNumber
+		token.text = source[start:end]
+		parser.offset = end
+	default:
+		token.kind = ddlTokenSymbol
+		token.text = string(c)
+		parser.offset = start + 1
+	}
+
+	token.end = parser.offset
+
+	return token, nil
+}
+
+// lexString reads a string literal, which may be raw, bytes and triple-quoted
+func (parser *ddlParser) lexString() (ddlToken, *errortools.Error) {
+	source := parser.source
+	token := ddlToken{kind: ddlTokenString, start: parser.offset}
+
+	raw := false
+	offset := parser.offset
+	if c := source[offset]; c == 'r' || c == 'R' || c == 'b' || c == 'B' {
+		raw = c == 'r' || c == 'R'
+		offset++
+	}
+
+	quote := source[offset : offset+1]
+	if strings.HasPrefix(source[offset:], strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+	}
+	offset += len(quote)
+
+	var value strings.Builder
+	for {
+		if offset >= len(source) || (len(quote) == 1 && source[offset] == '\n') {
+			return ddlToken{}, parser.errorf(token, "unterminated string")
+		}
+		if strings.HasPrefix(source[offset:], quote) {
+			offset += len(quote)
+			break
+		}
+		if source[offset] != '\\' {
+			value.WriteByte(source[offset])
+			offset++
+			continue
+		}
+		if offset+1 >= len(source) {
+			return ddlToken{}, parser.errorf(token, "unterminated string")
+		}
+		if raw {
+			value.WriteString(source[offset : offset+2])
+			offset += 2
+			continue
+		}
+
+		switch source[offset+1] {
+		case '\'', '"', '`', '?':
+			value.WriteByte(source[offset+1])
+			offset += 2
+			continue
+		}
+		r, multibyte, tail, err := strconv.UnquoteChar(source[offset:], 0)
+		if err != nil {
+			return ddlToken{}, parser.errorf(ddlToken{start: offset}, "invalid escape sequence in string")
+		}
+		if multibyte {
+			value.WriteRune(r)
+		} else {
+			value.WriteByte(byte(r))
+		}
+		offset = len(source) - len(tail)
+	}
+
+	token.text = value.String()
+	token.end = offset
+	parser.offset = offset
+
+	return token, nil
+}
+
+func (parser *ddlParser) parseCreate() (*Table, *errortools.Error) {
+	e := parser.expectKeyword("CREATE")
+	if e != nil {
+		return nil, e
+	}
+
+	ok, e := parser.acceptKeyword("OR")
+	if e != nil {
+		return nil, e
+	}
+	if ok {
+		e = parser.expectKeyword("REPLACE")
+		if e != nil {
+			return nil, e
+		}
+	}
+
+	token, e := parser.next()
+	if e != nil {
+		return nil, e
+	}
+
+	table := Table{}
+
+	switch {
+	case token.isKeyword("TABLE"):
+		table.Type = TableTypeTable
+	case token.isKeyword("VIEW"):
+		table.Type = TableTypeView
+	case token.isKeyword("MATERIALIZED"):
+		e = parser.expectKeyword("VIEW")
+		if e != nil {
+			return nil, e
+		}
+		table.Type = TableTypeMaterializedView
+	case token.isKeyword("TEMP", "TEMPORARY", "EXTERNAL", "SNAPSHOT"):
+		return nil, parser.errorf(token, "%s tables are not supported", strings.ToLower(token.text))
+	default:
+		return nil, parser.errorf(token, "expected TABLE, VIEW or MATERIALIZED VIEW, got %s", token)
+	}
+
+	ok, e = parser.acceptKeyword("IF")
+	if e != nil {
+		return nil, e
+	}
+	if ok {
+		e = parser.expectKeyword("NOT", "EXISTS")
+		if e != nil {
+			return nil, e
+		}
+	}
+
+	table.TableReference, e = parser.parseTableReference()
+	if e != nil {
+		return nil, e
+	}
+
+	switch table.Type {
+	case TableTypeTable:
+		e = parser.parseTable(&table)
+	case TableTypeView:
+		e = parser.parseView(&table)
+	case TableTypeMaterializedView:
+		e = parser.parseMaterializedView(&table)
+	}
+	if e != nil {
+		return nil, e
+	}
+
+	_, e = parser.acceptSymbol(";")
+	if e != nil {
+		return nil, e
+	}
+	token, e = parser.next()
+	if e != nil {
+		return nil, e
+	}
+	if token.kind != ddlTokenEOF {
+		return nil, parser.errorf(token, "unexpected %s", token)
+	}
+
+	return &table, nil
+}
+
+// parseTableReference parses a table name of one to three parts, of which the project may contain dashes
+func (parser *ddlParser) parseTableReference() (TableReference, *errortools.Error) {
+	first, e := parser.expectIdentifier("table name")
+	if e != nil {
+		return TableReference{}, e
+	}
+
+	path := first.text
+	end := first.end
+	for {
+		token, e := parser.peek()
+		if e != nil {
+			return TableReference{}, e
+		}
+		// tokens directly following each other in an unquoted name, e.g. my-project.dataset.table
+		if token.start != end || !(token.isSymbol(".") || token.isSymbol("-") || token.kind == ddlTokenIdentifier || token.kind == ddlTokenNumber || token.kind == ddlTokenQuotedIdentifier) {
+			break
+		}
+		parser.peeked = nil
+		path += token.text
+		end = token.end
+	}
+
+	parts := strings.Split(path, ".")
+	if len(parts) > 3 {
+		return TableReference{}, parser.errorf(first, "invalid table name %s", path)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return TableReference{}, parser.errorf(first, "invalid table name %s", path)
+		}
+	}
+
+	tableReference := TableReference{TableID: parts[len(parts)-1]}
+	if len(parts) > 1 {
+		tableReference.DatasetID = parts[len(parts)-2]
+	}
+	if len(parts) > 2 {
+		tableReference.ProjectID = parts[0]
+	}
+
+	return tableReference, nil
+}
+
+func (parser *ddlParser) parseTable(table *Table) *errortools.Error {
+	token, e := parser.peek()
+	if e != nil {
+		return e
+	}
+	if token.isKeyword("LIKE", "COPY", "CLONE") {
+		return parser.errorf(token, "CREATE TABLE %s is not supported", strings.ToUpper(token.text))
+	}
+
+	if token.isSymbol("(") {
+		parser.peeked = nil
+
+		fields := []TableFieldSchema{}
+		for {
+			token, e := parser.peek()
+			if e != nil {
+				return e
+			}
+			if token.isKeyword("PRIMARY", "FOREIGN", "CONSTRAINT") {
+				return parser.errorf(token, "table constraints are not supported")
+			}
+
+			field, e := parser.parseColumn(1)
+			if e != nil {
+				return e
+			}
+			fields = append(fields, field)
+
+			token, e = parser.next()
+			if e != nil {
+				return e
+			}
+			if token.isSymbol(")") {
+				break
+			}
+			if !token.isSymbol(",") {
+				return parser.errorf(token, "expected , or ), got %s", token)
+			}
+		}
+
+		e = ValidateSchema(fields)
+		if e != nil {
+			return parser.errorf(token, "%s", e.Message())
+		}
+		table.Schema = &TableSchema{Fields: fields}
+	}
+
+	token, e = parser.peek()
+	if e != nil {
+		return e
+	}
+	if token.isKeyword("DEFAULT") {
+		return parser.errorf(token, "DEFAULT COLLATE is not supported")
+	}
+
+	e = parser.parsePartitioning(table)
+	if e != nil {
+		return e
+	}
+
+	e = parser.parseOptions(table)
+	if e != nil {
+		return e
+	}
+
+	token, e = parser.peek()
+	if e != nil {
+		return e
+	}
+	if token.isKeyword("AS") {
+		return parser.errorf(token, "CREATE TABLE AS SELECT is not supported")
+	}
+	if table.Schema == nil {
+		return parser.errorf(token, "table has no columns")
+	}
+
+	return nil
+}
+
+func (parser *ddlParser) parseView(table *Table) *errortools.Error {
+	ok, e := parser.acceptSymbol("(")
+	if e != nil {
+		return e
+	}
+	if ok {
+		fields := []TableFieldSchema{}
+		for {
+			name, e := parser.expectIdentifier("column name")
+			if e != nil {
+				return e
+			}
+			field := TableFieldSchema{Name: name.text}
+
+			token, e := parser.peek()
+			if e != nil {
+				return e
+			}
+			if token.isKeyword("OPTIONS") {
+				e = parser.parseColumnOptions(&field)
+				if e != nil {
+					return e
+				}
+			}
+			fields = append(fields, field)
+
+			token, e = parser.next()
+			if e != nil {
+				return e
+			}
+			if token.isSymbol(")") {
+				break
+			}
+			if !token.isSymbol(",") {
+				return parser.errorf(token, "expected , or ), got %s", token)
+			}
+		}
+		table.Schema = &TableSchema{Fields: fields}
+	}
+
+	e = parser.parseOptions(table)
+	if e != nil {
+		return e
+	}
+
+	query, e := parser.parseQuery()
+	if e != nil {
+		return e
+	}
+
+	useLegacySQL := false
+	table.View = &ViewDefinition{
+		Query:        query,
+		UseLegacySQL: &useLegacySQL,
+	}
+
+	return nil
+}
+
+func (parser *ddlParser) parseMaterializedView(table *Table) *errortools.Error {
+	table.MaterializedView = &MaterializedViewDefinition{}
+
+	token, e := parser.peek()
+	if e != nil {
+		return e
+	}
+	if token.isSymbol("(") {
+		return parser.errorf(token, "column lists of materialized views are not supported")
+	}
+
+	e = parser.parsePartitioning(table)
+	if e != nil {
+		return e
+	}
+
+	e = parser.parseOptions(table)
+	if e != nil {
+		return e
+	}
+
+	table.MaterializedView.Query, e = parser.parseQuery()
+	if e != nil {
+		return e
+	}
+
+	return nil
+}
+
+// parseQuery returns the query following AS, up to the end of the statement
+func (parser *ddlParser) parseQuery() (string, *errortools.Error) {
+	e := parser.expectKeyword("AS")
+	if e != nil {
+		return "", e
+	}
+
+	// the query is not parsed, it is only lexed to find the terminator outside of strings and comments,
+	// comments inside the query are kept
+	start := parser.offset
+	for {
+		token, e := parser.peek()
+		if e != nil {
+			return "", e
+		}
+		if token.kind == ddlTokenEOF || token.isSymbol(";") {
+			break
+		}
+		parser.peeked = nil
+	}
+
+	query := strings.TrimSpace(parser.source[start:parser.peeked.start])
+	if query == "" {
+		return "", parser.errorf(*parser.peeked, "expected query, got %s", *parser.peeked)
+	}
+
+	return query, nil
+}
+
+// parseColumn parses the name and the column schema of a column or a STRUCT field
+func (parser *ddlParser) parseColumn(depth int) (TableFieldSchema, *errortools.Error) {
+	name, e := parser.expectIdentifier("column name")
+	if e != nil {
+		return TableFieldSchema{}, e
+	}
+
+	field := TableFieldSchema{
+		Name: name.text,
+		Mode: "NULLABLE",
+	}
+
+	e = parser.parseFieldType(&field, depth)
+	if e != nil {
+		return TableFieldSchema{}, e
+	}
+
+	for {
+		token, e := parser.peek()
+		if e != nil {
+			return TableFieldSchema{}, e
+		}
+
+		switch {
+		case token.isKeyword("NOT"):
+			parser.peeked = nil
+			e = parser.expectKeyword("NULL")
+			if e != nil {
+				return TableFieldSchema{}, e
+			}
+			if field.Mode == "REPEATED" {
+				return TableFieldSchema{}, parser.errorf(token, "ARRAY column %s cannot be NOT NULL", field.Name)
+			}
+			field.Mode = "REQUIRED"
+		case token.isKeyword("OPTIONS"):
+			e = parser.parseColumnOptions(&field)
+			if e != nil {
+				return TableFieldSchema{}, e
+			}
+		case token.isKeyword("DEFAULT", "COLLATE", "PRIMARY", "REFERENCES"):
+			return TableFieldSchema{}, parser.errorf(token, "%s of column %s is not supported", strings.ToUpper(token.text), field.Name)
+		default:
+			return field, nil
+		}
+	}
+}
+
+func (parser *ddlParser) parseFieldType(field *TableFieldSchema, depth int) *errortools.Error {
+	token, e := parser.next()
+	if e != nil {
+		return e
+	}
+	if token.kind != ddlTokenIdentifier {
+		return parser.errorf(token, "expected type of column %s, got %s", field.Name, token)
+	}
+
+	switch strings.ToUpper(token.text) {
+	case "ARRAY":
+		e = parser.expectSymbol("<")
+		if e != nil {
+			return e
+		}
+		e = parser.parseFieldType(field, depth)
+		if e != nil {
+			return e
+		}
+		if field.Mode == "REPEATED" {
+			return parser.errorf(token, "column %s: arrays of arrays are not supported", field.Name)
+		}
+		field.Mode = "REPEATED"
+		return parser.expectSymbol(">")
+	case "STRUCT":
+		if depth >= maxSchemaNestingDepth {
+			return parser.errorf(token, "column %s exceeds the maximum nesting depth of %v", field.Name, maxSchemaNestingDepth)
+		}
+		e = parser.expectSymbol("<")
+		if e != nil {
+			return e
+		}
+		field.Type = "RECORD"
+		field.Fields = []TableFieldSchema{}
+		for {
+			nestedField, e := parser.parseColumn(depth + 1)
+			if e != nil {
+				return e
+			}
+			field.Fields = append(field.Fields, nestedField)
+
+			token, e := parser.next()
+			if e != nil {
+				return e
+			}
+			if token.isSymbol(">") {
+				return nil
+			}
+			if !token.isSymbol(",") {
+				return parser.errorf(token, "expected , or >, got %s", token)
+			}
+		}
+	case "STRING", "BYTES":
+		field.Type = strings.ToUpper(token.text)
+		parameters, e := parser.parseTypeParameters(1)
+		if e != nil {
+			return e
+		}
+		if len(parameters) > 0 {
+			field.MaxLength = &parameters[0]
+		}
+	case "NUMERIC", "DECIMAL", "BIGNUMERIC", "BIGDECIMAL":
+		field.Type = "NUMERIC"
+		if token.isKeyword("BIGNUMERIC", "BIGDECIMAL") {
+			field.Type = "BIGNUMERIC"
+		}
+		parameters, e := parser.parseTypeParameters(2)
+		if e != nil {
+			return e
+		}
+		if len(parameters) > 0 {
+			field.Precision = &parameters[0]
+		}
+		if len(parameters) > 1 {
+			field.Scale = &parameters[1]
+		}
+	case "INT64", "INT", "SMALLINT", "INTEGER", "BIGINT", "TINYINT", "BYTEINT":
+		field.Type = "INTEGER"
+	case "FLOAT64":
+		field.Type = "FLOAT"
+	case "BOOL", "BOOLEAN":
+		field.Type = "BOOLEAN"
+	case "TIMESTAMP", "DATE", "TIME", "DATETIME", "GEOGRAPHY", "JSON", "INTERVAL":
+		field.Type = strings.ToUpper(token.text)
+	default:
+		return parser.errorf(token, "column %s has unsupported type %s", field.Name, token.text)
+	}
+
+	return nil
+}
+
+// parseTypeParameters parses the optional parameters of a parameterized type, e.g. the 10 of STRING(10)
+func (parser *ddlParser) parseTypeParameters(max int) ([]go_types.Int64String, *errortools.Error) {
+	ok, e := parser.acceptSymbol("(")
+	if e != nil || !ok {
+		return nil, e
+	}
+
+	parameters := []go_types.Int64String{}
+	for {
+		token, e := parser.next()
+		if e != nil {
+			return nil, e
+		}
+		i, err := strconv.ParseInt(token.text, 10, 64)
+		if token.kind != ddlTokenNumber || err != nil {
+			return nil, parser.errorf(token, "expected integer type parameter, got %s", token)
+		}
+		if len(parameters) == max {
+			return nil, parser.errorf(token, "too many type parameters")
+		}
+		parameters = append(parameters, go_types.Int64String(i))
+
+		token, e = parser.next()
+		if e != nil {
+			return nil, e
+		}
+		if token.isSymbol(")") {
+			return parameters, nil
+		}
+		if !token.isSymbol(",") {
+			return nil, parser.errorf(token, "expected , or ), got %s", token)
+		}
+	}
+}
+
+func (parser *ddlParser) parsePartitioning(table *Table) *errortools.Error {
+	ok, e := parser.acceptKeyword("PARTITION")
+	if e != nil {
+		return e
+	}
+	if ok {
+		e = parser.expectKeyword("BY")
+		if e != nil {
+			return e
+		}
+		e = parser.parsePartitionExpression(table)
+		if e != nil {
+			return e
+		}
+	}
+
+	ok, e = parser.acceptKeyword("CLUSTER")
+	if e != nil {
+		return e
+	}
+	if ok {
+		e = parser.expectKeyword("BY")
+		if e != nil {
+			return e
+		}
+
+		table.Clustering = &Clustering{}
+		for {
+			column, e := parser.expectIdentifier("clustering column")
+			if e != nil {
+				return e
+			}
+			e = parser.checkColumnType(table, column, "clustering", "")
+			if e != nil {
+				return e
+			}
+			table.Clustering.Fields = append(table.Clustering.Fields, column.text)
+
+			ok, e := parser.acceptSymbol(",")
+			if e != nil {
+				return e
+			}
+			if !ok {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// parsePartitionExpression parses the partitioning expressions that TableDDL generates
+// and their equivalents, e.g. DATE(_PARTITIONTIME) for _PARTITIONDATE
+func (parser *ddlParser) parsePartitionExpression(table *Table) *errortools.Error {
+	token, e := parser.next()
+	if e != nil {
+		return e
+	}
+
+	if token.kind == ddlTokenQuotedIdentifier || (token.kind == ddlTokenIdentifier && !token.isKeyword("_PARTITIONDATE")) {
+		next, e := parser.peek()
+		if e != nil {
+			return e
+		}
+		if !next.isSymbol("(") {
+			// partitioning by a DATE column
+			e = parser.checkColumnType(table, token, "partitioning", "DATE")
+			if e != nil {
+				return e
+			}
+			table.TimePartitioning = &TimePartitioning{Type: "DAY", Field: &token.text}
+			return nil
+		}
+	}
+
+	switch {
+	case token.isKeyword("_PARTITIONDATE"):
+		table.TimePartitioning = &TimePartitioning{Type: "DAY"}
+		return nil
+	case token.isKeyword("DATE", "DATE_TRUNC", "TIMESTAMP_TRUNC", "DATETIME_TRUNC"):
+		e = parser.expectSymbol("(")
+		if e != nil {
+			return e
+		}
+		column, e := parser.expectIdentifier("partitioning column")
+		if e != nil {
+			return e
+		}
+
+		partitionType := "DAY"
+		if !token.isKeyword("DATE") {
+			e = parser.expectSymbol(",")
+			if e != nil {
+				return e
+			}
+			unit, e := parser.next()
+			if e != nil {
+				return e
+			}
+			units := []string{"HOUR", "DAY", "MONTH", "YEAR"}
+			if token.isKeyword("DATE_TRUNC") {
+				units = []string{"MONTH", "YEAR"}
+			}
+			if !unit.isKeyword(units...) {
+				return parser.errorf(unit, "expected %s, got %s", strings.Join(units, ", "), unit)
+			}
+			partitionType = strings.ToUpper(unit.text)
+		}
+
+		e = parser.expectSymbol(")")
+		if e != nil {
+			return e
+		}
+
+		if column.isKeyword("_PARTITIONTIME") {
+			if token.isKeyword("DATE_TRUNC", "DATETIME_TRUNC") {
+				return parser.errorf(column, "_PARTITIONTIME can only be truncated by TIMESTAMP_TRUNC")
+			}
+			table.TimePartitioning = &TimePartitioning{Type: partitionType}
+			return nil
+		}
+
+		switch strings.ToUpper(token.text) {
+		case "DATE":
+			e = parser.checkColumnType(table, column, "partitioning", "TIMESTAMP", "DATETIME")
+		case "DATE_TRUNC":
+			e = parser.checkColumnType(table, column, "partitioning", "DATE")
+		case "TIMESTAMP_TRUNC":
+			e = parser.checkColumnType(table, column, "partitioning", "TIMESTAMP")
+		case "DATETIME_TRUNC":
+			e = parser.checkColumnType(table, column, "partitioning", "DATETIME")
+		}
+		if e != nil {
+			return e
+		}
+
+		table.TimePartitioning = &TimePartitioning{Type: partitionType, Field: &column.text}
+		return nil
+	case token.isKeyword("RANGE_BUCKET"):
+		e = parser.expectSymbol("(")
+		if e != nil {
+			return e
+		}
+		column, e := parser.expectIdentifier("partitioning column")
+		if e != nil {
+			return e
+		}
+		e = parser.checkColumnType(table, column, "partitioning", "INT64")
+		if e != nil {
+			return e
+		}
+		e = parser.expectSymbol(",")
+		if e != nil {
+			return e
+		}
+		e = parser.expectKeyword("GENERATE_ARRAY")
+		if e != nil {
+			return e
+		}
+		e = parser.expectSymbol("(")
+		if e != nil {
+			return e
+		}
+
+		bounds := []string{}
+		for i := 0; i < 3; i++ {
+			if i > 0 {
+				e = parser.expectSymbol(",")
+				if e != nil {
+					return e
+				}
+			}
+			value, e := parser.parseValue()
+			if e != nil {
+				return e
+			}
+			bound, e := value.integerValue()
+			if e != nil {
+				return parser.errorf(value.token, "%s", e.Message())
+			}
+			bounds = append(bounds, strconv.FormatInt(bound, 10))
+		}
+
+		e = parser.expectSymbol(")")
+		if e != nil {
+			return e
+		}
+		e = parser.expectSymbol(")")
+		if e != nil {
+			return e
+		}
+
+		table.RangePartitioning = &RangePartitioning{Field: column.text}
+		table.RangePartitioning.Range.Start = bounds[0]
+		table.RangePartitioning.Range.End = bounds[1]
+		table.RangePartitioning.Range.Interval = bounds[2]
+		return nil
+	}
+
+	return parser.errorf(token, "unsupported partitioning expression %s", token)
+}
+
+// checkColumnType checks that column is a top-level column of table of one of the types, if table has a schema
+func (parser *ddlParser) checkColumnType(table *Table, column ddlToken, use string, types ...string) *errortools.Error {
+	if table.Schema == nil {
+		return nil
+	}
+
+	for _, field := range table.Schema.Fields {
+		if !strings.EqualFold(field.Name, column.text) {
+			continue
+		}
+		if strings.ToUpper(field.Mode) == "REPEATED" {
+			return parser.errorf(column, "%s column %s cannot be an ARRAY", use, column.text)
+		}
+		for _, t := range types {
+			if t == "" || normalizedFieldType(field.Type) == t {
+				return nil
+			}
+		}
+		return parser.errorf(column, "%s column %s must be of type %s", use, column.text, strings.Join(types, " or "))
+	}
+
+	return parser.errorf(column, "%s column %s is not a column of the table", use, column.text)
+}
+
+type ddlValueKind int
+
+const (
+	ddlValueString ddlValueKind = iota
+	ddlValueNumber
+	ddlValueBool
+	ddlValueNull
+	ddlValueTimestamp
+	ddlValueArray
+	ddlValueTuple
+)
+
+// ddlValue is a literal value of an option
+type ddlValue struct {
+	token  ddlToken
+	kind   ddlValueKind
+	text   string
+	values []ddlValue
+}
+
+func (parser *ddlParser) parseValue() (ddlValue, *errortools.Error) {
+	token, e := parser.next()
+	if e != nil {
+		return ddlValue{}, e
+	}
+
+	value := ddlValue{token: token}
+
+	switch {
+	case token.kind == ddlTokenString:
+		value.kind = ddlValueString
+		value.text = token.text
+	case token.kind == ddlTokenNumber:
+		value.kind = ddlValueNumber
+		value.text = token.text
+	case token.isSymbol("-"):
+		number, e := parser.next()
+		if e != nil {
+			return ddlValue{}, e
+		}
+		if number.kind != ddlTokenNumber {
+			return ddlValue{}, parser.errorf(number, "expected number, got %s", number)
+		}
+		value.kind = ddlValueNumber
+		value.text = "-" + number.text
+	case token.isKeyword("TRUE", "FALSE"):
+		value.kind = ddlValueBool
+		value.text = strings.ToLower(token.text)
+	case token.isKeyword("NULL"):
+		value.kind = ddlValueNull
+	case token.isKeyword("TIMESTAMP"):
+		literal, e := parser.next()
+		if e != nil {
+			return ddlValue{}, e
+		}
+		if literal.kind != ddlTokenString {
+			return ddlValue{}, parser.errorf(literal, "expected timestamp literal, got %s", literal)
+		}
+		value.kind = ddlValueTimestamp
+		value.text = literal.text
+	case token.isSymbol("[") || token.isSymbol("("):
+		value.kind = ddlValueArray
+		closing := "]"
+		if token.isSymbol("(") {
+			value.kind = ddlValueTuple
+			closing = ")"
+		}
+		ok, e := parser.acceptSymbol(closing)
+		if e != nil {
+			return ddlValue{}, e
+		}
+		for !ok {
+			element, e := parser.parseValue()
+			if e != nil {
+				return ddlValue{}, e
+			}
+			value.values = append(value.values, element)
+
+			separator, e := parser.next()
+			if e != nil {
+				return ddlValue{}, e
+			}
+			ok = separator.isSymbol(closing)
+			if !ok && !separator.isSymbol(",") {
+				return ddlValue{}, parser.errorf(separator, "expected , or %s, got %s", closing, separator)
+			}
+		}
+	default:
+		return ddlValue{}, parser.errorf(token, "unsupported option value %s, only literals are supported", token)
+	}
+
+	return value, nil
+}
+
+func (value ddlValue) stringValue() (string, *errortools.Error) {
+	if value.kind != ddlValueString {
+		return "", errortools.ErrorMessagef("expected string, got %s", value.token)
+	}
+	return value.text, nil
+}
+
+func (value ddlValue) boolValue() (bool, *errortools.Error) {
+	if value.kind != ddlValueBool {
+		return false, errortools.ErrorMessagef("expected TRUE or FALSE, got %s", value.token)
+	}
+	return value.text == "true", nil
+}
+
+func (value ddlValue) floatValue() (float64, *errortools.Error) {
+	f, err := strconv.ParseFloat(value.text, 64)
+	if value.kind != ddlValueNumber || err != nil {
+		return 0, errortools.ErrorMessagef("expected number, got %s", value.token)
+	}
+	return f, nil
+}
+
+func (value ddlValue) integerValue() (int64, *errortools.Error) {
+	i, err := strconv.ParseInt(value.text, 10, 64)
+	if value.kind != ddlValueNumber || err != nil {
+		return 0, errortools.ErrorMessagef("expected integer, got %s", value.token)
+	}
+	return i, nil
+}
+
+// timestampValue returns the time of a timestamp literal, which is in UTC unless it has a time zone
+func (value ddlValue) timestampValue() (time.Time, *errortools.Error) {
+	if value.kind != ddlValueTimestamp {
+		return time.Time{}, errortools.ErrorMessagef("expected timestamp literal, got %s", value.token)
+	}
+
+	text := strings.TrimSpace(value.text)
+	if strings.HasSuffix(text, " UTC") {
+		text = strings.TrimSuffix(text, " UTC")
+	}
+
+	layouts := []string{
+		"2006-01-02 15:04:05.999999999Z07:00",
+		"2006-01-02 15:04:05.999999999Z07",
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02T15:04:05.999999999Z07:00",
+		"2006-01-02T15:04:05.999999999",
+		"2006-01-02",
+	}
+	for _, layout := range layouts {
+		t, err := time.ParseInLocation(layout, text, time.UTC)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errortools.ErrorMessagef("invalid timestamp literal %s", strconv.Quote(value.text))
+}
+
+type ddlParsedOption struct {
+	name  ddlToken
+	value ddlValue
+}
+
+// parseOptionList parses OPTIONS(name=value, ...)
+func (parser *ddlParser) parseOptionList() ([]ddlParsedOption, *errortools.Error) {
+	e := parser.expectKeyword("OPTIONS")
+	if e != nil {
+		return nil, e
+	}
+	e = parser.expectSymbol("(")
+	if e != nil {
+		return nil, e
+	}
+
+	options := []ddlParsedOption{}
+
+	ok, e := parser.acceptSymbol(")")
+	if e != nil {
+		return nil, e
+	}
+	for !ok {
+		name, e := parser.expectIdentifier("option name")
+		if e != nil {
+			return nil, e
+		}
+		e = parser.expectSymbol("=")
+		if e != nil {
+			return nil, e
+		}
+		value, e := parser.parseValue()
+		if e != nil {
+			return nil, e
+		}
+		options = append(options, ddlParsedOption{name: name, value: value})
+
+		separator, e := parser.next()
+		if e != nil {
+			return nil, e
+		}
+		ok = separator.isSymbol(")")
+		if !ok && !separator.isSymbol(",") {
+			return nil, parser.errorf(separator, "expected , or ), got %s", separator)
+		}
+	}
+
+	return options, nil
+}
+
+func (parser *ddlParser) parseColumnOptions(field *TableFieldSchema) *errortools.Error {
+	options, e := parser.parseOptionList()
+	if e != nil {
+		return e
+	}
+
+	for _, option := range options {
+		if !option.name.isKeyword("description") {
+			return parser.errorf(option.name, "unsupported column option %s", option.name.text)
+		}
+		if option.value.kind == ddlValueNull {
+			continue
+		}
+		description, e := option.value.stringValue()
+		if e != nil {
+			return parser.errorf(option.value.token, "description: %s", e.Message())
+		}
+		field.Description = description
+	}
+
+	return nil
+}
+
+// parseOptions parses the optional OPTIONS of a table or view into table
+func (parser *ddlParser) parseOptions(table *Table) *errortools.Error {
+	token, e := parser.peek()
+	if e != nil {
+		return e
+	}
+	if !token.isKeyword("OPTIONS") {
+		return nil
+	}
+
+	options, e := parser.parseOptionList()
+	if e != nil {
+		return e
+	}
+
+	for _, option := range options {
+		name := strings.ToLower(option.name.text)
+		value := option.value
+
+		if value.kind == ddlValueNull {
+			// NULL is the default of every option
+			continue
+		}
+
+		supported := true
+		switch name {
+		case "description", "friendly_name", "labels", "expiration_timestamp":
+		case "partition_expiration_days", "require_partition_filter", "kms_key_name":
+			supported = table.Type != TableTypeView
+		case "enable_refresh", "refresh_interval_minutes":
+			supported = table.Type == TableTypeMaterializedView
+		default:
+			supported = false
+		}
+		if !supported {
+			return parser.errorf(option.name, "unsupported option %s", option.name.text)
+		}
+
+		switch name {
+		case "description":
+			description, e := value.stringValue()
+			if e != nil {
+				return parser.errorf(value.token, "%s: %s", name, e.Message())
+			}
+			table.Description = &description
+		case "friendly_name":
+			friendlyName, e := value.stringValue()
+			if e != nil {
+				return parser.errorf(value.token, "%s: %s", name, e.Message())
+			}
+			table.FriendlyName = &friendlyName
+		case "labels":
+			if value.kind != ddlValueArray {
+				return parser.errorf(value.token, "labels: expected an array of (key, value) pairs")
+			}
+			labels := map[string]string{}
+			for _, label := range value.values {
+				if label.kind != ddlValueTuple || len(label.values) != 2 || label.values[0].kind != ddlValueString || label.values[1].kind != ddlValueString {
+					return parser.errorf(label.token, "labels: expected a (key, value) pair of strings")
+				}
+				labels[label.values[0].text] = label.values[1].text
+			}
+			table.Labels = &labels
+		case "expiration_timestamp":
+			expiration, e := value.timestampValue()
+			if e != nil {
+				return parser.errorf(value.token, "%s: %s", name, e.Message())
+			}
+			expirationTime := go_types.Int64String(expiration.UnixMilli())
+			table.ExpirationTime = &expirationTime
+		case "partition_expiration_days":
+			if table.TimePartitioning == nil {
+				return parser.errorf(option.name, "%s requires time partitioning", name)
+			}
+			days, e := value.floatValue()
+			if e != nil {
+				return parser.errorf(value.token, "%s: %s", name, e.Message())
+			}
+			expirationMS := go_types.Int64String(math.Round(days * float64(24*time.Hour/time.Millisecond)))
+			table.TimePartitioning.ExpirationMS = &expirationMS
+		case "require_partition_filter":
+			requirePartitionFilter, e := value.boolValue()
+			if e != nil {
+				return parser.errorf(value.token, "%s: %s", name, e.Message())
+			}
+			table.RequirePartitionFilter = &requirePartitionFilter
+		case "kms_key_name":
+			kmsKeyName, e := value.stringValue()
+			if e != nil {
+				return parser.errorf(value.token, "%s: %s", name, e.Message())
+			}
+			table.EncryptionConfiguration = &EncryptionConfiguration{KMSKeyName: kmsKeyName}
+		case "enable_refresh":
+			enableRefresh, e := value.boolValue()
+			if e != nil {
+				return parser.errorf(value.token, "%s: %s", name, e.Message())
+			}
+			table.MaterializedView.EnableRefresh = &enableRefresh
+		case "refresh_interval_minutes":
+			minutes, e := value.floatValue()
+			if e != nil {
+				return parser.errorf(value.token, "%s: %s", name, e.Message())
+			}
+			refreshIntervalMS := go_types.Int64String(math.Round(minutes * float64(time.Minute/time.Millisecond)))
+			table.MaterializedView.RefreshIntervalMS = &refreshIntervalMS
+		}
+	}
+
+	return nil
+}
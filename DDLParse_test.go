@@ -0,0 +1,223 @@
+package googlebigquery
+
+import (
+	"testing"
+
+	go_types "github.com/leapforce-libraries/go_types"
+)
+
+func TestParseDDLRoundTrip(t *testing.T) {
+	description := "orders, \"quoted\" and 'single'\nsecond line"
+	friendlyName := "Orders"
+	requirePartitionFilter := true
+	enableRefresh := true
+	refreshInterval := go_types.Int64String(30 * 60 * 1000)
+	date := "date"
+
+	for _, table := range []Table{
+		{
+			TableReference: TableReference{ProjectID: "project", DatasetID: "dataset", TableID: "orders"},
+			Type:           TableTypeTable,
+			Description:    &description,
+			FriendlyName:   &friendlyName,
+			Labels:         &map[string]string{"team": "sales"},
+			Schema: &TableSchema{Fields: []TableFieldSchema{
+				{Name: "id", Type: "INTEGER", Mode: "REQUIRED", Description: "the order id"},
+				{Name: "date", Type: "DATE", Mode: "NULLABLE"},
+				{Name: "tags", Type: "STRING", Mode: "REPEATED"},
+				{Name: "customer", Type: "RECORD", Mode: "NULLABLE", Fields: []TableFieldSchema{
+					{Name: "name", Type: "STRING", Mode: "NULLABLE"},
+					{Name: "addresses", Type: "RECORD", Mode: "REPEATED", Fields: []TableFieldSchema{
+						{Name: "city", Type: "STRING", Mode: "NULLABLE"},
+					}},
+				}},
+				{Name: "amount", Type: "NUMERIC", Mode: "NULLABLE"},
+			}},
+			TimePartitioning:       &TimePartitioning{Type: "MONTH", Field: &date},
+			Clustering:             &Clustering{Fields: []string{"id"}},
+			RequirePartitionFilter: &requirePartitionFilter,
+		},
+		{
+			TableReference: TableReference{DatasetID: "dataset", TableID: "orders_view"},
+			Type:           TableTypeView,
+			Schema: &TableSchema{Fields: []TableFieldSchema{
+				{Name: "id", Description: "the order id"},
+			}},
+			View: &ViewDefinition{Query: "SELECT id\nFROM `dataset.orders`\nWHERE note = ';' -- keep the terminator out"},
+		},
+		{
+			TableReference: TableReference{ProjectID: "project", DatasetID: "dataset", TableID: "orders_per_day"},
+			Type:           TableTypeMaterializedView,
+			Clustering:     &Clustering{Fields: []string{"date"}},
+			MaterializedView: &MaterializedViewDefinition{
+				Query:             "SELECT date, COUNT(*) AS orders FROM `project.dataset.orders` GROUP BY date",
+				EnableRefresh:     &enableRefresh,
+				RefreshIntervalMS: &refreshInterval,
+			},
+		},
+	} {
+		t.Run(table.TableReference.TableID, func(t *testing.T) {
+			ddl, e := TableDDL(&table)
+			if e != nil {
+				t.Fatal(e.Message())
+			}
+
+			parsed, e := ParseDDL(ddl)
+			if e != nil {
+				t.Fatalf("%s\n%s", e.Message(), ddl)
+			}
+			if parsed.Type != table.Type {
+				t.Errorf("Type = %s, want %s", parsed.Type, table.Type)
+			}
+			if parsed.TableReference != table.TableReference {
+				t.Errorf("TableReference = %+v, want %+v", parsed.TableReference, table.TableReference)
+			}
+
+			reparsed, e := TableDDL(parsed)
+			if e != nil {
+				t.Fatal(e.Message())
+			}
+			if reparsed != ddl {
+				t.Errorf("got\n%s\nwant\n%s", reparsed, ddl)
+			}
+		})
+	}
+}
+
+func TestParsePartitionExpression(t *testing.T) {
+	columns := "(d DATE, ts TIMESTAMP, dt DATETIME, n INT64)"
+
+	for _, test := range []struct {
+		expression    string
+		wantType      string // empty for range partitioning
+		wantField     string
+		wantRangeSpec string
+	}{
+		{"_PARTITIONDATE", "DAY", "", ""},
+		{"DATE(_PARTITIONTIME)", "DAY", "", ""},
+		{"TIMESTAMP_TRUNC(_PARTITIONTIME, HOUR)", "HOUR", "", ""},
+		{"TIMESTAMP_TRUNC(_PARTITIONTIME, MONTH)", "MONTH", "", ""},
+		{"d", "DAY", "d", ""},
+		{"`d`", "DAY", "d", ""},
+		{"DATE_TRUNC(d, MONTH)", "MONTH", "d", ""},
+		{"DATE_TRUNC(d, YEAR)", "YEAR", "d", ""},
+		{"DATE(ts)", "DAY", "ts", ""},
+		{"TIMESTAMP_TRUNC(ts, HOUR)", "HOUR", "ts", ""},
+		{"TIMESTAMP_TRUNC(ts, DAY)", "DAY", "ts", ""},
+		{"DATE(dt)", "DAY", "dt", ""},
+		{"DATETIME_TRUNC(dt, YEAR)", "YEAR", "dt", ""},
+		{"RANGE_BUCKET(n, GENERATE_ARRAY(-10, 100, 10))", "", "n", "-10,100,10"},
+	} {
+		table, e := ParseDDL("CREATE TABLE t " + columns + " PARTITION BY " + test.expression)
+		if e != nil {
+			t.Errorf("%s: %s", test.expression, e.Message())
+			continue
+		}
+
+		if test.wantType == "" {
+			r := table.RangePartitioning
+			if r == nil || r.Field != test.wantField || r.Range.Start+","+r.Range.End+","+r.Range.Interval != test.wantRangeSpec {
+				t.Errorf("%s: RangePartitioning = %+v", test.expression, r)
+			}
+			continue
+		}
+
+		p := table.TimePartitioning
+		if p == nil || p.Type != test.wantType {
+			t.Errorf("%s: TimePartitioning = %+v, want type %s", test.expression, p, test.wantType)
+			continue
+		}
+		field := ""
+		if p.Field != nil {
+			field = *p.Field
+		}
+		if field != test.wantField {
+			t.Errorf("%s: Field = %q, want %q", test.expression, field, test.wantField)
+		}
+	}
+}
+
+func TestParseDDLStrings(t *testing.T) {
+	for _, test := range []struct {
+		literal string
+		want    string
+	}{
+		{`"plain"`, "plain"},
+		{`'single'`, "single"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`'it\'s'`, "it's"},
+		{`"tab\tnewline\n"`, "tab\tnewline\n"},
+		{`"back\\slash"`, `back\slash`},
+		{`"\x41é\U0001F600"`, "Aé\U0001F600"},
+		{`"\101"`, "A"},
+		{`"\?\` + "`" + `"`, "?`"},
+		{`r"raw\n"`, `raw\n`},
+		{`R'raw\'s'`, `raw\'s`},
+		{`"""triple "quoted" string"""`, `triple "quoted" string`},
+		{"'''multi\nline'''", "multi\nline"},
+		{`r"""raw\ttriple"""`, `raw\ttriple`},
+	} {
+		table, e := ParseDDL("CREATE TABLE t (x INT64) OPTIONS(description=" + test.literal + ")")
+		if e != nil {
+			t.Errorf("%s: %s", test.literal, e.Message())
+			continue
+		}
+		if table.Description == nil || *table.Description != test.want {
+			t.Errorf("%s: Description = %v, want %q", test.literal, table.Description, test.want)
+		}
+	}
+}
+
+func TestParseDDLErrors(t *testing.T) {
+	for _, test := range []struct {
+		ddl  string
+		want string
+	}{
+		{"CREATE TEMP TABLE t (x INT64)", "line 1, column 8: temp tables are not supported"},
+		{"CREATE EXTERNAL TABLE t (x INT64)", "line 1, column 8: external tables are not supported"},
+		{"CREATE TABLE t AS SELECT 1", "line 1, column 16: CREATE TABLE AS SELECT is not supported"},
+		{"CREATE TABLE t\n(\n  x INT64 DEFAULT 1\n)", "line 3, column 11: DEFAULT of column x is not supported"},
+		{"CREATE TABLE t (x STRING COLLATE 'und:ci')", "line 1, column 26: COLLATE of column x is not supported"},
+		{"CREATE TABLE t (x INT64)\nPARTITION BY x", "line 2, column 14: partitioning column x must be of type DATE"},
+		{"CREATE TABLE t (x INT64) OPTIONS(foo=1)", "line 1, column 34: unsupported option foo"},
+		{"CREATE TABLE t (x STRING OPTIONS(description=\"open", "line 1, column 46: unterminated string"},
+		{"CREATE VIEW v AS ;", "line 1, column 18: expected query, got ;"},
+		{"CREATE VIEW v AS SELECT 1;\nSELECT 2", "line 2, column 1: unexpected SELECT"},
+	} {
+		_, e := ParseDDL(test.ddl)
+		if e == nil {
+			t.Errorf("%q: expected an error", test.ddl)
+			continue
+		}
+		if e.Message() != test.want {
+			t.Errorf("%q: error %q, want %q", test.ddl, e.Message(), test.want)
+		}
+	}
+}
+
+func TestParseDDLQueryTerminator(t *testing.T) {
+	for _, test := range []struct {
+		ddl  string
+		want string
+	}{
+		{"CREATE VIEW v AS SELECT 1", "SELECT 1"},
+		{"CREATE VIEW v AS SELECT 1;", "SELECT 1"},
+		{"CREATE VIEW v AS SELECT 1; -- done", "SELECT 1"},
+		{"CREATE VIEW v AS SELECT 1;\n/* done */\n# really", "SELECT 1"},
+		{"CREATE VIEW v AS SELECT 1 -- last line\n;", "SELECT 1 -- last line"},
+		{"CREATE VIEW v AS SELECT ';' AS x, `a;b` /* ; */;", "SELECT ';' AS x, `a;b` /* ; */"},
+	} {
+		table, e := ParseDDL(test.ddl)
+		if e != nil {
+			t.Errorf("%q: %s", test.ddl, e.Message())
+			continue
+		}
+		if table.View == nil {
+			t.Errorf("%q: not parsed as a view", test.ddl)
+			continue
+		}
+		if table.View.Query != test.want {
+			t.Errorf("%q: query %q, want %q", test.ddl, table.View.Query, test.want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package googlebigquery
+
+import "testing"
+
+func TestExternalTableDDL(t *testing.T) {
+	connectionId := "project.eu.connection"
+	sourceFormat := "PARQUET"
+	sourceURIPrefix := "gs://bucket/table"
+
+	ddl, e := TableDDL(&Table{
+		TableReference: TableReference{ProjectID: "project", DatasetID: "dataset", TableID: "table"},
+		Schema: &TableSchema{Fields: []TableFieldSchema{
+			{Name: "id", Type: "INTEGER"},
+			{Name: "date", Type: "DATE"},
+		}},
+		ExternalDataConfiguration: &ExternalDataConfiguration{
+			SourceURIs:   []string{"gs://bucket/table/*"},
+			SourceFormat: &sourceFormat,
+			ConnectionId: &connectionId,
+			HivePartitioningOptions: &HivePartitioningOptions{
+				SourceURIPrefix: &sourceURIPrefix,
+				Fields:          &[]string{"date"},
+			},
+		},
+	})
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+
+	want := "CREATE EXTERNAL TABLE `project.dataset.table`\n" +
+		"(\n" +
+		"  `id` INT64\n" +
+		")\n" +
+		"WITH CONNECTION `project.eu.connection`\n" +
+		"WITH PARTITION COLUMNS\n" +
+		"(\n" +
+		"  `date` DATE\n" +
+		")\n" +
+		"OPTIONS(\n" +
+		"  format=\"PARQUET\",\n" +
+		"  uris=[\"gs://bucket/table/*\"],\n" +
+		"  hive_partition_uri_prefix=\"gs://bucket/table\"\n" +
+		");\n"
+	if ddl != want {
+		t.Errorf("got\n%s\nwant\n%s", ddl, want)
+	}
+}
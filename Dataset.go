@@ -1,6 +1,7 @@
 package googlebigquery
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -54,14 +55,52 @@ type GetDatasetsConfig struct {
 	All        *bool
 	Filter     *string
 	MaxResults *int
+	MaxItems   *int
 	PageToken  *string
 }
 
 func (service *Service) GetDatasets(config *GetDatasetsConfig) (*[]Dataset, *errortools.Error) {
+	return service.GetDatasetsWithContext(context.Background(), config)
+}
+
+func (service *Service) GetDatasetsWithContext(ctx context.Context, config *GetDatasetsConfig) (*[]Dataset, *errortools.Error) {
+	return service.DatasetsPagerWithContext(ctx, config).All()
+}
+
+// IterateDatasets returns an iterator that fetches the datasets page by page
+func (service *Service) IterateDatasets(config *GetDatasetsConfig) *Iterator[Dataset] {
+	return service.IterateDatasetsWithContext(context.Background(), config)
+}
+
+func (service *Service) IterateDatasetsWithContext(ctx context.Context, config *GetDatasetsConfig) *Iterator[Dataset] {
+	return service.DatasetsPagerWithContext(ctx, config).Iterator()
+}
+
+func (service *Service) DatasetsPager(config *GetDatasetsConfig) *Pager[Dataset] {
+	return service.DatasetsPagerWithContext(context.Background(), config)
+}
+
+func (service *Service) DatasetsPagerWithContext(ctx context.Context, config *GetDatasetsConfig) *Pager[Dataset] {
 	if config == nil {
-		return nil, errortools.ErrorMessage("GetDatasetsConfig must not be a nil pointer")
+		return newErrorPager[Dataset](errortools.ErrorMessage("GetDatasetsConfig must not be a nil pointer"))
+	}
+
+	pagerConfig := PagerConfig{
+		PageToken: config.PageToken,
+		PageSize:  config.MaxResults,
+		MaxItems:  config.MaxItems,
 	}
 
+	return NewPager(ctx, &pagerConfig, func(ctx context.Context, pageToken *string, pageSize *int) ([]Dataset, *string, *errortools.Error) {
+		datasetsResponse, e := service.getDatasetsPage(ctx, config, pageToken, pageSize)
+		if e != nil {
+			return nil, nil, e
+		}
+		return datasetsResponse.Datasets, datasetsResponse.NextPageToken, nil
+	})
+}
+
+func (service *Service) getDatasetsPage(ctx context.Context, config *GetDatasetsConfig, pageToken *string, pageSize *int) (*DatasetsResponse, *errortools.Error) {
 	values := url.Values{}
 
 	if config.All != nil {
@@ -70,43 +109,27 @@ func (service *Service) GetDatasets(config *GetDatasetsConfig) (*[]Dataset, *err
 	if config.Filter != nil {
 		values.Set("filter", *config.Filter)
 	}
-	if config.MaxResults != nil {
-		values.Set("maxResults", fmt.Sprintf("%v", *config.MaxResults))
-	}
-	pageToken := config.PageToken
-
-	datasets := []Dataset{}
 
-	for {
-		if pageToken != nil {
-			values.Set("pageToken", *pageToken)
-		}
-
-		datasetsReponse := DatasetsResponse{}
-
-		requestConfig := go_http.RequestConfig{
-			Method:        http.MethodGet,
-			Url:           service.url(fmt.Sprintf("projects/%s/datasets?%s", config.ProjectId, values.Encode())),
-			ResponseModel: &datasetsReponse,
-		}
-		_, _, e := service.googleService.HttpRequest(&requestConfig)
-		if e != nil {
-			return nil, e
-		}
-
-		datasets = append(datasets, datasetsReponse.Datasets...)
+	if pageSize != nil {
+		values.Set("maxResults", fmt.Sprintf("%v", *pageSize))
+	}
+	if pageToken != nil {
+		values.Set("pageToken", *pageToken)
+	}
 
-		if config.PageToken != nil {
-			break
-		}
-		if datasetsReponse.NextPageToken == nil {
-			break
-		}
+	datasetsResponse := DatasetsResponse{}
 
-		pageToken = datasetsReponse.NextPageToken
+	requestConfig := go_http.RequestConfig{
+		Method:        http.MethodGet,
+		Url:           service.url(fmt.Sprintf("projects/%s/datasets?%s", config.ProjectId, values.Encode())),
+		ResponseModel: &datasetsResponse,
+	}
+	_, _, e := service.httpRequest(ctx, OperationDatasetsList, &requestConfig)
+	if e != nil {
+		return nil, e
 	}
 
-	return &datasets, nil
+	return &datasetsResponse, nil
 }
 
 type GetDatasetConfig struct {
@@ -115,6 +138,10 @@ type GetDatasetConfig struct {
 }
 
 func (service *Service) GetDataset(config *GetDatasetConfig) (*Dataset, *errortools.Error) {
+	return service.GetDatasetWithContext(context.Background(), config)
+}
+
+func (service *Service) GetDatasetWithContext(ctx context.Context, config *GetDatasetConfig) (*Dataset, *errortools.Error) {
 	if config == nil {
 		return nil, errortools.ErrorMessage("GetDatasetsConfig must not be a nil pointer")
 	}
@@ -126,7 +153,7 @@ func (service *Service) GetDataset(config *GetDatasetConfig) (*Dataset, *errorto
 		Url:           service.url(fmt.Sprintf("projects/%s/datasets/%s", config.ProjectId, config.DatasetId)),
 		ResponseModel: &dataset,
 	}
-	_, _, e := service.googleService.HttpRequest(&requestConfig)
+	_, _, e := service.httpRequest(ctx, OperationDatasetsGet, &requestConfig)
 	if e != nil {
 		return nil, e
 	}
@@ -0,0 +1,172 @@
+package googlebigquery
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"unsafe"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+)
+
+// Error is a typed BigQuery error.
+type Error struct {
+	StatusCode int    // http status code, 0 if no response was received
+	Status     string // canonical status, e.g. NOT_FOUND, if known
+	Message    string
+	Errors     []ErrorProto
+	Guardrail  *GuardrailError // set for reason ErrorReasonGuardrail
+	e          *errortools.Error
+}
+
+// errorRegistry holds the *Error per *errortools.Error it was created for, keyed by address so that
+// the entry does not keep the *errortools.Error alive. The entry is removed when it is collected.
+var errorRegistry sync.Map
+
+// setError keeps err next to e, see AsError
+func setError(e *errortools.Error, err *Error) {
+	key := uintptr(unsafe.Pointer(e))
+	if _, loaded := errorRegistry.Swap(key, *err); !loaded {
+		runtime.SetFinalizer(e, func(*errortools.Error) { errorRegistry.Delete(key) })
+	}
+}
+
+// AsError returns the BigQuery error that e was created for, or nil if e was not caused by an error response,
+// e.g. because no response was received. It is safe to call for any error returned by one of the Service methods,
+// also when requests run concurrently.
+func AsError(e *errortools.Error) *Error {
+	if e == nil {
+		return nil
+	}
+
+	value, ok := errorRegistry.Load(uintptr(unsafe.Pointer(e)))
+	if !ok {
+		return nil
+	}
+
+	err := value.(Error)
+	err.e = e
+
+	return &err
+}
+
+// NewError converts an error returned by one of the Service methods into an *Error.
+// The error reasons are only known if e was caused by an error response, see AsError.
+func NewError(e *errortools.Error) *Error {
+	if e == nil {
+		return nil
+	}
+
+	if err := AsError(e); err != nil {
+		return err
+	}
+
+	err := Error{
+		Message: e.Message(),
+		e:       e,
+	}
+	if e.Response() != nil {
+		err.StatusCode = e.Response().StatusCode
+	}
+
+	return &err
+}
+
+// NewJobError returns the error a job finished with, or nil if the job did not fail.
+func NewJobError(job *Job) *Error {
+	if job == nil || job.Status.ErrorResult == nil {
+		return nil
+	}
+
+	err := Error{
+		Message: job.Status.ErrorResult.Message,
+		Errors:  []ErrorProto{*job.Status.ErrorResult},
+	}
+	if job.Status.Errors != nil {
+		for _, errorProto := range *job.Status.Errors {
+			if errorProto != *job.Status.ErrorResult {
+				err.Errors = append(err.Errors, errorProto)
+			}
+		}
+	}
+
+	return &err
+}
+
+func (err *Error) Error() string {
+	if err == nil {
+		return "googlebigquery: <nil>"
+	}
+	reason := err.Reason()
+	if reason == "" {
+		return fmt.Sprintf("googlebigquery: %s", err.Message)
+	}
+	if err.StatusCode == 0 {
+		return fmt.Sprintf("googlebigquery: %s: %s", reason, err.Message)
+	}
+	return fmt.Sprintf("googlebigquery: %s (%v): %s", reason, err.StatusCode, err.Message)
+}
+
+// ErrortoolsError returns the underlying *errortools.Error, if any
+func (err *Error) ErrortoolsError() *errortools.Error {
+	if err == nil {
+		return nil
+	}
+	return err.e
+}
+
+// Reason returns the reason of the first error entry
+func (err *Error) Reason() string {
+	if err == nil || len(err.Errors) == 0 {
+		return ""
+	}
+	return err.Errors[0].Reason
+}
+
+func (err *Error) HasReason(reason string) bool {
+	if err == nil {
+		return false
+	}
+	for _, errorProto := range err.Errors {
+		if errorProto.Reason == reason {
+			return true
+		}
+	}
+	return false
+}
+
+func (err *Error) IsNotFound() bool {
+	return err.HasReason(ErrorReasonNotFound)
+}
+
+func (err *Error) IsAlreadyExists() bool {
+	return err.HasReason(ErrorReasonDuplicate)
+}
+
+func (err *Error) IsQuotaExceeded() bool {
+	return err.HasReason(ErrorReasonQuotaExceeded) || err.HasReason(ErrorReasonRateLimitExceeded) || err.HasReason(ErrorReasonJobRateLimitExceeded)
+}
+
+func (err *Error) IsAccessDenied() bool {
+	return err.HasReason(ErrorReasonAccessDenied) || err.HasReason(ErrorReasonUnauthorized)
+}
+
+// IsNotFound reports whether e, as returned by one of the Service methods, was caused by a notFound error
+func IsNotFound(e *errortools.Error) bool {
+	return AsError(e).IsNotFound()
+}
+
+// IsAlreadyExists reports whether e, as returned by one of the Service methods, was caused by a duplicate error
+func IsAlreadyExists(e *errortools.Error) bool {
+	return AsError(e).IsAlreadyExists()
+}
+
+// IsQuotaExceeded reports whether e, as returned by one of the Service methods, was caused by exceeding a quota or rate limit
+func IsQuotaExceeded(e *errortools.Error) bool {
+	return AsError(e).IsQuotaExceeded()
+}
+
+// IsAccessDenied reports whether e, as returned by one of the Service methods, was caused by missing permissions
+func IsAccessDenied(e *errortools.Error) bool {
+	return AsError(e).IsAccessDenied()
+}
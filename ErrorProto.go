@@ -6,3 +6,31 @@ type ErrorProto struct {
 	DebugInfo string `json:"debugInfo"`
 	Message   string `json:"message"`
 }
+
+// BigQuery error reasons as documented at https://cloud.google.com/bigquery/docs/error-messages
+const (
+	ErrorReasonAccessDenied             string = "accessDenied"
+	ErrorReasonBackendError             string = "backendError"
+	ErrorReasonBillingNotEnabled        string = "billingNotEnabled"
+	ErrorReasonBillingTierLimitExceeded string = "billingTierLimitExceeded"
+	ErrorReasonBlocked                  string = "blocked"
+	ErrorReasonDuplicate                string = "duplicate"
+	ErrorReasonInternalError            string = "internalError"
+	ErrorReasonInvalid                  string = "invalid"
+	ErrorReasonInvalidQuery             string = "invalidQuery"
+	ErrorReasonInvalidUser              string = "invalidUser"
+	ErrorReasonJobBackendError          string = "jobBackendError"
+	ErrorReasonJobInternalError         string = "jobInternalError"
+	ErrorReasonJobRateLimitExceeded     string = "jobRateLimitExceeded"
+	ErrorReasonNotFound                 string = "notFound"
+	ErrorReasonNotImplemented           string = "notImplemented"
+	ErrorReasonQuotaExceeded            string = "quotaExceeded"
+	ErrorReasonRateLimitExceeded        string = "rateLimitExceeded"
+	ErrorReasonResourceInUse            string = "resourceInUse"
+	ErrorReasonResourcesExceeded        string = "resourcesExceeded"
+	ErrorReasonResponseTooLarge         string = "responseTooLarge"
+	ErrorReasonStopped                  string = "stopped"
+	ErrorReasonTableUnavailable         string = "tableUnavailable"
+	ErrorReasonTimeout                  string = "timeout"
+	ErrorReasonUnauthorized             string = "unauthorized"
+)
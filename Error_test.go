@@ -0,0 +1,105 @@
+package googlebigquery
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	google "github.com/leapforce-libraries/go_google"
+	go_http "github.com/leapforce-libraries/go_http"
+	tokenfixed "github.com/leapforce-libraries/go_oauth2/tokenfixed"
+)
+
+func newTestService(t *testing.T) *Service {
+	tokenSource, e := tokenfixed.NewTokenFixed("token")
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+
+	service, e := NewServiceWithOAuth2(&google.ServiceWithOAuth2Config{
+		ClientId:    "test",
+		TokenSource: tokenSource,
+	})
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+	return service
+}
+
+func TestErrorResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":{"code":404,"message":"Not found: Table p:d.t","status":"NOT_FOUND","errors":[{"reason":"notFound","message":"Not found: Table p:d.t"}]}}`))
+	}))
+	defer server.Close()
+
+	service := newTestService(t)
+	_, _, e := service.httpRequest(context.Background(), OperationTablesGet, &go_http.RequestConfig{
+		Method: http.MethodGet,
+		Url:    server.URL,
+	})
+	if e == nil {
+		t.Fatal("expected an error")
+	}
+	if e.Message() != "Not found: Table p:d.t" {
+		t.Errorf("Message() = %q, want the message of the error response", e.Message())
+	}
+
+	err := AsError(e)
+	if err == nil {
+		t.Fatal("AsError returned nil for an error response")
+	}
+	if err.StatusCode != http.StatusNotFound || err.Status != "NOT_FOUND" || err.Reason() != ErrorReasonNotFound {
+		t.Errorf("got %+v", err)
+	}
+	if err.ErrortoolsError() != e {
+		t.Error("ErrortoolsError does not return the error AsError was called for")
+	}
+	if !IsNotFound(e) || IsAlreadyExists(e) {
+		t.Error("IsNotFound or IsAlreadyExists reports the wrong reason")
+	}
+}
+
+func TestNetworkError(t *testing.T) {
+	// a closed port, so that the request fails without a response
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + listener.Addr().String()
+	listener.Close()
+
+	service := newTestService(t)
+	_, _, e := service.httpRequest(context.Background(), OperationTablesGet, &go_http.RequestConfig{
+		Method: http.MethodGet,
+		Url:    url,
+	})
+	if e == nil {
+		t.Fatal("expected an error")
+	}
+
+	if AsError(e) != nil {
+		t.Errorf("AsError = %+v, want nil for a network error", AsError(e))
+	}
+	if IsNotFound(e) || IsAlreadyExists(e) || IsQuotaExceeded(e) || IsAccessDenied(e) {
+		t.Error("a network error reports an error reason")
+	}
+
+	networkErr := NewError(e)
+	if networkErr == nil || networkErr.StatusCode != 0 || networkErr.Reason() != "" {
+		t.Errorf("NewError = %+v, want an error without status code and reason", networkErr)
+	}
+}
+
+func TestNilError(t *testing.T) {
+	var err *Error
+	if err.IsNotFound() || err.HasReason(ErrorReasonNotFound) || err.Reason() != "" || err.ErrortoolsError() != nil {
+		t.Error("a nil *Error reports an error")
+	}
+	if AsError(nil) != nil || IsNotFound(nil) {
+		t.Error("a nil *errortools.Error reports an error")
+	}
+}
@@ -0,0 +1,137 @@
+package googlebigquery
+
+import (
+	"context"
+	"strings"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+)
+
+const (
+	bytesPerMiB int64 = 1 << 20
+	bytesPerTiB int64 = 1 << 40
+	// on-demand queries are billed for at least 10 MiB per referenced table
+	minimumBilledBytesPerTable int64   = 10 * bytesPerMiB
+	defaultPricePerTiB         float64 = 6.25
+)
+
+// OnDemandPricing holds the on-demand query price per TiB in USD, by location
+type OnDemandPricing struct {
+	Default float64
+	// Locations overrules Default per location, e.g. "europe-west4", keys are case-insensitive
+	Locations map[string]float64
+}
+
+func (pricing *OnDemandPricing) PricePerTiB(location string) float64 {
+	for l, price := range pricing.Locations {
+		if strings.EqualFold(l, location) {
+			return price
+		}
+	}
+	return pricing.Default
+}
+
+// SetOnDemandPricing sets the prices EstimateQuery calculates the cost with,
+// by default every location is priced at 6.25 USD per TiB
+func (service *Service) SetOnDemandPricing(pricing *OnDemandPricing) {
+	service.onDemandPricing = pricing
+}
+
+func (service *Service) pricePerTiB(location string) float64 {
+	if service.onDemandPricing == nil {
+		return defaultPricePerTiB
+	}
+	return service.onDemandPricing.PricePerTiB(location)
+}
+
+type EstimateQueryConfig struct {
+	ProjectId string
+	Location  *string
+	Query     JobConfigurationQuery
+}
+
+type QueryEstimate struct {
+	TotalBytesProcessed int64
+	// BilledBytes applies the on-demand rounding and minimum to TotalBytesProcessed
+	BilledBytes      int64
+	ReferencedTables []TableReference
+	Schema           *TableSchema
+	Location         string
+	PricePerTiB      float64
+	EstimatedCost    float64
+}
+
+// ExceedsBytes reports whether the query would process more than maxBytes
+func (estimate *QueryEstimate) ExceedsBytes(maxBytes int64) bool {
+	return estimate.TotalBytesProcessed > maxBytes
+}
+
+// EstimateQuery dry-runs the query and estimates its on-demand cost.
+// A dry run is free and does not start a job.
+func (service *Service) EstimateQuery(config *EstimateQueryConfig) (*QueryEstimate, *errortools.Error) {
+	return service.EstimateQueryWithContext(context.Background(), config)
+}
+
+func (service *Service) EstimateQueryWithContext(ctx context.Context, config *EstimateQueryConfig) (*QueryEstimate, *errortools.Error) {
+	if config == nil {
+		return nil, errortools.ErrorMessage("EstimateQueryConfig must not be a nil pointer")
+	}
+
+	dryRun := true
+	query := config.Query
+
+	job, e := service.InsertJobWithContext(ctx, &InsertJobConfig{
+		ProjectId: config.ProjectId,
+		Location:  config.Location,
+		Configuration: JobConfiguration{
+			Query:  &query,
+			DryRun: &dryRun,
+		},
+	})
+	if e != nil {
+		return nil, e
+	}
+	if err := NewJobError(job); err != nil {
+		return nil, errortools.ErrorMessage(err)
+	}
+
+	estimate := QueryEstimate{
+		Location: job.JobReference.Location,
+	}
+
+	statistics := job.Statistics.Query
+	if statistics != nil {
+		if statistics.TotalBytesProcessed != nil {
+			estimate.TotalBytesProcessed = statistics.TotalBytesProcessed.Value()
+		}
+		if statistics.ReferencedTables != nil {
+			estimate.ReferencedTables = *statistics.ReferencedTables
+		}
+		estimate.Schema = statistics.Schema
+	} else if job.Statistics.TotalBytesProcessed != nil {
+		estimate.TotalBytesProcessed = job.Statistics.TotalBytesProcessed.Value()
+	}
+
+	estimate.BilledBytes = billedBytes(estimate.TotalBytesProcessed, len(estimate.ReferencedTables))
+	estimate.PricePerTiB = service.pricePerTiB(estimate.Location)
+	estimate.EstimatedCost = float64(estimate.BilledBytes) / float64(bytesPerTiB) * estimate.PricePerTiB
+
+	return &estimate, nil
+}
+
+// billedBytes rounds up to the next MiB with a minimum of 10 MiB per referenced table.
+// The minimum actually applies to each table separately, so this is a lower bound.
+func billedBytes(bytesProcessed int64, referencedTables int) int64 {
+	if bytesProcessed == 0 {
+		return 0
+	}
+
+	if referencedTables < 1 {
+		referencedTables = 1
+	}
+	if minimum := minimumBilledBytesPerTable * int64(referencedTables); bytesProcessed < minimum {
+		bytesProcessed = minimum
+	}
+
+	return (bytesProcessed + bytesPerMiB - 1) / bytesPerMiB * bytesPerMiB
+}
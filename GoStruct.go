@@ -0,0 +1,321 @@
+package googlebigquery
+
+import (
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+)
+
+// GenerateGoStructs generates the source of a Go file in package packageName with a struct per table,
+// with bigquery and json tags so that the structs can be used to read and write rows.
+//
+// Structs are named after the dataset and the table, e.g. SalesOrders for sales.orders, and names that
+// collide are an error. NULLABLE fields get a nullable type, e.g. bigquery.NullString, REPEATED fields a slice
+// and RECORD fields a struct of their own, named after the struct and the field. NUMERIC and BIGNUMERIC fields
+// get the generated type Numeric, a big.Rat that marshals as a decimal string rather than as a fraction.
+// Tables are ordered by their full name and fields keep the order of the schema, so that the output only changes
+// if a schema changes.
+func GenerateGoStructs(packageName string, tables []Table) ([]byte, *errortools.Error) {
+	if packageName == "" {
+		return nil, errortools.ErrorMessage("packageName must not be empty")
+	}
+
+	sorted := make([]Table, len(tables))
+	copy(sorted, tables)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return goStructTableName(sorted[i].TableReference) < goStructTableName(sorted[j].TableReference)
+	})
+
+	generator := goStructGenerator{
+		structNames: map[string]bool{},
+		imports:     map[string]bool{},
+	}
+
+	for _, table := range sorted {
+		if table.Schema == nil {
+			return nil, errortools.ErrorMessagef("table %s has no schema", goStructTableName(table.TableReference))
+		}
+
+		name, e := generator.structName(table.TableReference.DatasetID + "_" + table.TableReference.TableID)
+		if e != nil {
+			return nil, errortools.ErrorMessagef("table %s: %s", goStructTableName(table.TableReference), e.Message())
+		}
+		comment := fmt.Sprintf("%s is a row of %s", name, goStructTableName(table.TableReference))
+
+		e = generator.writeStruct(name, comment, table.Schema.Fields)
+		if e != nil {
+			return nil, errortools.ErrorMessagef("table %s: %s", goStructTableName(table.TableReference), e.Message())
+		}
+	}
+
+	var source strings.Builder
+	source.WriteString("// Code generated from BigQuery table schemas. DO NOT EDIT.\n\n")
+	source.WriteString(fmt.Sprintf("package %s\n\n", packageName))
+
+	// standard library imports first, as goimports groups them
+	imports := [2][]string{}
+	for path := range generator.imports {
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			imports[1] = append(imports[1], path)
+		} else {
+			imports[0] = append(imports[0], path)
+		}
+	}
+	if len(imports[0])+len(imports[1]) > 0 {
+		source.WriteString("import (\n")
+		for i, group := range imports {
+			sort.Strings(group)
+			if i > 0 && len(group) > 0 && len(imports[0]) > 0 {
+				source.WriteString("\n")
+			}
+			for _, path := range group {
+				source.WriteString(fmt.Sprintf("\t%q\n", path))
+			}
+		}
+		source.WriteString(")\n")
+	}
+
+	source.WriteString(generator.structs.String())
+	if generator.numeric {
+		source.WriteString(goStructNumeric)
+	}
+
+	b, err := format.Source([]byte(source.String()))
+	if err != nil {
+		return nil, errortools.ErrorMessagef("generated source is invalid: %s", err.Error())
+	}
+
+	return b, nil
+}
+
+func goStructTableName(table TableReference) string {
+	return fmt.Sprintf("%s.%s.%s", table.ProjectID, table.DatasetID, table.TableID)
+}
+
+type goStructGenerator struct {
+	structs     strings.Builder
+	structNames map[string]bool
+	imports     map[string]bool
+	// numeric is set if a field has the type Numeric
+	numeric bool
+}
+
+// goStructNumeric is the source of the type of NUMERIC and BIGNUMERIC fields
+const goStructNumeric = `
+// Numeric is a NUMERIC or BIGNUMERIC value, a nil *Numeric is NULL.
+// Unlike big.Rat it marshals as a decimal string.
+type Numeric struct {
+	big.Rat
+}
+
+// AppendText appends the value with as many decimals as needed, up to the 38 decimals of BIGNUMERIC.
+// It replaces the AppendText of big.Rat, which encoding/json prefers over MarshalText.
+func (n *Numeric) AppendText(b []byte) ([]byte, error) {
+	scaled := new(big.Rat).Set(&n.Rat)
+	decimals := 0
+	for !scaled.IsInt() && decimals < 38 {
+		scaled.Mul(scaled, big.NewRat(10, 1))
+		decimals++
+	}
+	return append(b, n.FloatString(decimals)...), nil
+}
+
+func (n *Numeric) MarshalText() ([]byte, error) {
+	return n.AppendText(nil)
+}
+
+func (n *Numeric) UnmarshalText(text []byte) error {
+	if _, ok := n.SetString(string(text)); !ok {
+		return fmt.Errorf("invalid numeric value %q", text)
+	}
+	return nil
+}
+`
+
+// structName derives the name of a struct from name. Unlike a field name it is not numbered if it is taken,
+// since the number would depend on the other tables, so a name that is taken already is an error.
+func (generator *goStructGenerator) structName(name string) (string, *errortools.Error) {
+	structName := goName(name)
+	if generator.structNames[structName] {
+		return "", errortools.ErrorMessagef("struct name %s of %s is already taken", structName, name)
+	}
+	generator.structNames[structName] = true
+
+	return structName, nil
+}
+
+// writeStruct writes the struct and then the structs of its RECORD fields
+func (generator *goStructGenerator) writeStruct(name string, comment string, fields []TableFieldSchema) *errortools.Error {
+	type nestedStruct struct {
+		name   string
+		field  string
+		fields []TableFieldSchema
+	}
+	nestedStructs := []nestedStruct{}
+
+	var s strings.Builder
+	s.WriteString(fmt.Sprintf("\n// %s\ntype %s struct {\n", comment, name))
+
+	fieldNames := map[string]bool{}
+
+	for _, field := range fields {
+		nestedName := ""
+		if normalizedFieldType(field.Type) == "STRUCT" {
+			var e *errortools.Error
+			nestedName, e = generator.structName(name + "_" + field.Name)
+			if e != nil {
+				return e
+			}
+			nestedStructs = append(nestedStructs, nestedStruct{name: nestedName, field: field.Name, fields: field.Fields})
+		}
+
+		goType, e := generator.goType(field, nestedName)
+		if e != nil {
+			return e
+		}
+
+		if field.Description != "" {
+			for _, line := range strings.Split(strings.TrimSpace(field.Description), "\n") {
+				s.WriteString(strings.TrimRight("\t// "+line, " ") + "\n")
+			}
+		}
+		s.WriteString(fmt.Sprintf("\t%s %s `bigquery:%q json:%q`\n", goIdentifier(field.Name, fieldNames), goType, field.Name, field.Name))
+	}
+
+	s.WriteString("}\n")
+	generator.structs.WriteString(s.String())
+
+	for _, nested := range nestedStructs {
+		e := generator.writeStruct(nested.name, fmt.Sprintf("%s is the type of field %s of %s", nested.name, nested.field, name), nested.fields)
+		if e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+func (generator *goStructGenerator) goType(field TableFieldSchema, structName string) (string, *errortools.Error) {
+	nullable := true
+	prefix := ""
+	switch strings.ToUpper(field.Mode) {
+	case "REQUIRED":
+		nullable = false
+	case "REPEATED":
+		// BigQuery arrays cannot contain NULL
+		nullable = false
+		prefix = "[]"
+	}
+
+	goType := ""
+
+	switch normalizedFieldType(field.Type) {
+	case "STRING":
+		goType = generator.nullable(nullable, "string", "bigquery.NullString")
+	case "GEOGRAPHY":
+		goType = generator.nullable(nullable, "string", "bigquery.NullGeography")
+	case "JSON":
+		goType = generator.nullable(nullable, "string", "bigquery.NullJSON")
+	case "INT64":
+		goType = generator.nullable(nullable, "int64", "bigquery.NullInt64")
+	case "FLOAT64":
+		goType = generator.nullable(nullable, "float64", "bigquery.NullFloat64")
+	case "BOOL":
+		goType = generator.nullable(nullable, "bool", "bigquery.NullBool")
+	case "TIMESTAMP":
+		goType = generator.nullable(nullable, "time.Time", "bigquery.NullTimestamp")
+	case "DATE":
+		goType = generator.nullable(nullable, "civil.Date", "bigquery.NullDate")
+	case "TIME":
+		goType = generator.nullable(nullable, "civil.Time", "bigquery.NullTime")
+	case "DATETIME":
+		goType = generator.nullable(nullable, "civil.DateTime", "bigquery.NullDateTime")
+	case "BYTES":
+		// a nil slice is NULL
+		goType = "[]byte"
+	case "NUMERIC", "BIGNUMERIC":
+		// a nil pointer is NULL
+		if !generator.numeric {
+			if generator.structNames["Numeric"] {
+				return "", errortools.ErrorMessage("struct name Numeric is already taken")
+			}
+			generator.structNames["Numeric"] = true
+			generator.numeric = true
+		}
+		goType = "*Numeric"
+		generator.imports["fmt"] = true
+		generator.imports["math/big"] = true
+	case "INTERVAL":
+		goType = "*bigquery.IntervalValue"
+		generator.imports["cloud.google.com/go/bigquery"] = true
+	case "STRUCT":
+		goType = structName
+		if nullable {
+			goType = "*" + structName
+		}
+	default:
+		return "", errortools.ErrorMessagef("field %s has unsupported type %s", field.Name, field.Type)
+	}
+
+	return prefix + goType, nil
+}
+
+// nullable returns nullableType if nullable and goType otherwise, and adds the import of the returned type
+func (generator *goStructGenerator) nullable(nullable bool, goType string, nullableType string) string {
+	if nullable {
+		goType = nullableType
+	}
+
+	switch strings.Split(goType, ".")[0] {
+	case "bigquery":
+		generator.imports["cloud.google.com/go/bigquery"] = true
+	case "civil":
+		generator.imports["cloud.google.com/go/civil"] = true
+	case "time":
+		generator.imports["time"] = true
+	}
+
+	return goType
+}
+
+// goIdentifier derives a unique exported Go identifier from a name, numbering it if it is taken
+func goIdentifier(name string, names map[string]bool) string {
+	identifier := goName(name)
+
+	unique := identifier
+	for i := 2; names[unique]; i++ {
+		unique = fmt.Sprintf("%s%v", identifier, i)
+	}
+	names[unique] = true
+
+	return unique
+}
+
+// goName derives an exported Go identifier from a name, e.g. customer_id becomes CustomerId
+func goName(name string) string {
+	identifier := ""
+	upper := true
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z':
+			if upper {
+				r -= 'a' - 'A'
+			}
+			identifier += string(r)
+			upper = false
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			identifier += string(r)
+			upper = false
+		default:
+			upper = true
+		}
+	}
+	if identifier == "" || (identifier[0] >= '0' && identifier[0] <= '9') {
+		identifier = "X" + identifier
+	}
+
+	return identifier
+}
@@ -0,0 +1,40 @@
+package googlebigquery
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateGoStructsNumeric(t *testing.T) {
+	b, e := GenerateGoStructs("tables", []Table{{
+		TableReference: TableReference{ProjectID: "project", DatasetID: "sales", TableID: "orders"},
+		Schema: &TableSchema{Fields: []TableFieldSchema{
+			{Name: "amount", Type: "NUMERIC"},
+			{Name: "rates", Type: "BIGNUMERIC", Mode: "REPEATED"},
+		}},
+	}})
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+
+	source := string(b)
+	for _, want := range []string{
+		"Amount *Numeric   `bigquery:\"amount\" json:\"amount\"`",
+		"Rates  []*Numeric `bigquery:\"rates\" json:\"rates\"`",
+		"type Numeric struct {",
+		"func (n *Numeric) AppendText(b []byte) ([]byte, error) {",
+	} {
+		if !strings.Contains(source, want) {
+			t.Errorf("generated source lacks %s:\n%s", want, source)
+		}
+	}
+
+	// a table struct cannot take the name of the Numeric type
+	_, e = GenerateGoStructs("tables", []Table{
+		{TableReference: TableReference{TableID: "numeric"}, Schema: &TableSchema{Fields: []TableFieldSchema{{Name: "id", Type: "INTEGER"}}}},
+		{TableReference: TableReference{DatasetID: "sales", TableID: "orders"}, Schema: &TableSchema{Fields: []TableFieldSchema{{Name: "amount", Type: "NUMERIC"}}}},
+	})
+	if e == nil {
+		t.Error("expected an error for a table struct named Numeric")
+	}
+}
@@ -0,0 +1,173 @@
+package googlebigquery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+	go_types "github.com/leapforce-libraries/go_types"
+)
+
+// QueryGuardrails limit the cost of the query jobs inserted by the Service
+type QueryGuardrails struct {
+	// MaximumBytesBilled is set on each query job that does not set a lower maximum,
+	// BigQuery fails a job that would exceed it without charge
+	MaximumBytesBilled *int64
+	// DryRun dry-runs each query job before inserting it
+	DryRun bool
+	// MaxBytesProcessed refuses query jobs whose dry run processes more bytes, implies DryRun
+	MaxBytesProcessed *int64
+	// MaxEstimatedCost refuses query jobs with a higher estimated on-demand cost, implies DryRun
+	MaxEstimatedCost *float64
+	// RequirePartitionFilter returns the rejection by BigQuery of the dry run of a query that does not filter
+	// on the partitioning column of a table with RequirePartitionFilter set as a GuardrailError, implies DryRun.
+	// BigQuery itself enforces the filter, the guardrail makes the rejection typed and refuses the query before a job is inserted.
+	RequirePartitionFilter bool
+}
+
+func (guardrails *QueryGuardrails) dryRun() bool {
+	return guardrails.DryRun || guardrails.MaxBytesProcessed != nil || guardrails.MaxEstimatedCost != nil || guardrails.RequirePartitionFilter
+}
+
+type GuardrailViolation string
+
+const (
+	GuardrailViolationBytesProcessed  GuardrailViolation = "bytesProcessed"
+	GuardrailViolationEstimatedCost   GuardrailViolation = "estimatedCost"
+	GuardrailViolationPartitionFilter GuardrailViolation = "partitionFilter"
+)
+
+// ErrorReasonGuardrail is the reason of the *Error for a query job that InsertJob refused because of the QueryGuardrails,
+// its Guardrail field holds the violation
+const ErrorReasonGuardrail string = "guardrail"
+
+// GuardrailError is returned for a query that was refused by the QueryGuardrails
+type GuardrailError struct {
+	Violation GuardrailViolation
+	Message   string
+	Estimate  *QueryEstimate // nil if the dry run failed
+}
+
+func (err *GuardrailError) Error() string {
+	return fmt.Sprintf("googlebigquery: query refused by guardrail %s: %s", err.Violation, err.Message)
+}
+
+// errortoolsError returns err as *errortools.Error, AsError returns the violation as an *Error with reason ErrorReasonGuardrail
+func (err *GuardrailError) errortoolsError() *errortools.Error {
+	e := errortools.ErrorMessage(err)
+	setError(e, &Error{
+		Message:   e.Message(),
+		Errors:    []ErrorProto{{Reason: ErrorReasonGuardrail, Message: err.Message}},
+		Guardrail: err,
+	})
+	return e
+}
+
+// SetQueryGuardrails applies guardrails to all query jobs inserted by the Service, pass nil to remove them
+func (service *Service) SetQueryGuardrails(guardrails *QueryGuardrails) {
+	service.queryGuardrails = guardrails
+}
+
+// CheckQuery checks the query against the guardrails of the Service without inserting a job.
+// A violation is returned as *GuardrailError, other failures as *errortools.Error.
+func (service *Service) CheckQuery(config *EstimateQueryConfig) (*QueryEstimate, *GuardrailError, *errortools.Error) {
+	return service.CheckQueryWithContext(context.Background(), config)
+}
+
+func (service *Service) CheckQueryWithContext(ctx context.Context, config *EstimateQueryConfig) (*QueryEstimate, *GuardrailError, *errortools.Error) {
+	if config == nil {
+		return nil, nil, errortools.ErrorMessage("EstimateQueryConfig must not be a nil pointer")
+	}
+
+	guardrails := service.queryGuardrails
+	if guardrails == nil {
+		guardrails = &QueryGuardrails{}
+	}
+
+	estimate, e := service.EstimateQueryWithContext(ctx, config)
+	if e != nil {
+		if guardrails.RequirePartitionFilter && isMissingPartitionFilter(e) {
+			return nil, &GuardrailError{
+				Violation: GuardrailViolationPartitionFilter,
+				Message:   e.Message(),
+			}, nil
+		}
+		return nil, nil, e
+	}
+
+	if guardrails.MaxBytesProcessed != nil && estimate.ExceedsBytes(*guardrails.MaxBytesProcessed) {
+		return estimate, &GuardrailError{
+			Violation: GuardrailViolationBytesProcessed,
+			Message:   fmt.Sprintf("query processes %v bytes, maximum is %v", estimate.TotalBytesProcessed, *guardrails.MaxBytesProcessed),
+			Estimate:  estimate,
+		}, nil
+	}
+
+	if guardrails.MaxEstimatedCost != nil && estimate.EstimatedCost > *guardrails.MaxEstimatedCost {
+		return estimate, &GuardrailError{
+			Violation: GuardrailViolationEstimatedCost,
+			Message:   fmt.Sprintf("query costs an estimated %.2f, maximum is %.2f", estimate.EstimatedCost, *guardrails.MaxEstimatedCost),
+			Estimate:  estimate,
+		}, nil
+	}
+
+	return estimate, nil, nil
+}
+
+// isMissingPartitionFilter recognizes the error BigQuery returns for a query over a table
+// with RequirePartitionFilter set that does not filter on the partitioning column.
+// BigQuery has no dedicated reason for it, so the invalidQuery entry is recognized by its message,
+// e.g. "Cannot query over table 'p.d.t' without a filter over column(s) 'date' that can be used for partition elimination".
+func isMissingPartitionFilter(e *errortools.Error) bool {
+	err := AsError(e)
+	if err == nil {
+		return false
+	}
+	for _, errorProto := range err.Errors {
+		if errorProto.Reason == ErrorReasonInvalidQuery && strings.Contains(errorProto.Message, "partition elimination") {
+			return true
+		}
+	}
+	return false
+}
+
+// applyQueryGuardrails returns the config with the guardrails applied,
+// the config passed is not modified
+func (service *Service) applyQueryGuardrails(ctx context.Context, config *InsertJobConfig) (*InsertJobConfig, *GuardrailError, *errortools.Error) {
+	guardrails := service.queryGuardrails
+
+	if guardrails == nil || config.Configuration.Query == nil {
+		return config, nil, nil
+	}
+	if config.Configuration.DryRun != nil && *config.Configuration.DryRun {
+		return config, nil, nil
+	}
+
+	_config := *config
+	query := *config.Configuration.Query
+	_config.Configuration.Query = &query
+
+	if guardrails.MaximumBytesBilled != nil {
+		if query.MaximumBytesBilled == nil || query.MaximumBytesBilled.Value() > *guardrails.MaximumBytesBilled {
+			maximumBytesBilled := go_types.Int64String(*guardrails.MaximumBytesBilled)
+			query.MaximumBytesBilled = &maximumBytesBilled
+		}
+	}
+
+	if guardrails.dryRun() {
+		_, guardrailError, e := service.CheckQueryWithContext(ctx, &EstimateQueryConfig{
+			ProjectId: config.ProjectId,
+			Location:  config.Location,
+			Query:     query,
+		})
+		if e != nil {
+			return nil, nil, e
+		}
+		if guardrailError != nil {
+			return nil, guardrailError, nil
+		}
+	}
+
+	return &_config, nil, nil
+}
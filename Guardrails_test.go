@@ -0,0 +1,49 @@
+package googlebigquery
+
+import (
+	"testing"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+)
+
+func TestGuardrailErrorPropagates(t *testing.T) {
+	guardrailError := GuardrailError{
+		Violation: GuardrailViolationEstimatedCost,
+		Message:   "query costs an estimated 10.00, maximum is 1.00",
+	}
+
+	e := guardrailError.errortoolsError()
+	err := AsError(e)
+	if err == nil || !err.HasReason(ErrorReasonGuardrail) {
+		t.Fatalf("AsError = %+v, want reason %s", err, ErrorReasonGuardrail)
+	}
+	if err.Guardrail == nil || err.Guardrail.Violation != GuardrailViolationEstimatedCost {
+		t.Errorf("Guardrail = %+v, want the violation", err.Guardrail)
+	}
+	if e.Message() != guardrailError.Error() {
+		t.Errorf("Message() = %q, want %q", e.Message(), guardrailError.Error())
+	}
+}
+
+func TestIsMissingPartitionFilter(t *testing.T) {
+	newError := func(errorProto ErrorProto) *errortools.Error {
+		e := errortools.ErrorMessage(errorProto.Message)
+		setError(e, &Error{StatusCode: 400, Message: errorProto.Message, Errors: []ErrorProto{errorProto}})
+		return e
+	}
+
+	for _, test := range []struct {
+		name string
+		e    *errortools.Error
+		want bool
+	}{
+		{"missing filter", newError(ErrorProto{Reason: ErrorReasonInvalidQuery, Message: "Cannot query over table 'p.d.t' without a filter over column(s) 'date' that can be used for partition elimination"}), true},
+		{"other invalid query", newError(ErrorProto{Reason: ErrorReasonInvalidQuery, Message: "Syntax error: Unexpected end of script at [1:7]"}), false},
+		{"other reason", newError(ErrorProto{Reason: ErrorReasonInvalid, Message: "can be used for partition elimination"}), false},
+		{"no error response", errortools.ErrorMessage("Cannot query over table 'p.d.t' without a filter over column(s) 'date' that can be used for partition elimination"), false},
+	} {
+		if got := isMissingPartitionFilter(test.e); got != test.want {
+			t.Errorf("%s: isMissingPartitionFilter = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
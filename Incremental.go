@@ -0,0 +1,332 @@
+package googlebigquery
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+)
+
+type IncrementalReadConfig struct {
+	ProjectId string
+	Location  *string
+	Table     TableReference
+	Store     WatermarkStore
+	// WatermarkColumn is the column whose high-water mark is tracked, it should only increase for new rows,
+	// e.g. an ingestion timestamp. If nil the last-modified times of the partitions are tracked instead.
+	WatermarkColumn *string
+	// Columns to select, defaults to all columns
+	Columns *[]string
+}
+
+// IncrementalRead holds the rows added since the previous watermark.
+// Call Commit once the rows have been processed to read only newer rows on the next run.
+type IncrementalRead struct {
+	service *Service
+	store   WatermarkStore
+	key     string
+	// Job is the query job that selected the new rows, nil if there are none
+	Job      *Job
+	Previous *Watermark
+	Next     *Watermark
+	// PartitionIds are the partitions modified since the previous watermark when tracking partitions,
+	// these are read in full, so consumers should replace their copy of each partition
+	PartitionIds []string
+}
+
+func (service *Service) ReadIncremental(config *IncrementalReadConfig) (*IncrementalRead, *errortools.Error) {
+	return service.ReadIncrementalWithContext(context.Background(), config)
+}
+
+func (service *Service) ReadIncrementalWithContext(ctx context.Context, config *IncrementalReadConfig) (*IncrementalRead, *errortools.Error) {
+	if config == nil {
+		return nil, errortools.ErrorMessage("IncrementalReadConfig must not be a nil pointer")
+	}
+	if config.Store == nil {
+		return nil, errortools.ErrorMessage("Store must not be nil")
+	}
+
+	table, e := service.GetTableWithContext(ctx, &GetTableConfig{
+		ProjectId: config.Table.ProjectID,
+		DatasetId: config.Table.DatasetID,
+		TableId:   config.Table.TableID,
+	})
+	if e != nil {
+		return nil, e
+	}
+
+	key := watermarkKey(config.Table, config.WatermarkColumn)
+
+	previous, e := config.Store.GetWatermark(ctx, key)
+	if e != nil {
+		return nil, e
+	}
+
+	read := IncrementalRead{
+		service:  service,
+		store:    config.Store,
+		key:      key,
+		Previous: previous,
+		Next:     previous,
+	}
+
+	if config.WatermarkColumn != nil {
+		e = service.readIncrementalColumn(ctx, config, table, &read)
+	} else {
+		e = service.readIncrementalPartitions(ctx, config, table, &read)
+	}
+	if e != nil {
+		return nil, e
+	}
+
+	return &read, nil
+}
+
+func watermarkKey(table TableReference, watermarkColumn *string) string {
+	if watermarkColumn == nil {
+		return fmt.Sprintf("%s.%s.%s#partitions", table.ProjectID, table.DatasetID, table.TableID)
+	}
+	return fmt.Sprintf("%s.%s.%s#%s", table.ProjectID, table.DatasetID, table.TableID, *watermarkColumn)
+}
+
+// readIncrementalColumn selects the rows with a watermark column value after the previous watermark
+// and up to the current maximum, so that rows added while reading are left for the next run
+func (service *Service) readIncrementalColumn(ctx context.Context, config *IncrementalReadConfig, table *Table, read *IncrementalRead) *errortools.Error {
+	var columnType string
+	if table.Schema != nil {
+		for _, field := range table.Schema.Fields {
+			if strings.EqualFold(field.Name, *config.WatermarkColumn) {
+				columnType = normalizedFieldType(field.Type)
+				break
+			}
+		}
+	}
+	if columnType == "" {
+		return errortools.ErrorMessagef("column %s does not exist in table %s", *config.WatermarkColumn, config.Table.TableID)
+	}
+
+	column := quoteIdentifier(*config.WatermarkColumn)
+
+	job, e := service.runIncrementalQuery(ctx, config, fmt.Sprintf("SELECT CAST(MAX(%s) AS STRING) FROM %s", column, quoteTableReference(config.Table)), nil)
+	if e != nil {
+		return e
+	}
+	rows, e := service.queryResultRows(ctx, job)
+	if e != nil {
+		return e
+	}
+	if len(rows) == 0 || len(rows[0].F) == 0 {
+		return nil
+	}
+	upper, ok := rows[0].F[0].V.(string)
+	if !ok {
+		// the table is empty
+		return nil
+	}
+	if read.Previous != nil && read.Previous.Value == upper {
+		return nil
+	}
+
+	where := fmt.Sprintf("%s <= CAST(@upper AS %s)", column, columnType)
+	parameters := map[string]interface{}{"upper": upper}
+	if read.Previous != nil {
+		where = fmt.Sprintf("%s > CAST(@lower AS %s) AND %s", column, columnType, where)
+		parameters["lower"] = read.Previous.Value
+	}
+
+	read.Job, e = service.runIncrementalQuery(ctx, config, fmt.Sprintf("SELECT %s FROM %s WHERE %s", incrementalColumns(config), quoteTableReference(config.Table), where), parameters)
+	if e != nil {
+		return e
+	}
+	read.Next = &Watermark{
+		Value:     upper,
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	return nil
+}
+
+// readIncrementalPartitions selects the rows of all partitions modified after the previous watermark,
+// the watermark being the latest last-modified time in microseconds
+func (service *Service) readIncrementalPartitions(ctx context.Context, config *IncrementalReadConfig, table *Table, read *IncrementalRead) *errortools.Error {
+	partitionIdExpression, e := partitionIdExpression(table)
+	if e != nil {
+		return e
+	}
+
+	var previous int64
+	if read.Previous != nil {
+		var err error
+		previous, err = strconv.ParseInt(read.Previous.Value, 10, 64)
+		if err != nil {
+			return errortools.ErrorMessagef("invalid partition watermark %s", read.Previous.Value)
+		}
+	}
+
+	partitions, e := service.GetPartitionsWithContext(ctx, &GetPartitionsConfig{
+		ProjectId: &config.ProjectId,
+		Table:     config.Table,
+	})
+	if e != nil {
+		return e
+	}
+
+	next := previous
+	partitionIds := []string{}
+	for _, partition := range *partitions {
+		if partition.LastModifiedTime == nil {
+			continue
+		}
+		lastModified := partition.LastModifiedTime.UnixMicro()
+		if lastModified <= previous {
+			continue
+		}
+		partitionIds = append(partitionIds, partition.PartitionId)
+		if lastModified > next {
+			next = lastModified
+		}
+	}
+	if len(partitionIds) == 0 {
+		return nil
+	}
+
+	read.Job, e = service.runIncrementalQuery(ctx, config, fmt.Sprintf("SELECT %s FROM %s WHERE %s IN UNNEST(@partition_ids)", incrementalColumns(config), quoteTableReference(config.Table), partitionIdExpression), map[string]interface{}{"partition_ids": partitionIds})
+	if e != nil {
+		return e
+	}
+	read.PartitionIds = partitionIds
+	read.Next = &Watermark{
+		Value:     strconv.FormatInt(next, 10),
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	return nil
+}
+
+// partitionIdExpression returns the SQL expression that evaluates to the partition id of a row
+func partitionIdExpression(table *Table) (string, *errortools.Error) {
+	if table.TimePartitioning != nil {
+		var format string
+		switch table.TimePartitioning.Type {
+		case "HOUR":
+			format = "%Y%m%d%H"
+		case "DAY", "":
+			format = "%Y%m%d"
+		case "MONTH":
+			format = "%Y%m"
+		case "YEAR":
+			format = "%Y"
+		default:
+			return "", errortools.ErrorMessagef("unsupported time partitioning type %s", table.TimePartitioning.Type)
+		}
+
+		if table.TimePartitioning.Field == nil {
+			return fmt.Sprintf("IFNULL(FORMAT_TIMESTAMP('%s', _PARTITIONTIME), '%s')", format, PartitionIdUnpartitioned), nil
+		}
+		return fmt.Sprintf("IFNULL(FORMAT_TIMESTAMP('%s', CAST(%s AS TIMESTAMP)), '%s')", format, quoteIdentifier(*table.TimePartitioning.Field), PartitionIdNull), nil
+	}
+
+	if table.RangePartitioning != nil {
+		start, err := strconv.ParseInt(table.RangePartitioning.Range.Start, 10, 64)
+		if err != nil {
+			return "", errortools.ErrorMessagef("invalid range partitioning start %s", table.RangePartitioning.Range.Start)
+		}
+		end, err := strconv.ParseInt(table.RangePartitioning.Range.End, 10, 64)
+		if err != nil {
+			return "", errortools.ErrorMessagef("invalid range partitioning end %s", table.RangePartitioning.Range.End)
+		}
+		interval, err := strconv.ParseInt(table.RangePartitioning.Range.Interval, 10, 64)
+		if err != nil || interval <= 0 {
+			return "", errortools.ErrorMessagef("invalid range partitioning interval %s", table.RangePartitioning.Range.Interval)
+		}
+
+		field := quoteIdentifier(table.RangePartitioning.Field)
+		return fmt.Sprintf("CASE WHEN %s IS NULL THEN '%s' WHEN %s < %v OR %s >= %v THEN '%s' ELSE CAST(%v + DIV(%s - %v, %v) * %v AS STRING) END",
+			field, PartitionIdNull,
+			field, start, field, end, PartitionIdUnpartitioned,
+			start, field, start, interval, interval,
+		), nil
+	}
+
+	return "", errortools.ErrorMessagef("table %s is not partitioned", table.TableReference.TableID)
+}
+
+func incrementalColumns(config *IncrementalReadConfig) string {
+	if config.Columns == nil || len(*config.Columns) == 0 {
+		return "*"
+	}
+
+	columns := []string{}
+	for _, column := range *config.Columns {
+		columns = append(columns, quoteIdentifier(column))
+	}
+
+	return strings.Join(columns, ", ")
+}
+
+func (service *Service) runIncrementalQuery(ctx context.Context, config *IncrementalReadConfig, statement string, parameters map[string]interface{}) (*Job, *errortools.Error) {
+	query := JobConfigurationQuery{
+		Query: statement,
+		DefaultDataset: &DatasetReference{
+			ProjectID: config.Table.ProjectID,
+			DatasetID: config.Table.DatasetID,
+		},
+	}
+	if len(parameters) > 0 {
+		e := query.SetNamedParameters(parameters)
+		if e != nil {
+			return nil, e
+		}
+	}
+
+	return service.RunJobWithContext(ctx, &InsertJobConfig{
+		ProjectId: config.ProjectId,
+		Location:  config.Location,
+		Configuration: JobConfiguration{
+			Query: &query,
+		},
+	})
+}
+
+// Schema returns the schema of the new rows, nil if there are none
+func (read *IncrementalRead) Schema() *TableSchema {
+	if read.Job == nil || read.Job.Statistics.Query == nil {
+		return nil
+	}
+	return read.Job.Statistics.Query.Schema
+}
+
+func (read *IncrementalRead) RowsPager() *Pager[TableRow] {
+	return read.RowsPagerWithContext(context.Background())
+}
+
+// RowsPagerWithContext returns a pager over the new rows
+func (read *IncrementalRead) RowsPagerWithContext(ctx context.Context) *Pager[TableRow] {
+	if read.Job == nil {
+		return NewPager(ctx, nil, func(ctx context.Context, pageToken *string, pageSize *int) ([]TableRow, *string, *errortools.Error) {
+			return nil, nil, nil
+		})
+	}
+
+	return read.service.QueryResultsPagerWithContext(ctx, &GetQueryResultsConfig{
+		ProjectId: read.Job.JobReference.ProjectID,
+		JobId:     read.Job.JobReference.JobID,
+		Location:  jobReferenceLocation(read.Job.JobReference),
+	})
+}
+
+func (read *IncrementalRead) Commit() *errortools.Error {
+	return read.CommitWithContext(context.Background())
+}
+
+// CommitWithContext stores the new watermark
+func (read *IncrementalRead) CommitWithContext(ctx context.Context) *errortools.Error {
+	if read.Next == nil || read.Next == read.Previous {
+		return nil
+	}
+
+	return read.store.SetWatermark(ctx, read.key, *read.Next)
+}
@@ -0,0 +1,58 @@
+package googlebigquery
+
+import (
+	errortools "github.com/leapforce-libraries/go_errortools"
+)
+
+// Iterator yields the items of a list endpoint page by page,
+// only keeping the current page in memory.
+//
+//	for {
+//		dataset, e := it.Next()
+//		if e != nil {
+//			return e
+//		}
+//		if dataset == nil {
+//			break
+//		}
+//	}
+type Iterator[T any] struct {
+	pager     *Pager[T]
+	items     []T
+	index     int
+	pageToken *string
+}
+
+// Next returns the next item, or nil if all items have been returned
+func (it *Iterator[T]) Next() (*T, *errortools.Error) {
+	for it.index >= len(it.items) {
+		items, e := it.pager.NextPage()
+		if e != nil {
+			return nil, e
+		}
+		if items == nil {
+			return nil, nil
+		}
+
+		it.items = items
+		it.index = 0
+		it.pageToken = it.pager.PageToken()
+	}
+
+	item := &it.items[it.index]
+	it.index++
+
+	return item, nil
+}
+
+// PageToken returns the token of the page the last returned item belongs to,
+// nil if that is the first page. Passing it as PageToken resumes at the start of that page.
+func (it *Iterator[T]) PageToken() *string {
+	return it.pageToken
+}
+
+// NextPageToken returns the token of the page that is fetched after the current page has been consumed,
+// nil if there are no more pages.
+func (it *Iterator[T]) NextPageToken() *string {
+	return it.pager.NextPageToken()
+}
@@ -0,0 +1,36 @@
+//go:build go1.23
+
+package googlebigquery
+
+import (
+	"iter"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+)
+
+// All returns the remaining items as a range-over-func sequence.
+// Iteration stops after the first error.
+//
+//	for dataset, e := range service.IterateDatasets(config).All() {
+//		if e != nil {
+//			return e
+//		}
+//	}
+func (it *Iterator[T]) All() iter.Seq2[T, *errortools.Error] {
+	return func(yield func(T, *errortools.Error) bool) {
+		for {
+			item, e := it.Next()
+			if e != nil {
+				var zero T
+				yield(zero, e)
+				return
+			}
+			if item == nil {
+				return
+			}
+			if !yield(*item, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package googlebigquery
+
+import (
+	"strings"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+)
+
+const jsonSchemaDialect string = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema is a JSON Schema (draft 2020-12) document or subschema
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 interface{}            `json:"type,omitempty"` // a type name or a list of type names
+	Format               string                 `json:"format,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	ContentEncoding      string                 `json:"contentEncoding,omitempty"`
+	MaxLength            *int64                 `json:"maxLength,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+}
+
+// TableToJSONSchema returns the JSON Schema of the rows of table, titled after the table
+func TableToJSONSchema(table *Table) (*JSONSchema, *errortools.Error) {
+	if table == nil {
+		return nil, errortools.ErrorMessage("Table must not be a nil pointer")
+	}
+	if table.Schema == nil {
+		return nil, errortools.ErrorMessagef("table %s has no schema", table.TableReference.TableID)
+	}
+
+	jsonSchema, e := TableFieldsToJSONSchema(&table.Schema.Fields)
+	if e != nil {
+		return nil, e
+	}
+
+	jsonSchema.Title = table.TableReference.TableID
+	if table.FriendlyName != nil && *table.FriendlyName != "" {
+		jsonSchema.Title = *table.FriendlyName
+	}
+	if table.Description != nil {
+		jsonSchema.Description = *table.Description
+	}
+
+	return jsonSchema, nil
+}
+
+// TableFieldsToJSONSchema returns the JSON Schema of a row with the fields, in the JSON representation
+// accepted by tabledata.insertAll. Rows are objects without additional properties, REQUIRED fields are required
+// properties, NULLABLE fields also accept null, REPEATED fields are arrays and RECORD fields nested objects.
+// Descriptions carry over. Types are mapped as follows:
+//
+//	STRING                 string, with the maxLength of the field
+//	BYTES                  string with contentEncoding base64, with the base64 length of the maxLength of the field
+//	INTEGER                integer
+//	FLOAT                  number
+//	NUMERIC, BIGNUMERIC    number or decimal string
+//	BOOLEAN                boolean
+//	TIMESTAMP              string with format date-time
+//	DATE                   string with format date
+//	TIME                   string matching hh:mm:ss[.ffffff]
+//	DATETIME               string matching yyyy-mm-ddThh:mm:ss[.ffffff], with a T or a space
+//	GEOGRAPHY, INTERVAL    string
+//	JSON                   any value
+func TableFieldsToJSONSchema(fields *[]TableFieldSchema) (*JSONSchema, *errortools.Error) {
+	if fields == nil {
+		return nil, errortools.ErrorMessage("fields must not be a nil pointer")
+	}
+
+	jsonSchema, e := jsonSchemaObject(*fields)
+	if e != nil {
+		return nil, e
+	}
+	jsonSchema.Schema = jsonSchemaDialect
+
+	return jsonSchema, nil
+}
+
+func jsonSchemaObject(fields []TableFieldSchema) (*JSONSchema, *errortools.Error) {
+	additionalProperties := false
+
+	object := JSONSchema{
+		Type:                 "object",
+		Properties:           map[string]*JSONSchema{},
+		AdditionalProperties: &additionalProperties,
+	}
+
+	for _, field := range fields {
+		property, e := jsonSchemaProperty(field)
+		if e != nil {
+			return nil, e
+		}
+
+		switch strings.ToUpper(field.Mode) {
+		case "REQUIRED":
+			object.Required = append(object.Required, field.Name)
+		case "REPEATED":
+			property = &JSONSchema{
+				Description: property.Description,
+				Type:        "array",
+				Items:       property,
+			}
+			property.Items.Description = ""
+		default:
+			if types, ok := property.Type.([]string); ok {
+				property.Type = append(types, "null")
+			} else if property.Type != nil {
+				property.Type = []string{property.Type.(string), "null"}
+			}
+		}
+
+		object.Properties[field.Name] = property
+	}
+
+	return &object, nil
+}
+
+func jsonSchemaProperty(field TableFieldSchema) (*JSONSchema, *errortools.Error) {
+	property := JSONSchema{
+		Description: field.Description,
+	}
+
+	switch normalizedFieldType(field.Type) {
+	case "STRING":
+		property.Type = "string"
+		if field.MaxLength != nil {
+			maxLength := int64(*field.MaxLength)
+			property.MaxLength = &maxLength
+		}
+	case "BYTES":
+		property.Type = "string"
+		property.ContentEncoding = "base64"
+		if field.MaxLength != nil {
+			maxLength := (int64(*field.MaxLength) + 2) / 3 * 4
+			property.MaxLength = &maxLength
+		}
+	case "INT64":
+		property.Type = "integer"
+	case "FLOAT64":
+		property.Type = "number"
+	case "NUMERIC", "BIGNUMERIC":
+		// decimals are commonly sent as strings to preserve their precision
+		property.Type = []string{"number", "string"}
+		property.Pattern = `^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][+-]?[0-9]+)?$`
+	case "BOOL":
+		property.Type = "boolean"
+	case "TIMESTAMP":
+		property.Type = "string"
+		property.Format = "date-time"
+	case "DATE":
+		property.Type = "string"
+		property.Format = "date"
+	case "TIME":
+		property.Type = "string"
+		property.Pattern = `^[0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]{1,6})?$`
+	case "DATETIME":
+		property.Type = "string"
+		property.Pattern = `^[0-9]{4}-[0-9]{2}-[0-9]{2}[T ][0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]{1,6})?$`
+	case "GEOGRAPHY", "INTERVAL":
+		property.Type = "string"
+	case "JSON":
+		// any JSON value
+	case "STRUCT":
+		object, e := jsonSchemaObject(field.Fields)
+		if e != nil {
+			return nil, e
+		}
+		object.Description = field.Description
+		return object, nil
+	default:
+		return nil, errortools.ErrorMessagef("field %s has unsupported type %s", field.Name, field.Type)
+	}
+
+	return &property, nil
+}
@@ -1,9 +1,12 @@
 package googlebigquery
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	errortools "github.com/leapforce-libraries/go_errortools"
 	go_http "github.com/leapforce-libraries/go_http"
@@ -64,6 +67,7 @@ type JobConfigurationQuery struct {
 	DestinationEncryptionConfiguration *EncryptionConfiguration             `json:"destinationEncryptionConfiguration"`
 	ScriptOptions                      *ScriptOptions                       `json:"scriptOptions"`
 	ConnectionProperties               *[]ConnectionProperty                `json:"connectionProperties"`
+	CreateSession                      *bool                                `json:"createSession"`
 }
 
 type QueryParameter struct {
@@ -73,13 +77,15 @@ type QueryParameter struct {
 }
 
 type QueryParameterType struct {
-	Type        string              `json:"type"`
-	ArrayType   *QueryParameterType `json:"arrayType"`
-	StructTypes *[]struct {
-		Name        *string            `json:"name"`
-		Type        QueryParameterType `json:"type"`
-		Description *string            `json:"description"`
-	} `json:"structTypes"`
+	Type        string                      `json:"type"`
+	ArrayType   *QueryParameterType         `json:"arrayType"`
+	StructTypes *[]QueryParameterStructType `json:"structTypes"`
+}
+
+type QueryParameterStructType = struct {
+	Name        *string            `json:"name"`
+	Type        QueryParameterType `json:"type"`
+	Description *string            `json:"description"`
 }
 
 type QueryParameterValue struct {
@@ -164,19 +170,52 @@ type JobStatistics struct {
 	TotalBytesProcessed *go_types.Int64String `json:"totalBytesProcessed"`
 	CompletionRatio     *float64              `json:"completionRatio"`
 	QuotaDeferments     *[]string             `json:"quotaDeferments"`
-	//Query                      *JobStatistics2             `json:"query"`
+	Query               *JobStatistics2       `json:"query"`
 	//Load                       *JobStatistics3             `json:"load"`
 	//Extract                    *JobStatistics4             `json:"extract"`
 	TotalSlotMS *go_types.Int64String `json:"totalSlotMs"`
 	//ReservationUsage           *[]ReservationUsage         `json:"reservationUsage"`
-	ReservationId *string               `json:"reservation_id"`
-	NumChildJobs  *go_types.Int64String `json:"numChildJobs"`
-	ParentJobId   *string               `json:"parentJobId"`
-	//ScriptStatistics           *ScriptStatistics           `json:"scriptStatistics"`
+	ReservationId    *string               `json:"reservation_id"`
+	NumChildJobs     *go_types.Int64String `json:"numChildJobs"`
+	ParentJobId      *string               `json:"parentJobId"`
+	ScriptStatistics *ScriptStatistics     `json:"scriptStatistics"`
+	SessionInfo      *SessionInfo          `json:"sessionInfo"`
 	//RowLevelSecurityStatistics *RowLevelSecurityStatistics `json:"rowLevelSecurityStatistics"`
 	//TransactionInfo            *TransactionInfo            `json:"transactionInfo"`
 }
 
+type JobStatistics2 struct {
+	TotalBytesProcessed         *go_types.Int64String `json:"totalBytesProcessed"`
+	TotalBytesProcessedAccuracy *string               `json:"totalBytesProcessedAccuracy"`
+	EstimatedBytesProcessed     *go_types.Int64String `json:"estimatedBytesProcessed"`
+	ReferencedTables            *[]TableReference     `json:"referencedTables"`
+	Schema                      *TableSchema          `json:"schema"`
+	TotalBytesBilled            *go_types.Int64String `json:"totalBytesBilled"`
+	BillingTier                 *int64                `json:"billingTier"`
+	TotalSlotMS                 *go_types.Int64String `json:"totalSlotMs"`
+	CacheHit                    *bool                 `json:"cacheHit"`
+	StatementType               *string               `json:"statementType"`
+	NumDmlAffectedRows          *go_types.Int64String `json:"numDmlAffectedRows"`
+}
+
+type ScriptStatistics struct {
+	EvaluationKind string             `json:"evaluationKind"`
+	StackFrames    []ScriptStackFrame `json:"stackFrames"`
+}
+
+type ScriptStackFrame struct {
+	StartLine   int     `json:"startLine"`
+	StartColumn int     `json:"startColumn"`
+	EndLine     int     `json:"endLine"`
+	EndColumn   int     `json:"endColumn"`
+	ProcedureId *string `json:"procedureId"`
+	Text        string  `json:"text"`
+}
+
+type SessionInfo struct {
+	SessionId string `json:"sessionId"`
+}
+
 type JobStatus struct {
 	ErrorResult *ErrorProto   `json:"errorResult"`
 	Errors      *[]ErrorProto `json:"errors"`
@@ -189,6 +228,7 @@ type GetJobsConfig struct {
 	MaxResults      *int
 	MinCreationTime *int64
 	MaxCreationTime *int64
+	MaxItems        *int
 	PageToken       *string
 	Projection      *JobProjection
 	StateFilter     *[]JobState
@@ -202,6 +242,16 @@ const (
 	JobProjectionMinimal JobProjection = "MINIMAL"
 )
 
+type JobType string
+
+const (
+	JobTypeCopy    JobType = "COPY"
+	JobTypeExtract JobType = "EXTRACT"
+	JobTypeLoad    JobType = "LOAD"
+	JobTypeQuery   JobType = "QUERY"
+	JobTypeUnknown JobType = "UNKNOWN"
+)
+
 type JobState string
 
 const (
@@ -211,18 +261,52 @@ const (
 )
 
 func (service *Service) GetJobs(config *GetJobsConfig) (*[]Job, *errortools.Error) {
+	return service.GetJobsWithContext(context.Background(), config)
+}
+
+func (service *Service) GetJobsWithContext(ctx context.Context, config *GetJobsConfig) (*[]Job, *errortools.Error) {
+	return service.JobsPagerWithContext(ctx, config).All()
+}
+
+// IterateJobs returns an iterator that fetches the jobs page by page
+func (service *Service) IterateJobs(config *GetJobsConfig) *Iterator[Job] {
+	return service.IterateJobsWithContext(context.Background(), config)
+}
+
+func (service *Service) IterateJobsWithContext(ctx context.Context, config *GetJobsConfig) *Iterator[Job] {
+	return service.JobsPagerWithContext(ctx, config).Iterator()
+}
+
+func (service *Service) JobsPager(config *GetJobsConfig) *Pager[Job] {
+	return service.JobsPagerWithContext(context.Background(), config)
+}
+
+func (service *Service) JobsPagerWithContext(ctx context.Context, config *GetJobsConfig) *Pager[Job] {
 	if config == nil {
-		return nil, errortools.ErrorMessage("GetJobsConfig must not be a nil pointer")
+		return newErrorPager[Job](errortools.ErrorMessage("GetJobsConfig must not be a nil pointer"))
+	}
+
+	pagerConfig := PagerConfig{
+		PageToken: config.PageToken,
+		PageSize:  config.MaxResults,
+		MaxItems:  config.MaxItems,
 	}
 
+	return NewPager(ctx, &pagerConfig, func(ctx context.Context, pageToken *string, pageSize *int) ([]Job, *string, *errortools.Error) {
+		jobsResponse, e := service.getJobsPage(ctx, config, pageToken, pageSize)
+		if e != nil {
+			return nil, nil, e
+		}
+		return jobsResponse.Jobs, jobsResponse.NextPageToken, nil
+	})
+}
+
+func (service *Service) getJobsPage(ctx context.Context, config *GetJobsConfig, pageToken *string, pageSize *int) (*JobsResponse, *errortools.Error) {
 	values := url.Values{}
 
 	if config.AllUsers != nil {
 		values.Set("allUsers", fmt.Sprintf("%v", *config.AllUsers))
 	}
-	if config.MaxResults != nil {
-		values.Set("maxResults", fmt.Sprintf("%v", *config.MaxResults))
-	}
 	if config.MinCreationTime != nil {
 		values.Set("minCreationTime", fmt.Sprintf("%v", *config.MinCreationTime))
 	}
@@ -234,66 +318,437 @@ func (service *Service) GetJobs(config *GetJobsConfig) (*[]Job, *errortools.Erro
 	}
 	if config.StateFilter != nil {
 		for _, stateFilter := range *config.StateFilter {
-			values.Set("stateFilter", string(stateFilter))
+			values.Add("stateFilter", string(stateFilter))
 		}
 	}
-	pageToken := config.PageToken
-
-	jobs := []Job{}
-
-	for {
-		if pageToken != nil {
-			values.Set("pageToken", *pageToken)
-		}
-
-		jobsReponse := JobsResponse{}
-
-		requestConfig := go_http.RequestConfig{
-			Method:        http.MethodGet,
-			Url:           service.url(fmt.Sprintf("projects/%s/jobs?%s", config.ProjectId, values.Encode())),
-			ResponseModel: &jobsReponse,
-		}
-		_, _, e := service.googleService.HttpRequest(&requestConfig)
-		if e != nil {
-			return nil, e
-		}
+	if config.ParentJobId != nil {
+		values.Set("parentJobId", *config.ParentJobId)
+	}
 
-		jobs = append(jobs, jobsReponse.Jobs...)
+	if pageSize != nil {
+		values.Set("maxResults", fmt.Sprintf("%v", *pageSize))
+	}
+	if pageToken != nil {
+		values.Set("pageToken", *pageToken)
+	}
 
-		if config.PageToken != nil {
-			break
-		}
-		if jobsReponse.NextPageToken == nil {
-			break
-		}
+	jobsResponse := JobsResponse{}
 
-		pageToken = jobsReponse.NextPageToken
+	requestConfig := go_http.RequestConfig{
+		Method:        http.MethodGet,
+		Url:           service.url(fmt.Sprintf("projects/%s/jobs?%s", config.ProjectId, values.Encode())),
+		ResponseModel: &jobsResponse,
+	}
+	_, _, e := service.httpRequest(ctx, OperationJobsList, &requestConfig)
+	if e != nil {
+		return nil, e
 	}
 
-	return &jobs, nil
+	return &jobsResponse, nil
 }
 
 type GetJobConfig struct {
 	ProjectId string
 	JobId     string
+	Location  *string
 }
 
 func (service *Service) GetJob(config *GetJobConfig) (*Job, *errortools.Error) {
+	return service.GetJobWithContext(context.Background(), config)
+}
+
+func (service *Service) GetJobWithContext(ctx context.Context, config *GetJobConfig) (*Job, *errortools.Error) {
 	if config == nil {
 		return nil, errortools.ErrorMessage("GetJobsConfig must not be a nil pointer")
 	}
 
+	values := url.Values{}
+
+	if location := service.jobLocation(config.Location); location != nil {
+		values.Set("location", *location)
+	}
+
 	job := Job{}
 
 	requestConfig := go_http.RequestConfig{
 		Method:        http.MethodGet,
-		Url:           service.url(fmt.Sprintf("projects/%s/jobs/%s", config.ProjectId, config.JobId)),
+		Url:           service.url(fmt.Sprintf("projects/%s/jobs/%s?%s", config.ProjectId, config.JobId, values.Encode())),
 		ResponseModel: &job,
 	}
-	_, _, e := service.googleService.HttpRequest(&requestConfig)
+	_, _, e := service.httpRequest(ctx, OperationJobsGet, &requestConfig)
 	if e != nil {
 		return nil, e
 	}
 
 	return &job, nil
 }
+
+type InsertJobConfig struct {
+	ProjectId string
+	// JobId should be generated by the client (see NewJobId) to make the insert safe to retry
+	JobId         *string
+	Location      *string
+	Configuration JobConfiguration
+}
+
+type insertJobRequest struct {
+	JobReference  *JobReference    `json:"jobReference,omitempty"`
+	Configuration JobConfiguration `json:"configuration"`
+}
+
+// NewJobId returns a unique job id with the given prefix
+func NewJobId(prefix string) string {
+	guid := go_types.NewGuid()
+	return fmt.Sprintf("%s%s", prefix, strings.Replace(guid.String(), "-", "", -1))
+}
+
+// InsertJob inserts a job, a query job refused by the QueryGuardrails of the Service fails with an error
+// for which AsError returns the violation with reason ErrorReasonGuardrail
+func (service *Service) InsertJob(config *InsertJobConfig) (*Job, *errortools.Error) {
+	return service.InsertJobWithContext(context.Background(), config)
+}
+
+func (service *Service) InsertJobWithContext(ctx context.Context, config *InsertJobConfig) (*Job, *errortools.Error) {
+	job, guardrailError, e := service.GuardedInsertJobWithContext(ctx, config)
+	if e != nil {
+		return nil, e
+	}
+	if guardrailError != nil {
+		return nil, guardrailError.errortoolsError()
+	}
+
+	return job, nil
+}
+
+// GuardedInsertJob inserts a job unless it is a query job refused by the QueryGuardrails of the Service.
+// A violation is returned as *GuardrailError, other failures as *errortools.Error.
+func (service *Service) GuardedInsertJob(config *InsertJobConfig) (*Job, *GuardrailError, *errortools.Error) {
+	return service.GuardedInsertJobWithContext(context.Background(), config)
+}
+
+func (service *Service) GuardedInsertJobWithContext(ctx context.Context, config *InsertJobConfig) (*Job, *GuardrailError, *errortools.Error) {
+	if config == nil {
+		return nil, nil, errortools.ErrorMessage("InsertJobConfig must not be a nil pointer")
+	}
+
+	config, guardrailError, e := service.applyQueryGuardrails(ctx, config)
+	if e != nil || guardrailError != nil {
+		return nil, guardrailError, e
+	}
+
+	location := service.insertJobLocation(ctx, config)
+
+	body := insertJobRequest{
+		Configuration: config.Configuration,
+	}
+	if config.JobId != nil || location != nil {
+		body.JobReference = &JobReference{
+			ProjectID: config.ProjectId,
+		}
+		if config.JobId != nil {
+			body.JobReference.JobID = *config.JobId
+		}
+		if location != nil {
+			body.JobReference.Location = *location
+		}
+	}
+
+	job := Job{}
+
+	requestConfig := go_http.RequestConfig{
+		Method:        http.MethodPost,
+		Url:           service.url(fmt.Sprintf("projects/%s/jobs", config.ProjectId)),
+		BodyModel:     &body,
+		ResponseModel: &job,
+	}
+	_, _, e = service.httpRequest(ctx, OperationJobsInsert, &requestConfig)
+	if e != nil {
+		return nil, nil, e
+	}
+
+	return &job, nil, nil
+}
+
+type CancelJobConfig struct {
+	ProjectId string
+	JobId     string
+	Location  *string
+}
+
+type cancelJobResponse struct {
+	Kind string `json:"kind"`
+	Job  Job    `json:"job"`
+}
+
+// CancelJob requests the job to be cancelled and returns the job, cancellation is asynchronous
+func (service *Service) CancelJob(config *CancelJobConfig) (*Job, *errortools.Error) {
+	return service.CancelJobWithContext(context.Background(), config)
+}
+
+func (service *Service) CancelJobWithContext(ctx context.Context, config *CancelJobConfig) (*Job, *errortools.Error) {
+	if config == nil {
+		return nil, errortools.ErrorMessage("CancelJobConfig must not be a nil pointer")
+	}
+
+	values := url.Values{}
+
+	if location := service.jobLocation(config.Location); location != nil {
+		values.Set("location", *location)
+	}
+
+	response := cancelJobResponse{}
+
+	requestConfig := go_http.RequestConfig{
+		Method:        http.MethodPost,
+		Url:           service.url(fmt.Sprintf("projects/%s/jobs/%s/cancel?%s", config.ProjectId, config.JobId, values.Encode())),
+		ResponseModel: &response,
+	}
+	_, _, e := service.httpRequest(ctx, OperationJobsCancel, &requestConfig)
+	if e != nil {
+		return nil, e
+	}
+
+	return &response.Job, nil
+}
+
+type DeleteJobConfig struct {
+	ProjectId string
+	JobId     string
+	Location  *string
+}
+
+// DeleteJob deletes the metadata of a job that is done
+func (service *Service) DeleteJob(config *DeleteJobConfig) *errortools.Error {
+	return service.DeleteJobWithContext(context.Background(), config)
+}
+
+func (service *Service) DeleteJobWithContext(ctx context.Context, config *DeleteJobConfig) *errortools.Error {
+	if config == nil {
+		return errortools.ErrorMessage("DeleteJobConfig must not be a nil pointer")
+	}
+
+	values := url.Values{}
+
+	if location := service.jobLocation(config.Location); location != nil {
+		values.Set("location", *location)
+	}
+
+	requestConfig := go_http.RequestConfig{
+		Method: http.MethodDelete,
+		Url:    service.url(fmt.Sprintf("projects/%s/jobs/%s/delete?%s", config.ProjectId, config.JobId, values.Encode())),
+	}
+	_, _, e := service.httpRequest(ctx, OperationJobsDelete, &requestConfig)
+	if e != nil {
+		return e
+	}
+
+	return nil
+}
+
+type QueryResultsResponse struct {
+	Kind                string                `json:"kind"`
+	Etag                string                `json:"etag"`
+	Schema              *TableSchema          `json:"schema"`
+	JobReference        JobReference          `json:"jobReference"`
+	TotalRows           *go_types.Int64String `json:"totalRows"`
+	PageToken           *string               `json:"pageToken"`
+	Rows                []TableRow            `json:"rows"`
+	TotalBytesProcessed *go_types.Int64String `json:"totalBytesProcessed"`
+	JobComplete         bool                  `json:"jobComplete"`
+	Errors              *[]ErrorProto         `json:"errors"`
+	CacheHit            *bool                 `json:"cacheHit"`
+	NumDmlAffectedRows  *go_types.Int64String `json:"numDmlAffectedRows"`
+}
+
+type TableRow struct {
+	F []TableCell `json:"f"`
+}
+
+type TableCell struct {
+	V interface{} `json:"v"`
+}
+
+type GetQueryResultsConfig struct {
+	ProjectId  string
+	JobId      string
+	Location   *string
+	MaxResults *int
+	PageToken  *string
+	StartIndex *uint64
+	TimeoutMS  *int64
+}
+
+// GetQueryResults returns a single page of the results of a query job,
+// JobComplete is false if the job did not finish within the timeout
+func (service *Service) GetQueryResults(config *GetQueryResultsConfig) (*QueryResultsResponse, *errortools.Error) {
+	return service.GetQueryResultsWithContext(context.Background(), config)
+}
+
+func (service *Service) GetQueryResultsWithContext(ctx context.Context, config *GetQueryResultsConfig) (*QueryResultsResponse, *errortools.Error) {
+	if config == nil {
+		return nil, errortools.ErrorMessage("GetQueryResultsConfig must not be a nil pointer")
+	}
+
+	values := url.Values{}
+
+	if location := service.jobLocation(config.Location); location != nil {
+		values.Set("location", *location)
+	}
+	if config.MaxResults != nil {
+		values.Set("maxResults", fmt.Sprintf("%v", *config.MaxResults))
+	}
+	if config.PageToken != nil {
+		values.Set("pageToken", *config.PageToken)
+	}
+	if config.StartIndex != nil {
+		values.Set("startIndex", fmt.Sprintf("%v", *config.StartIndex))
+	}
+	if config.TimeoutMS != nil {
+		values.Set("timeoutMs", fmt.Sprintf("%v", *config.TimeoutMS))
+	}
+
+	queryResultsResponse := QueryResultsResponse{}
+
+	requestConfig := go_http.RequestConfig{
+		Method:        http.MethodGet,
+		Url:           service.url(fmt.Sprintf("projects/%s/queries/%s?%s", config.ProjectId, config.JobId, values.Encode())),
+		ResponseModel: &queryResultsResponse,
+	}
+	_, _, e := service.httpRequest(ctx, OperationJobsGetQueryResults, &requestConfig)
+	if e != nil {
+		return nil, e
+	}
+
+	return &queryResultsResponse, nil
+}
+
+func (service *Service) QueryResultsPager(config *GetQueryResultsConfig) *Pager[TableRow] {
+	return service.QueryResultsPagerWithContext(context.Background(), config)
+}
+
+// QueryResultsPagerWithContext returns a pager over the rows of a finished query job,
+// MaxResults sets the page size
+func (service *Service) QueryResultsPagerWithContext(ctx context.Context, config *GetQueryResultsConfig) *Pager[TableRow] {
+	if config == nil {
+		return newErrorPager[TableRow](errortools.ErrorMessage("GetQueryResultsConfig must not be a nil pointer"))
+	}
+
+	pagerConfig := PagerConfig{
+		PageToken: config.PageToken,
+		PageSize:  config.MaxResults,
+	}
+
+	return NewPager(ctx, &pagerConfig, func(ctx context.Context, pageToken *string, pageSize *int) ([]TableRow, *string, *errortools.Error) {
+		_config := *config
+		_config.PageToken = pageToken
+		_config.MaxResults = pageSize
+		if pageToken != nil {
+			// startIndex only applies to the first page
+			_config.StartIndex = nil
+		}
+
+		queryResultsResponse, e := service.GetQueryResultsWithContext(ctx, &_config)
+		if e != nil {
+			return nil, nil, e
+		}
+		if !queryResultsResponse.JobComplete {
+			return nil, nil, errortools.ErrorMessagef("job %s is not complete", config.JobId)
+		}
+		return queryResultsResponse.Rows, queryResultsResponse.PageToken, nil
+	})
+}
+
+// queryResultRows returns all rows of a finished query job
+func (service *Service) queryResultRows(ctx context.Context, job *Job) ([]TableRow, *errortools.Error) {
+	rows, e := service.QueryResultsPagerWithContext(ctx, &GetQueryResultsConfig{
+		ProjectId: job.JobReference.ProjectID,
+		JobId:     job.JobReference.JobID,
+		Location:  jobReferenceLocation(job.JobReference),
+	}).All()
+	if e != nil {
+		return nil, e
+	}
+
+	return *rows, nil
+}
+
+const defaultWaitForJobPollInterval time.Duration = 2 * time.Second
+
+type WaitForJobConfig struct {
+	ProjectId    string
+	JobId        string
+	Location     *string
+	PollInterval *time.Duration
+}
+
+// WaitForJob polls the job until its state is DONE and returns the final job.
+// If the job finished with an error the job is returned along with that error.
+func (service *Service) WaitForJob(config *WaitForJobConfig) (*Job, *errortools.Error) {
+	return service.WaitForJobWithContext(context.Background(), config)
+}
+
+func (service *Service) WaitForJobWithContext(ctx context.Context, config *WaitForJobConfig) (*Job, *errortools.Error) {
+	if config == nil {
+		return nil, errortools.ErrorMessage("WaitForJobConfig must not be a nil pointer")
+	}
+
+	pollInterval := defaultWaitForJobPollInterval
+	if config.PollInterval != nil {
+		pollInterval = *config.PollInterval
+	}
+
+	for {
+		job, e := service.GetJobWithContext(ctx, &GetJobConfig{
+			ProjectId: config.ProjectId,
+			JobId:     config.JobId,
+			Location:  config.Location,
+		})
+		if e != nil {
+			return nil, e
+		}
+
+		if JobState(job.Status.State) == JobStateDone {
+			if job.Status.ErrorResult != nil {
+				return job, errortools.ErrorMessage(job.Status.ErrorResult.Message)
+			}
+			return job, nil
+		}
+
+		timer := time.NewTimer(pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return job, errortools.ErrorMessage(ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// RunJob inserts the job and waits for it to be done.
+// If no JobId is given one is generated, so that the insert can be retried safely.
+func (service *Service) RunJob(config *InsertJobConfig) (*Job, *errortools.Error) {
+	return service.RunJobWithContext(context.Background(), config)
+}
+
+func (service *Service) RunJobWithContext(ctx context.Context, config *InsertJobConfig) (*Job, *errortools.Error) {
+	if config == nil {
+		return nil, errortools.ErrorMessage("InsertJobConfig must not be a nil pointer")
+	}
+
+	_config := *config
+	if _config.JobId == nil {
+		jobId := NewJobId("job_")
+		_config.JobId = &jobId
+	}
+
+	job, e := service.InsertJobWithContext(ctx, &_config)
+	if e != nil {
+		return nil, e
+	}
+
+	return service.WaitForJobWithContext(ctx, &WaitForJobConfig{
+		ProjectId: job.JobReference.ProjectID,
+		JobId:     job.JobReference.JobID,
+		Location:  jobReferenceLocation(job.JobReference),
+	})
+}
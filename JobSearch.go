@@ -0,0 +1,168 @@
+package googlebigquery
+
+import (
+	"context"
+	"time"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+)
+
+// SearchJobsConfig combines the filters supported by jobs.list (States, creation time range, ParentJobId)
+// with filters that are applied client side to each listed job.
+type SearchJobsConfig struct {
+	ProjectId       string
+	AllUsers        *bool
+	States          []JobState
+	MinCreationTime *time.Time
+	MaxCreationTime *time.Time
+	ParentJobId     *string
+	// client side filters
+	JobTypes   []JobType
+	UserEmails []string
+	// Labels selects jobs having all labels, an empty value only requires the label key to be present
+	Labels map[string]string
+	// DestinationTable selects jobs writing to the table, leave TableID empty to match all tables in the dataset
+	DestinationTable *TableReference
+	// Failed selects jobs that did (true) or did not (false) finish with an error
+	Failed     *bool
+	MaxResults *int
+	MaxItems   *int
+}
+
+func (service *Service) SearchJobs(config *SearchJobsConfig) (*[]Job, *errortools.Error) {
+	return service.SearchJobsWithContext(context.Background(), config)
+}
+
+func (service *Service) SearchJobsWithContext(ctx context.Context, config *SearchJobsConfig) (*[]Job, *errortools.Error) {
+	return service.SearchJobsPagerWithContext(ctx, config).All()
+}
+
+func (service *Service) SearchJobsPager(config *SearchJobsConfig) *Pager[Job] {
+	return service.SearchJobsPagerWithContext(context.Background(), config)
+}
+
+func (service *Service) SearchJobsPagerWithContext(ctx context.Context, config *SearchJobsConfig) *Pager[Job] {
+	if config == nil {
+		return newErrorPager[Job](errortools.ErrorMessage("SearchJobsConfig must not be a nil pointer"))
+	}
+
+	getJobsConfig := GetJobsConfig{
+		ProjectId:   config.ProjectId,
+		AllUsers:    config.AllUsers,
+		MaxResults:  config.MaxResults,
+		ParentJobId: config.ParentJobId,
+	}
+	if len(config.States) > 0 {
+		states := config.States
+		getJobsConfig.StateFilter = &states
+	}
+	if config.MinCreationTime != nil {
+		minCreationTime := config.MinCreationTime.UnixMilli()
+		getJobsConfig.MinCreationTime = &minCreationTime
+	}
+	if config.MaxCreationTime != nil {
+		maxCreationTime := config.MaxCreationTime.UnixMilli()
+		getJobsConfig.MaxCreationTime = &maxCreationTime
+	}
+	// the client side filters need the job configuration
+	projection := JobProjectionFull
+	getJobsConfig.Projection = &projection
+
+	pagerConfig := PagerConfig{
+		MaxItems: config.MaxItems,
+	}
+
+	// the page size is not reduced to the remaining number of items since jobs are filtered after fetching
+	return NewPager(ctx, &pagerConfig, func(ctx context.Context, pageToken *string, _ *int) ([]Job, *string, *errortools.Error) {
+		jobsResponse, e := service.getJobsPage(ctx, &getJobsConfig, pageToken, config.MaxResults)
+		if e != nil {
+			return nil, nil, e
+		}
+
+		jobs := []Job{}
+		for _, job := range jobsResponse.Jobs {
+			if config.matches(&job) {
+				jobs = append(jobs, job)
+			}
+		}
+
+		return jobs, jobsResponse.NextPageToken, nil
+	})
+}
+
+func (config *SearchJobsConfig) matches(job *Job) bool {
+	if len(config.JobTypes) > 0 {
+		match := false
+		for _, jobType := range config.JobTypes {
+			if JobType(job.Configuration.JobType) == jobType {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return false
+		}
+	}
+
+	if len(config.UserEmails) > 0 {
+		match := false
+		for _, userEmail := range config.UserEmails {
+			if job.UserEmail == userEmail {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return false
+		}
+	}
+
+	if len(config.Labels) > 0 {
+		if job.Configuration.Labels == nil {
+			return false
+		}
+		for key, value := range config.Labels {
+			jobValue, ok := (*job.Configuration.Labels)[key]
+			if !ok || (value != "" && jobValue != value) {
+				return false
+			}
+		}
+	}
+
+	if config.DestinationTable != nil {
+		destinationTable := job.destinationTable()
+		if destinationTable == nil {
+			return false
+		}
+		if config.DestinationTable.ProjectID != "" && config.DestinationTable.ProjectID != destinationTable.ProjectID {
+			return false
+		}
+		if config.DestinationTable.DatasetID != "" && config.DestinationTable.DatasetID != destinationTable.DatasetID {
+			return false
+		}
+		if config.DestinationTable.TableID != "" && config.DestinationTable.TableID != destinationTable.TableID {
+			return false
+		}
+	}
+
+	if config.Failed != nil {
+		if *config.Failed != (job.Status.ErrorResult != nil) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (job *Job) destinationTable() *TableReference {
+	switch {
+	case job.Configuration.Query != nil:
+		return job.Configuration.Query.DestinationTable
+	case job.Configuration.Load != nil:
+		return &job.Configuration.Load.DestinationTable
+	case job.Configuration.Copy != nil:
+		return &job.Configuration.Copy.DestinationTable
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package googlebigquery
+
+import (
+	"context"
+	"sort"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+)
+
+// JobTree is a job with its child jobs, as created by scripts and multi-statement queries.
+// Children are ordered by creation time, i.e. in order of execution.
+type JobTree struct {
+	Job      Job
+	Children []*JobTree
+}
+
+// EvaluationKind returns STATEMENT or EXPRESSION for child jobs of a script, empty otherwise
+func (tree *JobTree) EvaluationKind() string {
+	if tree.Job.Statistics.ScriptStatistics == nil {
+		return ""
+	}
+	return tree.Job.Statistics.ScriptStatistics.EvaluationKind
+}
+
+// StackFrames returns the script stack frames, innermost first
+func (tree *JobTree) StackFrames() []ScriptStackFrame {
+	if tree.Job.Statistics.ScriptStatistics == nil {
+		return nil
+	}
+	return tree.Job.Statistics.ScriptStatistics.StackFrames
+}
+
+// Statement returns the text of the statement or expression the job evaluated
+func (tree *JobTree) Statement() string {
+	stackFrames := tree.StackFrames()
+	if len(stackFrames) == 0 {
+		return ""
+	}
+	return stackFrames[0].Text
+}
+
+func (tree *JobTree) ErrorResult() *ErrorProto {
+	return tree.Job.Status.ErrorResult
+}
+
+// Walk visits the tree depth first in order of execution, it stops if fn returns false
+func (tree *JobTree) Walk(fn func(tree *JobTree, depth int) bool) {
+	tree.walk(fn, 0)
+}
+
+func (tree *JobTree) walk(fn func(tree *JobTree, depth int) bool, depth int) bool {
+	if !fn(tree, depth) {
+		return false
+	}
+	for _, child := range tree.Children {
+		if !child.walk(fn, depth+1) {
+			return false
+		}
+	}
+	return true
+}
+
+// FailedStatement returns the deepest job that failed first, nil if no job failed.
+// For a failed script this is the statement that broke.
+func (tree *JobTree) FailedStatement() *JobTree {
+	for _, child := range tree.Children {
+		if failed := child.FailedStatement(); failed != nil {
+			return failed
+		}
+	}
+	if tree.ErrorResult() != nil {
+		return tree
+	}
+	return nil
+}
+
+type GetChildJobsConfig struct {
+	ParentJobReference JobReference
+	AllUsers           *bool
+}
+
+// GetChildJobs returns the direct child jobs of a parent job in order of execution
+func (service *Service) GetChildJobs(config *GetChildJobsConfig) (*[]Job, *errortools.Error) {
+	return service.GetChildJobsWithContext(context.Background(), config)
+}
+
+func (service *Service) GetChildJobsWithContext(ctx context.Context, config *GetChildJobsConfig) (*[]Job, *errortools.Error) {
+	if config == nil {
+		return nil, errortools.ErrorMessage("GetChildJobsConfig must not be a nil pointer")
+	}
+
+	projection := JobProjectionFull
+
+	jobs, e := service.GetJobsWithContext(ctx, &GetJobsConfig{
+		ProjectId:   config.ParentJobReference.ProjectID,
+		AllUsers:    config.AllUsers,
+		Projection:  &projection,
+		ParentJobId: &config.ParentJobReference.JobID,
+	})
+	if e != nil {
+		return nil, e
+	}
+
+	// jobs are listed in reverse chronological order
+	sort.SliceStable(*jobs, func(i, j int) bool {
+		a, b := (*jobs)[i].Statistics.CreationTime.Value(), (*jobs)[j].Statistics.CreationTime.Value()
+		if a != b {
+			return a < b
+		}
+		return (*jobs)[i].JobReference.JobID < (*jobs)[j].JobReference.JobID
+	})
+
+	return jobs, nil
+}
+
+// GetJobTree returns the parent job with all its descendant jobs
+func (service *Service) GetJobTree(config *GetChildJobsConfig) (*JobTree, *errortools.Error) {
+	return service.GetJobTreeWithContext(context.Background(), config)
+}
+
+func (service *Service) GetJobTreeWithContext(ctx context.Context, config *GetChildJobsConfig) (*JobTree, *errortools.Error) {
+	if config == nil {
+		return nil, errortools.ErrorMessage("GetChildJobsConfig must not be a nil pointer")
+	}
+
+	job, e := service.GetJobWithContext(ctx, &GetJobConfig{
+		ProjectId: config.ParentJobReference.ProjectID,
+		JobId:     config.ParentJobReference.JobID,
+		Location:  jobReferenceLocation(config.ParentJobReference),
+	})
+	if e != nil {
+		return nil, e
+	}
+
+	tree := JobTree{Job: *job}
+
+	e = service.addChildJobs(ctx, &tree, config.AllUsers)
+	if e != nil {
+		return nil, e
+	}
+
+	return &tree, nil
+}
+
+func (service *Service) addChildJobs(ctx context.Context, tree *JobTree, allUsers *bool) *errortools.Error {
+	if tree.Job.Statistics.NumChildJobs == nil || tree.Job.Statistics.NumChildJobs.Value() == 0 {
+		return nil
+	}
+
+	children, e := service.GetChildJobsWithContext(ctx, &GetChildJobsConfig{
+		ParentJobReference: tree.Job.JobReference,
+		AllUsers:           allUsers,
+	})
+	if e != nil {
+		return e
+	}
+
+	for _, child := range *children {
+		childTree := JobTree{Job: child}
+
+		e = service.addChildJobs(ctx, &childTree, allUsers)
+		if e != nil {
+			return e
+		}
+
+		tree.Children = append(tree.Children, &childTree)
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package googlebigquery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"reflect"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+	go_http "github.com/leapforce-libraries/go_http"
+)
+
+const (
+	CreateDispositionCreateIfNeeded  string = "CREATE_IF_NEEDED"
+	CreateDispositionCreateNever     string = "CREATE_NEVER"
+	WriteDispositionWriteAppend      string = "WRITE_APPEND"
+	WriteDispositionWriteEmpty       string = "WRITE_EMPTY"
+	WriteDispositionWriteTruncate    string = "WRITE_TRUNCATE"
+	SourceFormatAvro                 string = "AVRO"
+	SourceFormatCSV                  string = "CSV"
+	SourceFormatNewlineDelimitedJSON string = "NEWLINE_DELIMITED_JSON"
+	SourceFormatORC                  string = "ORC"
+	SourceFormatParquet              string = "PARQUET"
+)
+
+type LoadRowsConfig struct {
+	ProjectId        string
+	Location         *string
+	DestinationTable TableReference
+	// Schema is required if the destination table does not exist yet
+	Schema            *TableSchema
+	CreateDisposition *string
+	WriteDisposition  *string
+	// Rows must be a slice, each element is marshalled to a JSON object,
+	// so fields are named after their json tags
+	Rows                interface{}
+	IgnoreUnknownValues *bool
+}
+
+// LoadRows uploads the rows as newline delimited JSON with a load job and waits for the job to be done
+func (service *Service) LoadRows(config *LoadRowsConfig) (*Job, *errortools.Error) {
+	return service.LoadRowsWithContext(context.Background(), config)
+}
+
+func (service *Service) LoadRowsWithContext(ctx context.Context, config *LoadRowsConfig) (*Job, *errortools.Error) {
+	if config == nil {
+		return nil, errortools.ErrorMessage("LoadRowsConfig must not be a nil pointer")
+	}
+
+	data, e := newlineDelimitedJSON(config.Rows)
+	if e != nil {
+		return nil, e
+	}
+
+	sourceFormat := SourceFormatNewlineDelimitedJSON
+	jobId := NewJobId("load_")
+
+	job, e := service.uploadJob(ctx, &InsertJobConfig{
+		ProjectId: config.ProjectId,
+		JobId:     &jobId,
+		Location:  config.Location,
+		Configuration: JobConfiguration{
+			Load: &JobConfigurationLoad{
+				Schema:              config.Schema,
+				DestinationTable:    config.DestinationTable,
+				CreateDisposition:   config.CreateDisposition,
+				WriteDisposition:    config.WriteDisposition,
+				SourceFormat:        &sourceFormat,
+				IgnoreUnknownValues: config.IgnoreUnknownValues,
+			},
+		},
+	}, data)
+	if e != nil {
+		return nil, e
+	}
+
+	return service.WaitForJobWithContext(ctx, &WaitForJobConfig{
+		ProjectId: job.JobReference.ProjectID,
+		JobId:     job.JobReference.JobID,
+		Location:  jobReferenceLocation(job.JobReference),
+	})
+}
+
+func newlineDelimitedJSON(rows interface{}) ([]byte, *errortools.Error) {
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, errortools.ErrorMessagef("rows must be a slice, not %T", rows)
+	}
+
+	buffer := bytes.Buffer{}
+	encoder := json.NewEncoder(&buffer)
+
+	for i := 0; i < v.Len(); i++ {
+		// Encode terminates each value with a newline
+		err := encoder.Encode(v.Index(i).Interface())
+		if err != nil {
+			return nil, errortools.ErrorMessagef("row %v: %s", i, err.Error())
+		}
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// uploadJob inserts a job along with its data using a multipart upload
+func (service *Service) uploadJob(ctx context.Context, config *InsertJobConfig, data []byte) (*Job, *errortools.Error) {
+	location := service.insertJobLocation(ctx, config)
+
+	metadata := insertJobRequest{
+		Configuration: config.Configuration,
+	}
+	if config.JobId != nil || location != nil {
+		metadata.JobReference = &JobReference{
+			ProjectID: config.ProjectId,
+		}
+		if config.JobId != nil {
+			metadata.JobReference.JobID = *config.JobId
+		}
+		if location != nil {
+			metadata.JobReference.Location = *location
+		}
+	}
+
+	b, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, errortools.ErrorMessage(err)
+	}
+
+	body := bytes.Buffer{}
+	writer := multipart.NewWriter(&body)
+
+	for _, part := range []struct {
+		contentType string
+		data        []byte
+	}{
+		{"application/json; charset=UTF-8", b},
+		{"application/octet-stream", data},
+	} {
+		partWriter, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, errortools.ErrorMessage(err)
+		}
+		_, err = partWriter.Write(part.data)
+		if err != nil {
+			return nil, errortools.ErrorMessage(err)
+		}
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, errortools.ErrorMessage(err)
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", fmt.Sprintf("multipart/related; boundary=%s", writer.Boundary()))
+
+	bodyRaw := body.Bytes()
+	job := Job{}
+
+	requestConfig := go_http.RequestConfig{
+		Method:            http.MethodPost,
+		Url:               service.uploadUrl(fmt.Sprintf("projects/%s/jobs?uploadType=multipart", config.ProjectId)),
+		BodyRaw:           &bodyRaw,
+		NonDefaultHeaders: &header,
+		ResponseModel:     &job,
+	}
+	idempotent := metadata.JobReference != nil && metadata.JobReference.JobID != ""
+	_, _, e := service.httpRequestIdempotent(ctx, OperationJobsInsert, idempotent, &requestConfig)
+	if e != nil {
+		return nil, e
+	}
+
+	return &job, nil
+}
@@ -0,0 +1,103 @@
+package googlebigquery
+
+import (
+	"context"
+)
+
+// SetDefaultLocation sets the location used for job operations that do not specify one.
+// If no default location is set, inserted jobs get the location of the dataset they target.
+func (service *Service) SetDefaultLocation(location string) {
+	service.defaultLocation = &location
+}
+
+func (service *Service) DefaultLocation() *string {
+	return service.defaultLocation
+}
+
+// jobLocation returns location, or the default location if location is nil or empty
+func (service *Service) jobLocation(location *string) *string {
+	if location != nil && *location != "" {
+		return location
+	}
+	return service.defaultLocation
+}
+
+// jobReferenceLocation returns the location of the job, or nil if the job reference has none
+func jobReferenceLocation(jobReference JobReference) *string {
+	if jobReference.Location == "" {
+		return nil
+	}
+	location := jobReference.Location
+	return &location
+}
+
+// insertJobLocation returns the location to insert the job in, or nil to let the server pick it
+func (service *Service) insertJobLocation(ctx context.Context, config *InsertJobConfig) *string {
+	if location := service.jobLocation(config.Location); location != nil {
+		return location
+	}
+
+	datasetReference := config.Configuration.targetDataset()
+	if datasetReference == nil {
+		return nil
+	}
+	if datasetReference.ProjectID == "" {
+		datasetReference.ProjectID = config.ProjectId
+	}
+
+	return service.datasetLocation(ctx, datasetReference)
+}
+
+// datasetLocation returns the location of the dataset, which is looked up once per dataset.
+// If the lookup fails, e.g. because the dataset is created by the job itself, it returns nil
+// so that the server picks the location, and a later job looks the dataset up again.
+func (service *Service) datasetLocation(ctx context.Context, datasetReference *DatasetReference) *string {
+	key := datasetReference.ProjectID + "." + datasetReference.DatasetID
+
+	if location, ok := service.datasetLocations.Load(key); ok {
+		l := location.(string)
+		return &l
+	}
+
+	dataset, e := service.GetDatasetWithContext(ctx, &GetDatasetConfig{
+		ProjectId: datasetReference.ProjectID,
+		DatasetId: datasetReference.DatasetID,
+	})
+	if e != nil || dataset.Location == "" {
+		return nil
+	}
+
+	service.datasetLocations.Store(key, dataset.Location)
+
+	return &dataset.Location
+}
+
+// targetDataset returns the dataset the job reads from or writes to
+func (configuration *JobConfiguration) targetDataset() *DatasetReference {
+	var tableReference *TableReference
+
+	switch {
+	case configuration.Query != nil:
+		if configuration.Query.DestinationTable != nil {
+			tableReference = configuration.Query.DestinationTable
+		} else if configuration.Query.DefaultDataset != nil {
+			datasetReference := *configuration.Query.DefaultDataset
+			return &datasetReference
+		}
+	case configuration.Load != nil:
+		tableReference = &configuration.Load.DestinationTable
+	case configuration.Copy != nil:
+		tableReference = &configuration.Copy.DestinationTable
+	case configuration.Extract != nil:
+		tableReference = configuration.Extract.SourceTable
+	}
+
+	if tableReference == nil || tableReference.DatasetID == "" {
+		return nil
+	}
+
+	return &DatasetReference{
+		ProjectID: tableReference.ProjectID,
+		DatasetID: tableReference.DatasetID,
+	}
+}
@@ -0,0 +1,104 @@
+package googlebigquery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+	go_http "github.com/leapforce-libraries/go_http"
+	go_types "github.com/leapforce-libraries/go_types"
+)
+
+type ModelsResponse struct {
+	NextPageToken *string `json:"nextPageToken"`
+	Models        []Model `json:"models"`
+}
+
+type Model struct {
+	Etag             string                `json:"etag"`
+	ModelReference   ModelReference        `json:"modelReference"`
+	CreationTime     go_types.Int64String  `json:"creationTime"`
+	LastModifiedTime go_types.Int64String  `json:"lastModifiedTime"`
+	Description      *string               `json:"description"`
+	FriendlyName     *string               `json:"friendlyName"`
+	Labels           *map[string]string    `json:"labels"`
+	ExpirationTime   *go_types.Int64String `json:"expirationTime"`
+	Location         string                `json:"location"`
+	ModelType        string                `json:"modelType"`
+}
+
+type GetModelsConfig struct {
+	ProjectId  string
+	DatasetId  string
+	MaxResults *int
+	MaxItems   *int
+	PageToken  *string
+}
+
+func (service *Service) GetModels(config *GetModelsConfig) (*[]Model, *errortools.Error) {
+	return service.GetModelsWithContext(context.Background(), config)
+}
+
+func (service *Service) GetModelsWithContext(ctx context.Context, config *GetModelsConfig) (*[]Model, *errortools.Error) {
+	return service.ModelsPagerWithContext(ctx, config).All()
+}
+
+// IterateModels returns an iterator that fetches the models page by page
+func (service *Service) IterateModels(config *GetModelsConfig) *Iterator[Model] {
+	return service.IterateModelsWithContext(context.Background(), config)
+}
+
+func (service *Service) IterateModelsWithContext(ctx context.Context, config *GetModelsConfig) *Iterator[Model] {
+	return service.ModelsPagerWithContext(ctx, config).Iterator()
+}
+
+func (service *Service) ModelsPager(config *GetModelsConfig) *Pager[Model] {
+	return service.ModelsPagerWithContext(context.Background(), config)
+}
+
+func (service *Service) ModelsPagerWithContext(ctx context.Context, config *GetModelsConfig) *Pager[Model] {
+	if config == nil {
+		return newErrorPager[Model](errortools.ErrorMessage("GetModelsConfig must not be a nil pointer"))
+	}
+
+	pagerConfig := PagerConfig{
+		PageToken: config.PageToken,
+		PageSize:  config.MaxResults,
+		MaxItems:  config.MaxItems,
+	}
+
+	return NewPager(ctx, &pagerConfig, func(ctx context.Context, pageToken *string, pageSize *int) ([]Model, *string, *errortools.Error) {
+		modelsResponse, e := service.getModelsPage(ctx, config, pageToken, pageSize)
+		if e != nil {
+			return nil, nil, e
+		}
+		return modelsResponse.Models, modelsResponse.NextPageToken, nil
+	})
+}
+
+func (service *Service) getModelsPage(ctx context.Context, config *GetModelsConfig, pageToken *string, pageSize *int) (*ModelsResponse, *errortools.Error) {
+	values := url.Values{}
+
+	if pageSize != nil {
+		values.Set("maxResults", fmt.Sprintf("%v", *pageSize))
+	}
+	if pageToken != nil {
+		values.Set("pageToken", *pageToken)
+	}
+
+	modelsResponse := ModelsResponse{}
+
+	requestConfig := go_http.RequestConfig{
+		Method:        http.MethodGet,
+		Url:           service.url(fmt.Sprintf("projects/%s/datasets/%s/models?%s", config.ProjectId, config.DatasetId, values.Encode())),
+		ResponseModel: &modelsResponse,
+	}
+	_, _, e := service.httpRequest(ctx, OperationModelsList, &requestConfig)
+	if e != nil {
+		return nil, e
+	}
+
+	return &modelsResponse, nil
+}
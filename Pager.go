@@ -0,0 +1,168 @@
+package googlebigquery
+
+import (
+	"context"
+	"sync"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+)
+
+// FetchPageFunc fetches the page identified by pageToken (nil for the first page),
+// requesting at most pageSize items if pageSize is not nil.
+// It returns the items and the token of the next page, nil if there is none.
+type FetchPageFunc[T any] func(ctx context.Context, pageToken *string, pageSize *int) ([]T, *string, *errortools.Error)
+
+type PagerConfig struct {
+	PageToken *string // page to start at
+	PageSize  *int    // maximum number of items requested per page
+	MaxItems  *int    // maximum number of items returned in total
+}
+
+// Pager walks the pages of a list endpoint.
+// It is safe for concurrent use: each call to NextPage hands out the next page exactly once.
+type Pager[T any] struct {
+	mutex         sync.Mutex
+	ctx           context.Context
+	fetch         FetchPageFunc[T]
+	pageSize      *int
+	maxItems      *int
+	itemCount     int
+	pageToken     *string
+	nextPageToken *string
+	done          bool
+	e             *errortools.Error
+}
+
+func NewPager[T any](ctx context.Context, config *PagerConfig, fetch FetchPageFunc[T]) *Pager[T] {
+	pager := Pager[T]{
+		ctx:   ctx,
+		fetch: fetch,
+	}
+	if config != nil {
+		pager.nextPageToken = config.PageToken
+		pager.pageSize = config.PageSize
+		pager.maxItems = config.MaxItems
+	}
+
+	return &pager
+}
+
+func newErrorPager[T any](e *errortools.Error) *Pager[T] {
+	return &Pager[T]{
+		done: true,
+		e:    e,
+	}
+}
+
+// NextPage fetches the next page. It returns nil once all pages have been fetched.
+func (pager *Pager[T]) NextPage() ([]T, *errortools.Error) {
+	pager.mutex.Lock()
+	defer pager.mutex.Unlock()
+
+	for {
+		if pager.e != nil {
+			return nil, pager.e
+		}
+		if pager.done {
+			return nil, nil
+		}
+
+		pageSize := pager.pageSize
+		if pager.maxItems != nil {
+			remaining := *pager.maxItems - pager.itemCount
+			if remaining <= 0 {
+				pager.done = true
+				return nil, nil
+			}
+			if pageSize == nil || *pageSize > remaining {
+				pageSize = &remaining
+			}
+		}
+
+		items, nextPageToken, e := pager.fetch(pager.ctx, pager.nextPageToken, pageSize)
+		if e != nil {
+			pager.e = e
+			return nil, e
+		}
+
+		pager.pageToken = pager.nextPageToken
+		pager.nextPageToken = nextPageToken
+		if nextPageToken == nil {
+			pager.done = true
+		}
+
+		if pager.maxItems != nil && pager.itemCount+len(items) > *pager.maxItems {
+			items = items[:*pager.maxItems-pager.itemCount]
+		}
+		pager.itemCount += len(items)
+
+		// skip empty pages, the api may return these while more pages follow
+		if len(items) > 0 {
+			return items, nil
+		}
+	}
+}
+
+// ForEachPage calls onPage for each remaining page and stops at the first error
+func (pager *Pager[T]) ForEachPage(onPage func(items []T) *errortools.Error) *errortools.Error {
+	for {
+		items, e := pager.NextPage()
+		if e != nil {
+			return e
+		}
+		if items == nil {
+			return nil
+		}
+
+		e = onPage(items)
+		if e != nil {
+			return e
+		}
+	}
+}
+
+// All fetches all remaining pages and returns their items
+func (pager *Pager[T]) All() (*[]T, *errortools.Error) {
+	all := []T{}
+
+	e := pager.ForEachPage(func(items []T) *errortools.Error {
+		all = append(all, items...)
+		return nil
+	})
+	if e != nil {
+		return nil, e
+	}
+
+	return &all, nil
+}
+
+// Iterator returns an iterator over the items of the remaining pages
+func (pager *Pager[T]) Iterator() *Iterator[T] {
+	return &Iterator[T]{pager: pager}
+}
+
+// PageToken returns the token of the last fetched page, nil if that is the first page
+func (pager *Pager[T]) PageToken() *string {
+	pager.mutex.Lock()
+	defer pager.mutex.Unlock()
+
+	return pager.pageToken
+}
+
+// NextPageToken returns the token of the page that is fetched next, nil if there are no more pages
+func (pager *Pager[T]) NextPageToken() *string {
+	pager.mutex.Lock()
+	defer pager.mutex.Unlock()
+
+	if pager.done {
+		return nil
+	}
+	return pager.nextPageToken
+}
+
+func (pager *Pager[T]) Done() bool {
+	pager.mutex.Lock()
+	defer pager.mutex.Unlock()
+
+	return pager.done && pager.e == nil
+}
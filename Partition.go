@@ -0,0 +1,298 @@
+package googlebigquery
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+)
+
+const (
+	PartitionIdNull          string = "__NULL__"
+	PartitionIdUnpartitioned string = "__UNPARTITIONED__"
+)
+
+// Partition describes a single partition of a table as listed in INFORMATION_SCHEMA.PARTITIONS
+type Partition struct {
+	PartitionId        string
+	TotalRows          *int64
+	TotalLogicalBytes  *int64
+	TotalBillableBytes *int64
+	LastModifiedTime   *time.Time
+	StorageTier        *string
+}
+
+type GetPartitionsConfig struct {
+	// ProjectId of the project running the query, defaults to the project of Table
+	ProjectId *string
+	// Table defaults to a dataset of ProjectId if it has no project
+	Table TableReference
+}
+
+func (service *Service) GetPartitions(config *GetPartitionsConfig) (*[]Partition, *errortools.Error) {
+	return service.GetPartitionsWithContext(context.Background(), config)
+}
+
+// GetPartitionsWithContext queries INFORMATION_SCHEMA.PARTITIONS of the table's dataset,
+// partitions are returned in ascending order of their id
+func (service *Service) GetPartitionsWithContext(ctx context.Context, config *GetPartitionsConfig) (*[]Partition, *errortools.Error) {
+	if config == nil {
+		return nil, errortools.ErrorMessage("GetPartitionsConfig must not be a nil pointer")
+	}
+
+	projectId := config.Table.ProjectID
+	if config.ProjectId != nil {
+		projectId = *config.ProjectId
+	}
+
+	// a table without project lives in the project running the query
+	tableProjectId := config.Table.ProjectID
+	if tableProjectId == "" {
+		tableProjectId = projectId
+	}
+
+	partitionsView := TableReference{
+		ProjectID: tableProjectId,
+		DatasetID: config.Table.DatasetID,
+		TableID:   "INFORMATION_SCHEMA.PARTITIONS",
+	}
+
+	query := JobConfigurationQuery{
+		Query: fmt.Sprintf("SELECT partition_id, total_rows, total_logical_bytes, total_billable_bytes, UNIX_MICROS(last_modified_time) AS last_modified_time, storage_tier FROM %s WHERE table_name = @table_name ORDER BY partition_id", quoteTableReference(partitionsView)),
+		DefaultDataset: &DatasetReference{
+			ProjectID: tableProjectId,
+			DatasetID: config.Table.DatasetID,
+		},
+	}
+	e := query.SetNamedParameters(map[string]interface{}{"table_name": config.Table.TableID})
+	if e != nil {
+		return nil, e
+	}
+
+	job, e := service.RunJobWithContext(ctx, &InsertJobConfig{
+		ProjectId: projectId,
+		Configuration: JobConfiguration{
+			Query: &query,
+		},
+	})
+	if e != nil {
+		return nil, e
+	}
+
+	rows, e := service.queryResultRows(ctx, job)
+	if e != nil {
+		return nil, e
+	}
+
+	partitions := []Partition{}
+	for _, row := range rows {
+		partition, e := partitionFromRow(&row)
+		if e != nil {
+			return nil, e
+		}
+		partitions = append(partitions, *partition)
+	}
+
+	return &partitions, nil
+}
+
+func partitionFromRow(row *TableRow) (*Partition, *errortools.Error) {
+	if len(row.F) != 6 {
+		return nil, errortools.ErrorMessagef("unexpected number of columns (%v) in partition row", len(row.F))
+	}
+
+	partitionId, _ := row.F[0].V.(string)
+	partition := Partition{
+		PartitionId: partitionId,
+	}
+
+	var e *errortools.Error
+	partition.TotalRows, e = int64Cell(row.F[1])
+	if e != nil {
+		return nil, e
+	}
+	partition.TotalLogicalBytes, e = int64Cell(row.F[2])
+	if e != nil {
+		return nil, e
+	}
+	partition.TotalBillableBytes, e = int64Cell(row.F[3])
+	if e != nil {
+		return nil, e
+	}
+	lastModifiedTime, e := int64Cell(row.F[4])
+	if e != nil {
+		return nil, e
+	}
+	if lastModifiedTime != nil {
+		t := time.UnixMicro(*lastModifiedTime).UTC()
+		partition.LastModifiedTime = &t
+	}
+	if storageTier, ok := row.F[5].V.(string); ok {
+		partition.StorageTier = &storageTier
+	}
+
+	return &partition, nil
+}
+
+// int64Cell parses an INT64 cell, which the API returns as a string
+func int64Cell(cell TableCell) (*int64, *errortools.Error) {
+	s, ok := cell.V.(string)
+	if !ok {
+		return nil, nil
+	}
+
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil, errortools.ErrorMessage(err)
+	}
+
+	return &i, nil
+}
+
+// partitionDecorator returns the table reference addressing a single partition, e.g. table$20240101
+func partitionDecorator(table TableReference, partitionId string) (*TableReference, *errortools.Error) {
+	if partitionId == "" {
+		return nil, errortools.ErrorMessage("PartitionId must not be empty")
+	}
+	if partitionId == PartitionIdNull || partitionId == PartitionIdUnpartitioned {
+		return nil, errortools.ErrorMessagef("partition %s cannot be addressed with a partition decorator", partitionId)
+	}
+
+	return &TableReference{
+		ProjectID: table.ProjectID,
+		DatasetID: table.DatasetID,
+		TableID:   fmt.Sprintf("%s$%s", table.TableID, partitionId),
+	}, nil
+}
+
+type DeletePartitionConfig struct {
+	Table       TableReference
+	PartitionId string
+}
+
+func (service *Service) DeletePartition(config *DeletePartitionConfig) *errortools.Error {
+	return service.DeletePartitionWithContext(context.Background(), config)
+}
+
+func (service *Service) DeletePartitionWithContext(ctx context.Context, config *DeletePartitionConfig) *errortools.Error {
+	if config == nil {
+		return errortools.ErrorMessage("DeletePartitionConfig must not be a nil pointer")
+	}
+
+	partition, e := partitionDecorator(config.Table, config.PartitionId)
+	if e != nil {
+		return e
+	}
+
+	return service.DeleteTableWithContext(ctx, &GetTableConfig{
+		ProjectId: partition.ProjectID,
+		DatasetId: partition.DatasetID,
+		TableId:   partition.TableID,
+	})
+}
+
+type OverwritePartitionConfig struct {
+	ProjectId   string
+	Location    *string
+	Table       TableReference
+	PartitionId string
+	// Query must only return rows that belong to the partition
+	Query string
+	// QueryParameters are passed as named parameters of the query
+	QueryParameters map[string]interface{}
+}
+
+func (service *Service) OverwritePartition(config *OverwritePartitionConfig) (*Job, *errortools.Error) {
+	return service.OverwritePartitionWithContext(context.Background(), config)
+}
+
+// OverwritePartitionWithContext replaces the contents of the partition by the results of the query
+func (service *Service) OverwritePartitionWithContext(ctx context.Context, config *OverwritePartitionConfig) (*Job, *errortools.Error) {
+	if config == nil {
+		return nil, errortools.ErrorMessage("OverwritePartitionConfig must not be a nil pointer")
+	}
+
+	partition, e := partitionDecorator(config.Table, config.PartitionId)
+	if e != nil {
+		return nil, e
+	}
+
+	writeDisposition := WriteDispositionWriteTruncate
+
+	query := JobConfigurationQuery{
+		Query:            config.Query,
+		DestinationTable: partition,
+		WriteDisposition: &writeDisposition,
+	}
+	if len(config.QueryParameters) > 0 {
+		e = query.SetNamedParameters(config.QueryParameters)
+		if e != nil {
+			return nil, e
+		}
+	}
+
+	return service.RunJobWithContext(ctx, &InsertJobConfig{
+		ProjectId: config.ProjectId,
+		Location:  config.Location,
+		Configuration: JobConfiguration{
+			Query: &query,
+		},
+	})
+}
+
+type CopyPartitionConfig struct {
+	ProjectId         string
+	Location          *string
+	SourceTable       TableReference
+	SourcePartitionId string
+	DestinationTable  TableReference
+	// DestinationPartitionId defaults to SourcePartitionId
+	DestinationPartitionId *string
+	// WriteDisposition defaults to WRITE_TRUNCATE
+	WriteDisposition *string
+}
+
+func (service *Service) CopyPartition(config *CopyPartitionConfig) (*Job, *errortools.Error) {
+	return service.CopyPartitionWithContext(context.Background(), config)
+}
+
+// CopyPartitionWithContext copies a single partition to a partition of the destination table,
+// both tables must be partitioned the same way
+func (service *Service) CopyPartitionWithContext(ctx context.Context, config *CopyPartitionConfig) (*Job, *errortools.Error) {
+	if config == nil {
+		return nil, errortools.ErrorMessage("CopyPartitionConfig must not be a nil pointer")
+	}
+
+	source, e := partitionDecorator(config.SourceTable, config.SourcePartitionId)
+	if e != nil {
+		return nil, e
+	}
+
+	destinationPartitionId := config.SourcePartitionId
+	if config.DestinationPartitionId != nil {
+		destinationPartitionId = *config.DestinationPartitionId
+	}
+	destination, e := partitionDecorator(config.DestinationTable, destinationPartitionId)
+	if e != nil {
+		return nil, e
+	}
+
+	writeDisposition := WriteDispositionWriteTruncate
+	if config.WriteDisposition != nil {
+		writeDisposition = *config.WriteDisposition
+	}
+
+	return service.RunJobWithContext(ctx, &InsertJobConfig{
+		ProjectId: config.ProjectId,
+		Location:  config.Location,
+		Configuration: JobConfiguration{
+			Copy: &JobConfigurationTableCopy{
+				SourceTable:      *source,
+				DestinationTable: *destination,
+				WriteDisposition: &writeDisposition,
+			},
+		},
+	})
+}
@@ -0,0 +1,92 @@
+package googlebigquery
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// ProtoFile renders the schema as the source of a .proto file, with the descriptions of the fields as comments.
+// The package statement is omitted if packageName is empty.
+func (schema *ProtoSchema) ProtoFile(packageName string) string {
+	// the fields of the nested messages, by message name
+	messageFields := map[string][]TableFieldSchema{}
+	nestedFields(schema.descriptor, schema.descriptor, schema.fields, messageFields)
+
+	var body strings.Builder
+	usesColumnName := writeProtoMessage(&body, schema.descriptor, schema.fields, schema.descriptor.NestedType, messageFields, "")
+
+	var file strings.Builder
+	file.WriteString("syntax = \"proto2\";\n\n")
+	if packageName != "" {
+		file.WriteString(fmt.Sprintf("package %s;\n\n", packageName))
+	}
+	if usesColumnName {
+		file.WriteString(fmt.Sprintf("import %s;\n\n", strconv.Quote(storagepb.File_google_cloud_bigquery_storage_v1_annotations_proto.Path())))
+	}
+	file.WriteString(body.String())
+
+	return file.String()
+}
+
+func nestedFields(root *descriptorpb.DescriptorProto, message *descriptorpb.DescriptorProto, fields []TableFieldSchema, messageFields map[string][]TableFieldSchema) {
+	for i, fieldDescriptor := range message.Field {
+		if fieldDescriptor.TypeName == nil || i >= len(fields) {
+			continue
+		}
+		messageFields[fieldDescriptor.GetTypeName()] = fields[i].Fields
+
+		for _, nested := range root.NestedType {
+			if nested.GetName() == fieldDescriptor.GetTypeName() {
+				nestedFields(root, nested, fields[i].Fields, messageFields)
+				break
+			}
+		}
+	}
+}
+
+// writeProtoMessage writes message and the nested messages, and reports whether the column name annotation was used
+func writeProtoMessage(b *strings.Builder, message *descriptorpb.DescriptorProto, fields []TableFieldSchema, nested []*descriptorpb.DescriptorProto, messageFields map[string][]TableFieldSchema, indent string) bool {
+	usesColumnName := false
+
+	b.WriteString(fmt.Sprintf("%smessage %s {\n", indent, message.GetName()))
+
+	for i, fieldDescriptor := range message.Field {
+		if i < len(fields) && fields[i].Description != "" {
+			for _, line := range strings.Split(fields[i].Description, "\n") {
+				b.WriteString(strings.TrimRight(fmt.Sprintf("%s  // %s", indent, line), " "))
+				b.WriteString("\n")
+			}
+		}
+
+		label := strings.ToLower(strings.TrimPrefix(fieldDescriptor.GetLabel().String(), "LABEL_"))
+		fieldType := strings.ToLower(strings.TrimPrefix(fieldDescriptor.GetType().String(), "TYPE_"))
+		if fieldDescriptor.TypeName != nil {
+			fieldType = fieldDescriptor.GetTypeName()
+		}
+
+		options := ""
+		if fieldDescriptor.Options != nil && proto.HasExtension(fieldDescriptor.Options, storagepb.E_ColumnName) {
+			columnName := proto.GetExtension(fieldDescriptor.Options, storagepb.E_ColumnName).(string)
+			options = fmt.Sprintf(" [(%s) = %s]", storagepb.E_ColumnName.TypeDescriptor().FullName(), strconv.Quote(columnName))
+			usesColumnName = true
+		}
+
+		b.WriteString(fmt.Sprintf("%s  %s %s %s = %v%s;\n", indent, label, fieldType, fieldDescriptor.GetName(), fieldDescriptor.GetNumber(), options))
+	}
+
+	for _, nestedMessage := range nested {
+		b.WriteString("\n")
+		if writeProtoMessage(b, nestedMessage, messageFields[nestedMessage.GetName()], nil, messageFields, indent+"  ") {
+			usesColumnName = true
+		}
+	}
+
+	b.WriteString(fmt.Sprintf("%s}\n", indent))
+
+	return usesColumnName
+}
@@ -0,0 +1,550 @@
+package googlebigquery
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"cloud.google.com/go/civil"
+	errortools "github.com/leapforce-libraries/go_errortools"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	numericScale    int = 9
+	bigNumericScale int = 38
+)
+
+var typeOfTextMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// ProtoSchema is the protobuf representation of a table schema, as expected by the Storage Write API
+type ProtoSchema struct {
+	fields     []TableFieldSchema
+	descriptor *descriptorpb.DescriptorProto
+	message    protoreflect.MessageDescriptor
+}
+
+// NewProtoSchema builds a self-contained proto2 message descriptor named name for the fields.
+// The messages of RECORD fields are all nested in the root message.
+//
+// Field numbers follow the order of the fields, starting at 1. REQUIRED fields are required,
+// REPEATED fields repeated and all other fields optional. Types are mapped as follows:
+//
+//	INTEGER, INT64                         int64
+//	FLOAT, FLOAT64                         double
+//	BOOLEAN, BOOL                          bool
+//	STRING, JSON, GEOGRAPHY, INTERVAL      string
+//	BYTES                                  bytes
+//	NUMERIC, BIGNUMERIC                    bytes, the little-endian two's complement of the value scaled by 10^9 resp. 10^38
+//	TIMESTAMP                              int64, microseconds since the epoch
+//	DATE                                   int32, days since the epoch
+//	DATETIME, TIME                         string, e.g. "2024-01-31 12:00:00.123456" and "12:00:00.123456"
+//	RECORD, STRUCT                         nested message
+//
+// Field names that are not valid proto identifiers are replaced by col_ followed by the hex encoded name,
+// the original name is passed in the column_name annotation of the Storage API.
+func NewProtoSchema(fields []TableFieldSchema, name string) (*ProtoSchema, *errortools.Error) {
+	root := descriptorpb.DescriptorProto{Name: proto.String(name)}
+
+	usesColumnName, e := addProtoFields(&root, &root, fields, map[string]bool{name: true})
+	if e != nil {
+		return nil, e
+	}
+
+	file := descriptorpb.FileDescriptorProto{
+		Name:        proto.String(fmt.Sprintf("%s.proto", name)),
+		Syntax:      proto.String("proto2"),
+		MessageType: []*descriptorpb.DescriptorProto{&root},
+	}
+	if usesColumnName {
+		file.Dependency = []string{storagepb.File_google_cloud_bigquery_storage_v1_annotations_proto.Path()}
+	}
+
+	fileDescriptor, err := protodesc.NewFile(&file, protoregistry.GlobalFiles)
+	if err != nil {
+		return nil, errortools.ErrorMessage(err)
+	}
+
+	return &ProtoSchema{
+		fields:     fields,
+		descriptor: &root,
+		message:    fileDescriptor.Messages().Get(0),
+	}, nil
+}
+
+// addProtoFields adds the fields to message, and the messages of RECORD fields to root.
+// It reports whether a field name needed the column name annotation.
+func addProtoFields(root *descriptorpb.DescriptorProto, message *descriptorpb.DescriptorProto, fields []TableFieldSchema, messageNames map[string]bool) (bool, *errortools.Error) {
+	usesColumnName := false
+
+	for i, field := range fields {
+		fieldDescriptor := descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(field.Name),
+			Number: proto.Int32(int32(i + 1)),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+
+		if !protoreflect.Name(field.Name).IsValid() {
+			// names that are not valid proto identifiers are passed through an annotation
+			fieldDescriptor.Name = proto.String(fmt.Sprintf("col_%x", field.Name))
+			fieldDescriptor.Options = &descriptorpb.FieldOptions{}
+			proto.SetExtension(fieldDescriptor.Options, storagepb.E_ColumnName, field.Name)
+			usesColumnName = true
+		}
+
+		switch strings.ToUpper(field.Mode) {
+		case "REQUIRED":
+			fieldDescriptor.Label = descriptorpb.FieldDescriptorProto_LABEL_REQUIRED.Enum()
+		case "REPEATED":
+			fieldDescriptor.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		}
+
+		switch normalizedFieldType(field.Type) {
+		case "INT64", "TIMESTAMP":
+			fieldDescriptor.Type = descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum()
+		case "FLOAT64":
+			fieldDescriptor.Type = descriptorpb.FieldDescriptorProto_TYPE_DOUBLE.Enum()
+		case "BOOL":
+			fieldDescriptor.Type = descriptorpb.FieldDescriptorProto_TYPE_BOOL.Enum()
+		case "STRING", "JSON", "GEOGRAPHY", "DATETIME", "TIME", "INTERVAL":
+			fieldDescriptor.Type = descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()
+		case "BYTES", "NUMERIC", "BIGNUMERIC":
+			fieldDescriptor.Type = descriptorpb.FieldDescriptorProto_TYPE_BYTES.Enum()
+		case "DATE":
+			fieldDescriptor.Type = descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum()
+		case "STRUCT":
+			nestedName := protoMessageName(field.Name, messageNames)
+			nested := descriptorpb.DescriptorProto{Name: proto.String(nestedName)}
+			// add the nested message before its own nested messages, so that the order is top-down
+			root.NestedType = append(root.NestedType, &nested)
+
+			nestedUsesColumnName, e := addProtoFields(root, &nested, field.Fields, messageNames)
+			if e != nil {
+				return false, e
+			}
+			usesColumnName = usesColumnName || nestedUsesColumnName
+
+			fieldDescriptor.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+			fieldDescriptor.TypeName = proto.String(nestedName)
+		default:
+			return false, errortools.ErrorMessagef("field %s has unsupported type %s", field.Name, field.Type)
+		}
+
+		message.Field = append(message.Field, &fieldDescriptor)
+	}
+
+	return usesColumnName, nil
+}
+
+// protoMessageName derives a unique message name from a field name, e.g. home_address becomes HomeAddress
+func protoMessageName(fieldName string, messageNames map[string]bool) string {
+	name := ""
+	upper := true
+	for _, r := range fieldName {
+		switch {
+		case r >= 'a' && r <= 'z':
+			if upper {
+				r -= 'a' - 'A'
+			}
+			name += string(r)
+			upper = false
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			name += string(r)
+			upper = false
+		default:
+			upper = true
+		}
+	}
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "Record" + name
+	}
+
+	unique := name
+	for i := 2; messageNames[unique]; i++ {
+		unique = fmt.Sprintf("%s%v", name, i)
+	}
+	messageNames[unique] = true
+
+	return unique
+}
+
+// DescriptorProto returns the descriptor of the root message, including the nested messages
+func (schema *ProtoSchema) DescriptorProto() *descriptorpb.DescriptorProto {
+	return proto.Clone(schema.descriptor).(*descriptorpb.DescriptorProto)
+}
+
+func (schema *ProtoSchema) MessageDescriptor() protoreflect.MessageDescriptor {
+	return schema.message
+}
+
+// Marshal converts a row to a message and returns its wire encoding
+func (schema *ProtoSchema) Marshal(row interface{}) ([]byte, *errortools.Error) {
+	message, e := schema.NewMessage(row)
+	if e != nil {
+		return nil, e
+	}
+
+	b, err := proto.Marshal(message)
+	if err != nil {
+		return nil, errortools.ErrorMessage(err)
+	}
+
+	return b, nil
+}
+
+// NewMessage converts a row, a struct or a map with string keys, into a message.
+// Fields are matched by name case-insensitively, struct fields by their bigquery or json tag if present.
+func (schema *ProtoSchema) NewMessage(row interface{}) (*dynamicpb.Message, *errortools.Error) {
+	return newProtoMessage(schema.message, schema.fields, reflect.ValueOf(row))
+}
+
+func newProtoMessage(descriptor protoreflect.MessageDescriptor, fields []TableFieldSchema, v reflect.Value) (*dynamicpb.Message, *errortools.Error) {
+	v = indirect(v)
+	if !v.IsValid() {
+		return nil, errortools.ErrorMessage("row must not be nil")
+	}
+
+	values := map[string]reflect.Value{}
+
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, errortools.ErrorMessagef("unsupported map key type %s", v.Type().Key())
+		}
+		for _, key := range v.MapKeys() {
+			values[strings.ToLower(key.String())] = v.MapIndex(key)
+		}
+	case reflect.Struct:
+		for _, field := range structFields(v.Type()) {
+			values[strings.ToLower(field.name)] = v.FieldByIndex(field.index)
+		}
+	default:
+		return nil, errortools.ErrorMessagef("unsupported row type %s", v.Type())
+	}
+
+	message := dynamicpb.NewMessage(descriptor)
+
+	for i, field := range fields {
+		value, ok := values[strings.ToLower(field.Name)]
+		if !ok {
+			continue
+		}
+		value = indirect(value)
+		if !value.IsValid() {
+			continue
+		}
+
+		fieldDescriptor := descriptor.Fields().Get(i)
+
+		if fieldDescriptor.Cardinality() == protoreflect.Repeated {
+			if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+				return nil, errortools.ErrorMessagef("field %s is repeated but its value is of type %s", field.Name, value.Type())
+			}
+			list := message.Mutable(fieldDescriptor).List()
+			for j := 0; j < value.Len(); j++ {
+				element, e := protoValue(fieldDescriptor, &field, value.Index(j))
+				if e != nil {
+					return nil, e
+				}
+				list.Append(element)
+			}
+			continue
+		}
+
+		fieldValue, e := protoValue(fieldDescriptor, &field, value)
+		if e != nil {
+			return nil, e
+		}
+		message.Set(fieldDescriptor, fieldValue)
+	}
+
+	return message, nil
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// protoValue converts a single value of a field
+func protoValue(fieldDescriptor protoreflect.FieldDescriptor, field *TableFieldSchema, v reflect.Value) (protoreflect.Value, *errortools.Error) {
+	v = indirect(v)
+	if !v.IsValid() {
+		return protoreflect.Value{}, errortools.ErrorMessagef("field %s contains a NULL array element", field.Name)
+	}
+
+	fieldType := normalizedFieldType(field.Type)
+
+	invalid := func() (protoreflect.Value, *errortools.Error) {
+		return protoreflect.Value{}, errortools.ErrorMessagef("field %s of type %s cannot hold a value of type %s", field.Name, field.Type, v.Type())
+	}
+
+	switch fieldType {
+	case "STRUCT":
+		message, e := newProtoMessage(fieldDescriptor.Message(), field.Fields, v)
+		if e != nil {
+			return protoreflect.Value{}, e
+		}
+		return protoreflect.ValueOfMessage(message), nil
+	case "INT64":
+		i, ok, e := int64Value(field, v)
+		if e != nil {
+			return protoreflect.Value{}, e
+		}
+		if !ok {
+			return invalid()
+		}
+		return protoreflect.ValueOfInt64(i), nil
+	case "FLOAT64":
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return protoreflect.ValueOfFloat64(v.Float()), nil
+		case reflect.String:
+			f, err := strconv.ParseFloat(v.String(), 64)
+			if err != nil {
+				return invalid()
+			}
+			return protoreflect.ValueOfFloat64(f), nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return protoreflect.ValueOfFloat64(float64(v.Int())), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return protoreflect.ValueOfFloat64(float64(v.Uint())), nil
+		}
+		if n, ok := v.Interface().(json.Number); ok {
+			f, err := n.Float64()
+			if err != nil {
+				return invalid()
+			}
+			return protoreflect.ValueOfFloat64(f), nil
+		}
+		return invalid()
+	case "BOOL":
+		if v.Kind() != reflect.Bool {
+			return invalid()
+		}
+		return protoreflect.ValueOfBool(v.Bool()), nil
+	case "STRING", "GEOGRAPHY", "INTERVAL":
+		if v.Kind() != reflect.String {
+			return invalid()
+		}
+		return protoreflect.ValueOfString(v.String()), nil
+	case "JSON":
+		if v.Kind() == reflect.String {
+			return protoreflect.ValueOfString(v.String()), nil
+		}
+		b, err := json.Marshal(v.Interface())
+		if err != nil {
+			return protoreflect.Value{}, errortools.ErrorMessagef("field %s: %s", field.Name, err.Error())
+		}
+		return protoreflect.ValueOfString(string(b)), nil
+	case "BYTES":
+		if v.Kind() == reflect.String {
+			// strings are expected to be base64 encoded, as in JSON
+			b, err := base64.StdEncoding.DecodeString(v.String())
+			if err != nil {
+				return invalid()
+			}
+			return protoreflect.ValueOfBytes(b), nil
+		}
+		if (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return protoreflect.ValueOfBytes(b), nil
+		}
+		return invalid()
+	case "NUMERIC", "BIGNUMERIC":
+		r, ok := ratValue(v)
+		if !ok {
+			return invalid()
+		}
+		scale := numericScale
+		if fieldType == "BIGNUMERIC" {
+			scale = bigNumericScale
+		}
+		return protoreflect.ValueOfBytes(numericBytes(r, scale)), nil
+	case "TIMESTAMP":
+		switch v.Type() {
+		case typeOfTime:
+			return protoreflect.ValueOfInt64(v.Interface().(time.Time).UnixMicro()), nil
+		}
+		if v.Kind() == reflect.String {
+			t, err := time.Parse(time.RFC3339Nano, v.String())
+			if err != nil {
+				return invalid()
+			}
+			return protoreflect.ValueOfInt64(t.UnixMicro()), nil
+		}
+		// integers are taken as microseconds since the epoch
+		i, ok, e := int64Value(field, v)
+		if e != nil {
+			return protoreflect.Value{}, e
+		}
+		if !ok {
+			return invalid()
+		}
+		return protoreflect.ValueOfInt64(i), nil
+	case "DATE":
+		var d civil.Date
+		switch {
+		case v.Type() == typeOfCivilDate:
+			d = v.Interface().(civil.Date)
+		case v.Type() == typeOfTime:
+			d = civil.DateOf(v.Interface().(time.Time))
+		case v.Kind() == reflect.String:
+			var err error
+			d, err = civil.ParseDate(v.String())
+			if err != nil {
+				return invalid()
+			}
+		default:
+			return invalid()
+		}
+		return protoreflect.ValueOfInt32(int32(d.DaysSince(civil.Date{Year: 1970, Month: time.January, Day: 1}))), nil
+	case "DATETIME":
+		switch {
+		case v.Type() == typeOfCivilDateTime:
+			dt := v.Interface().(civil.DateTime)
+			return protoreflect.ValueOfString(dt.In(time.UTC).Format("2006-01-02 15:04:05.999999")), nil
+		case v.Type() == typeOfTime:
+			return protoreflect.ValueOfString(v.Interface().(time.Time).Format("2006-01-02 15:04:05.999999")), nil
+		case v.Kind() == reflect.String:
+			return protoreflect.ValueOfString(v.String()), nil
+		}
+		return invalid()
+	case "TIME":
+		switch {
+		case v.Type() == typeOfCivilTime:
+			t := v.Interface().(civil.Time)
+			return protoreflect.ValueOfString(time.Date(1970, 1, 1, t.Hour, t.Minute, t.Second, t.Nanosecond, time.UTC).Format("15:04:05.999999")), nil
+		case v.Kind() == reflect.String:
+			return protoreflect.ValueOfString(v.String()), nil
+		}
+		return invalid()
+	}
+
+	return protoreflect.Value{}, errortools.ErrorMessagef("field %s has unsupported type %s", field.Name, field.Type)
+}
+
+// int64Value returns the integer held by v, ok is false if v does not hold an integer
+// and an error is returned if the integer is out of the INT64 range
+func int64Value(field *TableFieldSchema, v reflect.Value) (int64, bool, *errortools.Error) {
+	outOfRange := func(value interface{}) (int64, bool, *errortools.Error) {
+		return 0, false, errortools.ErrorMessagef("field %s: value %v is out of the INT64 range", field.Name, value)
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return outOfRange(v.Uint())
+		}
+		return int64(v.Uint()), true, nil
+	}
+
+	var s string
+	if n, ok := v.Interface().(json.Number); ok {
+		s = n.String()
+	} else if v.Kind() == reflect.String {
+		s = v.String()
+	} else {
+		return 0, false, nil
+	}
+
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		if numError, ok := err.(*strconv.NumError); ok && numError.Err == strconv.ErrRange {
+			return outOfRange(s)
+		}
+		return 0, false, nil
+	}
+	return i, true, nil
+}
+
+func ratValue(v reflect.Value) (*big.Rat, bool) {
+	if v.Type() == typeOfBigRat {
+		r := v.Interface().(big.Rat)
+		return &r, true
+	}
+	if n, ok := v.Interface().(json.Number); ok {
+		return new(big.Rat).SetString(n.String())
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return new(big.Rat).SetString(v.String())
+	case reflect.Float32, reflect.Float64:
+		r := new(big.Rat).SetFloat64(v.Float())
+		return r, r != nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Rat).SetInt64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(v.Uint())), true
+	}
+	// e.g. the Numeric type of GenerateGoStructs
+	if reflect.PointerTo(v.Type()).Implements(typeOfTextMarshaler) {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		text, err := p.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, false
+		}
+		return new(big.Rat).SetString(string(text))
+	}
+	return nil, false
+}
+
+// numericBytes encodes a NUMERIC or BIGNUMERIC as the little-endian two's complement of its value scaled by 10^scale
+func numericBytes(r *big.Rat, scale int) []byte {
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)))
+	// round half away from zero
+	i := new(big.Int).Quo(scaled.Num(), scaled.Denom())
+	remainder := new(big.Int).Rem(scaled.Num(), scaled.Denom())
+	if new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(scaled.Denom()) >= 0 {
+		if scaled.Sign() < 0 {
+			i.Sub(i, big.NewInt(1))
+		} else {
+			i.Add(i, big.NewInt(1))
+		}
+	}
+
+	// big-endian two's complement with a sign bit
+	var b []byte
+	if i.Sign() >= 0 {
+		b = i.Bytes()
+		if len(b) == 0 || b[0]&0x80 != 0 {
+			b = append([]byte{0}, b...)
+		}
+	} else {
+		// the shortest length whose sign bit is set, e.g. 1 byte for -128 and 2 bytes for -129
+		length := new(big.Int).Add(i, big.NewInt(1)).BitLen()/8 + 1
+		b = new(big.Int).Add(i, new(big.Int).Lsh(big.NewInt(1), uint(length*8))).Bytes()
+		for len(b) < length {
+			b = append([]byte{0xff}, b...)
+		}
+	}
+
+	// reverse to little-endian
+	for left, right := 0, len(b)-1; left < right; left, right = left+1, right-1 {
+		b[left], b[right] = b[right], b[left]
+	}
+
+	return b
+}
@@ -0,0 +1,95 @@
+package googlebigquery
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestNumericBytes(t *testing.T) {
+	for _, test := range []struct {
+		value string
+		scale int
+		want  []byte // little-endian
+	}{
+		{"0", 0, []byte{0x00}},
+		{"1", 0, []byte{0x01}},
+		{"127", 0, []byte{0x7f}},
+		{"128", 0, []byte{0x80, 0x00}},
+		{"-1", 0, []byte{0xff}},
+		{"-128", 0, []byte{0x80}},
+		{"-129", 0, []byte{0x7f, 0xff}},
+		{"9223372036854775808", 0, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x80, 0x00}},
+		{"-9223372036854775808", 0, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x80}},
+		// half rounds away from zero
+		{"0.5", 0, []byte{0x01}},
+		{"-0.5", 0, []byte{0xff}},
+		{"1.49", 0, []byte{0x01}},
+		{"2.5", 0, []byte{0x03}},
+		{"-2.5", 0, []byte{0xfd}},
+		{"0.0000000015", numericScale, []byte{0x02}},
+		{"-0.0000000014", numericScale, []byte{0xff}},
+	} {
+		r, ok := new(big.Rat).SetString(test.value)
+		if !ok {
+			t.Fatalf("invalid value %s", test.value)
+		}
+		if got := numericBytes(r, test.scale); !bytes.Equal(got, test.want) {
+			t.Errorf("numericBytes(%s, %v) = % x, want % x", test.value, test.scale, got, test.want)
+		}
+	}
+}
+
+func TestInt64Range(t *testing.T) {
+	schema, e := NewProtoSchema([]TableFieldSchema{{Name: "n", Type: "INTEGER"}}, "row")
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+
+	for _, test := range []struct {
+		value     interface{}
+		wantError bool
+	}{
+		{uint64(math.MaxInt64), false},
+		{uint64(math.MaxInt64) + 1, true},
+		{"9223372036854775807", false},
+		{"9223372036854775808", true},
+		{json.Number("-9223372036854775809"), true},
+	} {
+		_, e := schema.Marshal(map[string]interface{}{"n": test.value})
+		if (e != nil) != test.wantError {
+			t.Errorf("Marshal(%v): error = %v, want error %v", test.value, e, test.wantError)
+		}
+	}
+}
+
+// testDecimal is a numeric type like the Numeric type of GenerateGoStructs
+type testDecimal struct {
+	big.Rat
+}
+
+func (d *testDecimal) MarshalText() ([]byte, error) {
+	return []byte(d.FloatString(2)), nil
+}
+
+func TestNumericTextMarshaler(t *testing.T) {
+	schema, e := NewProtoSchema([]TableFieldSchema{{Name: "amount", Type: "NUMERIC"}}, "row")
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+
+	type row struct {
+		Amount *testDecimal `bigquery:"amount"`
+	}
+	message, e := schema.NewMessage(row{Amount: &testDecimal{*big.NewRat(3, 2)}})
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+
+	got := message.Get(schema.MessageDescriptor().Fields().ByName("amount")).Bytes()
+	if want := numericBytes(big.NewRat(3, 2), numericScale); !bytes.Equal(got, want) {
+		t.Errorf("amount = % x, want % x", got, want)
+	}
+}
@@ -0,0 +1,556 @@
+package googlebigquery
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	errortools "github.com/leapforce-libraries/go_errortools"
+)
+
+const (
+	ParameterModeNamed      string = "NAMED"
+	ParameterModePositional string = "POSITIONAL"
+)
+
+var (
+	typeOfTime          = reflect.TypeOf(time.Time{})
+	typeOfCivilDate     = reflect.TypeOf(civil.Date{})
+	typeOfCivilTime     = reflect.TypeOf(civil.Time{})
+	typeOfCivilDateTime = reflect.TypeOf(civil.DateTime{})
+	typeOfBigRat        = reflect.TypeOf(big.Rat{})
+)
+
+// NewQueryParameter converts a Go value into a query parameter, pass an empty name for a positional parameter.
+//
+// Go types map to BigQuery types as follows:
+//   - signed and unsigned integers: INT64, unsigned values above math.MaxInt64 are rejected
+//   - float32, float64: FLOAT64
+//   - string: STRING
+//   - bool: BOOL
+//   - []byte: BYTES
+//   - time.Time: TIMESTAMP
+//   - civil.Date, civil.Time, civil.DateTime: DATE, TIME, DATETIME
+//   - big.Rat, *big.Rat: NUMERIC, or BIGNUMERIC for values that NUMERIC cannot hold without rounding,
+//     values that BIGNUMERIC cannot hold either are rejected
+//   - slices and arrays: ARRAY
+//   - structs and maps with string keys: STRUCT
+//
+// Nil pointers become NULL values of the type pointed to, nil maps and untyped nils take their type
+// from the other elements of the array they are in.
+// Struct fields are named after their bigquery tag, json tag or field name, in that order.
+func NewQueryParameter(name string, value interface{}) (*QueryParameter, *errortools.Error) {
+	parameterType, parameterValue, e := queryParameterTypeAndValue(reflect.ValueOf(value))
+	if e != nil {
+		if name != "" {
+			e.SetMessagef("parameter %s: %s", name, e.Message())
+		}
+		return nil, e
+	}
+
+	queryParameter := QueryParameter{
+		ParameterType:  *parameterType,
+		ParameterValue: *parameterValue,
+	}
+	if name != "" {
+		queryParameter.Name = &name
+	}
+
+	return &queryParameter, nil
+}
+
+// NewNamedQueryParameters converts values into named query parameters, ordered by name
+func NewNamedQueryParameters(values map[string]interface{}) (*[]QueryParameter, *errortools.Error) {
+	names := []string{}
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	queryParameters := []QueryParameter{}
+	for _, name := range names {
+		queryParameter, e := NewQueryParameter(name, values[name])
+		if e != nil {
+			return nil, e
+		}
+		queryParameters = append(queryParameters, *queryParameter)
+	}
+
+	return &queryParameters, nil
+}
+
+// NewPositionalQueryParameters converts values into positional query parameters
+func NewPositionalQueryParameters(values ...interface{}) (*[]QueryParameter, *errortools.Error) {
+	queryParameters := []QueryParameter{}
+	for i, value := range values {
+		queryParameter, e := NewQueryParameter("", value)
+		if e != nil {
+			e.SetMessagef("parameter %v: %s", i+1, e.Message())
+			return nil, e
+		}
+		queryParameters = append(queryParameters, *queryParameter)
+	}
+
+	return &queryParameters, nil
+}
+
+// SetNamedParameters sets the query parameters to values, referenced as @name in the query
+func (query *JobConfigurationQuery) SetNamedParameters(values map[string]interface{}) *errortools.Error {
+	queryParameters, e := NewNamedQueryParameters(values)
+	if e != nil {
+		return e
+	}
+
+	parameterMode := ParameterModeNamed
+	query.ParameterMode = &parameterMode
+	query.QueryParameters = queryParameters
+
+	return nil
+}
+
+// SetPositionalParameters sets the query parameters to values, referenced as ? in the query
+func (query *JobConfigurationQuery) SetPositionalParameters(values ...interface{}) *errortools.Error {
+	queryParameters, e := NewPositionalQueryParameters(values...)
+	if e != nil {
+		return e
+	}
+
+	parameterMode := ParameterModePositional
+	query.ParameterMode = &parameterMode
+	query.QueryParameters = queryParameters
+
+	return nil
+}
+
+func queryParameterTypeAndValue(v reflect.Value) (*QueryParameterType, *QueryParameterValue, *errortools.Error) {
+	parameterType, e := queryParameterTypeOfValue(v)
+	if e != nil {
+		return nil, nil, e
+	}
+
+	e = checkQueryParameterType(parameterType)
+	if e != nil {
+		return nil, nil, e
+	}
+
+	parameterValue, e := queryParameterValue(v, parameterType)
+	if e != nil {
+		return nil, nil, e
+	}
+
+	return parameterType, parameterValue, nil
+}
+
+// queryParameterTypeOfValue determines the type from the value where the static type is insufficient,
+// i.e. for maps and for interface elements of slices.
+// The type of a NULL value that cannot be determined, e.g. an untyped nil or a nil map, is left empty,
+// so that it can be merged with the type of other elements of the same array.
+func queryParameterTypeOfValue(v reflect.Value) (*QueryParameterType, *errortools.Error) {
+	if !v.IsValid() {
+		return &QueryParameterType{}, nil
+	}
+
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			if v.Kind() == reflect.Interface {
+				return &QueryParameterType{}, nil
+			}
+			switch v.Type().Elem().Kind() {
+			case reflect.Interface, reflect.Map:
+				return &QueryParameterType{}, nil
+			}
+			return queryParameterType(v.Type().Elem())
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 || v.Len() == 0 {
+			break
+		}
+		elementType := &QueryParameterType{}
+		for i := 0; i < v.Len(); i++ {
+			t, e := queryParameterTypeOfValue(v.Index(i))
+			if e != nil {
+				return nil, e
+			}
+			elementType, e = mergeQueryParameterTypes(elementType, t)
+			if e != nil {
+				e.SetMessagef("element %v: %s", i, e.Message())
+				return nil, e
+			}
+		}
+		if elementType.Type == "" {
+			// only NULL elements, fall back on the static type
+			break
+		}
+		if elementType.Type == "ARRAY" {
+			return nil, errortools.ErrorMessagef("unsupported type %s, arrays of arrays are not allowed", v.Type())
+		}
+		return &QueryParameterType{Type: "ARRAY", ArrayType: elementType}, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, errortools.ErrorMessagef("unsupported map key type %s", v.Type().Key())
+		}
+		if v.IsNil() {
+			return &QueryParameterType{}, nil
+		}
+		structTypes := []QueryParameterStructType{}
+		for _, key := range sortedMapKeys(v) {
+			fieldType, e := queryParameterTypeOfValue(v.MapIndex(key))
+			if e != nil {
+				return nil, e
+			}
+			name := key.String()
+			structTypes = append(structTypes, QueryParameterStructType{Name: &name, Type: *fieldType})
+		}
+		return &QueryParameterType{Type: "STRUCT", StructTypes: &structTypes}, nil
+	case reflect.Struct:
+		if v.Type() == typeOfBigRat {
+			r := v.Interface().(big.Rat)
+			return numericParameterType(&r)
+		}
+		if _, ok := queryParameterScalarType(v.Type()); ok {
+			break
+		}
+		structTypes := []QueryParameterStructType{}
+		for _, field := range structFields(v.Type()) {
+			fieldType, e := queryParameterTypeOfValue(v.FieldByIndex(field.index))
+			if e != nil {
+				return nil, e
+			}
+			name := field.name
+			structTypes = append(structTypes, QueryParameterStructType{Name: &name, Type: *fieldType})
+		}
+		return &QueryParameterType{Type: "STRUCT", StructTypes: &structTypes}, nil
+	}
+
+	return queryParameterType(v.Type())
+}
+
+// mergeQueryParameterTypes returns the type that holds the values of both a and b, e.g. of two elements of an array.
+// An empty type takes the other type, NUMERIC widens to BIGNUMERIC and struct fields are united,
+// other differences are a conflict.
+func mergeQueryParameterTypes(a *QueryParameterType, b *QueryParameterType) (*QueryParameterType, *errortools.Error) {
+	if a.Type == "" {
+		return b, nil
+	}
+	if b.Type == "" {
+		return a, nil
+	}
+	if a.Type == "BIGNUMERIC" && b.Type == "NUMERIC" {
+		return a, nil
+	}
+	if a.Type == "NUMERIC" && b.Type == "BIGNUMERIC" {
+		return b, nil
+	}
+	if a.Type != b.Type {
+		return nil, errortools.ErrorMessagef("conflicting types %s and %s", a.Type, b.Type)
+	}
+
+	switch a.Type {
+	case "ARRAY":
+		arrayType, e := mergeQueryParameterTypes(a.ArrayType, b.ArrayType)
+		if e != nil {
+			return nil, e
+		}
+		return &QueryParameterType{Type: "ARRAY", ArrayType: arrayType}, nil
+	case "STRUCT":
+		structTypes := append([]QueryParameterStructType{}, *a.StructTypes...)
+		for _, structType := range *b.StructTypes {
+			i := 0
+			for i < len(structTypes) && *structTypes[i].Name != *structType.Name {
+				i++
+			}
+			if i == len(structTypes) {
+				structTypes = append(structTypes, structType)
+				continue
+			}
+			fieldType, e := mergeQueryParameterTypes(&structTypes[i].Type, &structType.Type)
+			if e != nil {
+				e.SetMessagef("field %s: %s", *structType.Name, e.Message())
+				return nil, e
+			}
+			structTypes[i].Type = *fieldType
+		}
+		return &QueryParameterType{Type: "STRUCT", StructTypes: &structTypes}, nil
+	}
+
+	return a, nil
+}
+
+// checkQueryParameterType returns an error if parameterType, or the type of one of its elements or fields, is empty
+func checkQueryParameterType(parameterType *QueryParameterType) *errortools.Error {
+	switch parameterType.Type {
+	case "":
+		return errortools.ErrorMessage("cannot determine type of untyped nil or nil map")
+	case "ARRAY":
+		return checkQueryParameterType(parameterType.ArrayType)
+	case "STRUCT":
+		for _, structType := range *parameterType.StructTypes {
+			e := checkQueryParameterType(&structType.Type)
+			if e != nil {
+				e.SetMessagef("field %s: %s", *structType.Name, e.Message())
+				return e
+			}
+		}
+	}
+
+	return nil
+}
+
+func queryParameterScalarType(t reflect.Type) (string, bool) {
+	switch t {
+	case typeOfTime:
+		return "TIMESTAMP", true
+	case typeOfCivilDate:
+		return "DATE", true
+	case typeOfCivilTime:
+		return "TIME", true
+	case typeOfCivilDateTime:
+		return "DATETIME", true
+	case typeOfBigRat:
+		return "NUMERIC", true
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "INT64", true
+	case reflect.Float32, reflect.Float64:
+		return "FLOAT64", true
+	case reflect.String:
+		return "STRING", true
+	case reflect.Bool:
+		return "BOOL", true
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "BYTES", true
+		}
+	}
+
+	return "", false
+}
+
+// queryParameterType determines the type from the static Go type
+func queryParameterType(t reflect.Type) (*QueryParameterType, *errortools.Error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if scalarType, ok := queryParameterScalarType(t); ok {
+		return &QueryParameterType{Type: scalarType}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		elementType, e := queryParameterType(t.Elem())
+		if e != nil {
+			return nil, e
+		}
+		if elementType.Type == "ARRAY" {
+			return nil, errortools.ErrorMessagef("unsupported type %s, arrays of arrays are not allowed", t)
+		}
+		return &QueryParameterType{Type: "ARRAY", ArrayType: elementType}, nil
+	case reflect.Struct:
+		structTypes := []QueryParameterStructType{}
+		for _, field := range structFields(t) {
+			fieldType, e := queryParameterType(t.FieldByIndex(field.index).Type)
+			if e != nil {
+				return nil, e
+			}
+			name := field.name
+			structTypes = append(structTypes, QueryParameterStructType{Name: &name, Type: *fieldType})
+		}
+		return &QueryParameterType{Type: "STRUCT", StructTypes: &structTypes}, nil
+	case reflect.Map:
+		return nil, errortools.ErrorMessagef("cannot determine struct fields of nil %s", t)
+	case reflect.Interface:
+		return nil, errortools.ErrorMessage("cannot determine type of nil interface value")
+	}
+
+	return nil, errortools.ErrorMessagef("unsupported type %s", t)
+}
+
+func queryParameterValue(v reflect.Value, parameterType *QueryParameterType) (*QueryParameterValue, *errortools.Error) {
+	if !v.IsValid() {
+		return &QueryParameterValue{}, nil
+	}
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return &QueryParameterValue{}, nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Map && v.IsNil() {
+		return &QueryParameterValue{}, nil
+	}
+
+	var value string
+
+	switch parameterType.Type {
+	case "ARRAY":
+		arrayValues := []QueryParameterValue{}
+		for i := 0; i < v.Len(); i++ {
+			arrayValue, e := queryParameterValue(v.Index(i), parameterType.ArrayType)
+			if e != nil {
+				return nil, e
+			}
+			arrayValues = append(arrayValues, *arrayValue)
+		}
+		return &QueryParameterValue{ArrayValues: &arrayValues}, nil
+	case "STRUCT":
+		fieldIndexes := map[string][]int{}
+		if v.Kind() == reflect.Struct {
+			for _, field := range structFields(v.Type()) {
+				fieldIndexes[field.name] = field.index
+			}
+		}
+		structValues := map[string]QueryParameterValue{}
+		for _, structType := range *parameterType.StructTypes {
+			// a field that the value lacks, e.g. a key of another map in the same array, is NULL
+			var fieldValue reflect.Value
+			if v.Kind() == reflect.Map {
+				fieldValue = v.MapIndex(reflect.ValueOf(*structType.Name).Convert(v.Type().Key()))
+			} else if index, ok := fieldIndexes[*structType.Name]; ok {
+				fieldValue = v.FieldByIndex(index)
+			}
+			structValue, e := queryParameterValue(fieldValue, &structType.Type)
+			if e != nil {
+				return nil, e
+			}
+			structValues[*structType.Name] = *structValue
+		}
+		return &QueryParameterValue{StructValues: &structValues}, nil
+	case "TIMESTAMP":
+		value = v.Interface().(time.Time).UTC().Format("2006-01-02 15:04:05.999999-07:00")
+	case "DATE", "TIME", "DATETIME":
+		value = fmt.Sprintf("%v", v.Interface())
+	case "NUMERIC", "BIGNUMERIC":
+		r := v.Interface().(big.Rat)
+		s, ok := numericString(&r, parameterType.Type)
+		if !ok {
+			return nil, errortools.ErrorMessagef("value %s exceeds the precision or range of %s", r.RatString(), parameterType.Type)
+		}
+		value = s
+	case "INT64":
+		switch v.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if v.Uint() > math.MaxInt64 {
+				return nil, errortools.ErrorMessagef("value %v is out of the INT64 range", v.Uint())
+			}
+		}
+		value = fmt.Sprintf("%v", v.Interface())
+	case "FLOAT64", "BOOL":
+		value = fmt.Sprintf("%v", v.Interface())
+	case "STRING":
+		value = v.String()
+	case "BYTES":
+		b := make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(b), v)
+		value = base64.StdEncoding.EncodeToString(b)
+	default:
+		return nil, errortools.ErrorMessagef("unsupported parameter type %s", parameterType.Type)
+	}
+
+	return &QueryParameterValue{Value: &value}, nil
+}
+
+var (
+	// NUMERIC holds 38 digits, 9 of which after the decimal point
+	maxNumeric = new(big.Int).Exp(big.NewInt(10), big.NewInt(38), nil)
+	// BIGNUMERIC holds a 256 bits two's complement integer scaled by 10^-38
+	maxBigNumeric = new(big.Int).Lsh(big.NewInt(1), 255)
+)
+
+// numericParameterType returns NUMERIC if it holds r without rounding, and BIGNUMERIC otherwise
+func numericParameterType(r *big.Rat) (*QueryParameterType, *errortools.Error) {
+	for _, parameterType := range []string{"NUMERIC", "BIGNUMERIC"} {
+		if _, ok := numericString(r, parameterType); ok {
+			return &QueryParameterType{Type: parameterType}, nil
+		}
+	}
+	return nil, errortools.ErrorMessagef("value %s exceeds the precision or range of BIGNUMERIC", r.RatString())
+}
+
+// numericString formats r with as few decimals as needed, ok is false if parameterType cannot hold r without rounding
+func numericString(r *big.Rat, parameterType string) (string, bool) {
+	scale := 9
+	if parameterType == "BIGNUMERIC" {
+		scale = 38
+	}
+
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)))
+	if !scaled.IsInt() {
+		return "", false
+	}
+	n := scaled.Num()
+	if parameterType == "BIGNUMERIC" {
+		if n.Cmp(new(big.Int).Neg(maxBigNumeric)) < 0 || n.Cmp(maxBigNumeric) >= 0 {
+			return "", false
+		}
+	} else if new(big.Int).Abs(n).Cmp(maxNumeric) >= 0 {
+		return "", false
+	}
+
+	decimals := 0
+	for !new(big.Rat).Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))).IsInt() {
+		decimals++
+	}
+	return r.FloatString(decimals), true
+}
+
+type structField struct {
+	name  string
+	index []int
+}
+
+func structFields(t reflect.Type) []structField {
+	fields := []structField{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		for _, tag := range []string{"bigquery", "json"} {
+			tagValue, ok := field.Tag.Lookup(tag)
+			if !ok {
+				continue
+			}
+			tagName := strings.Split(tagValue, ",")[0]
+			if tagName == "-" {
+				name = ""
+				break
+			}
+			if tagName != "" {
+				name = tagName
+				break
+			}
+		}
+		if name == "" {
+			continue
+		}
+
+		fields = append(fields, structField{name: name, index: field.Index})
+	}
+
+	return fields
+}
+
+func sortedMapKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
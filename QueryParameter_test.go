@@ -0,0 +1,105 @@
+package googlebigquery
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// testParameterType formats parameterType as in GoogleSQL, e.g. ARRAY<STRUCT<a INT64>>
+func testParameterType(parameterType *QueryParameterType) string {
+	switch parameterType.Type {
+	case "ARRAY":
+		return "ARRAY<" + testParameterType(parameterType.ArrayType) + ">"
+	case "STRUCT":
+		fields := []string{}
+		for _, structType := range *parameterType.StructTypes {
+			fields = append(fields, *structType.Name+" "+testParameterType(&structType.Type))
+		}
+		return "STRUCT<" + strings.Join(fields, ", ") + ">"
+	}
+	return parameterType.Type
+}
+
+func TestNewQueryParameter(t *testing.T) {
+	type item struct {
+		Name     string `bigquery:"name"`
+		Quantity *int64 `json:"quantity"`
+		Ignored  string `bigquery:"-"`
+	}
+	quantity := int64(2)
+	var nilItem *item
+	var nilRat *big.Rat
+
+	for _, test := range []struct {
+		name      string
+		value     interface{}
+		wantType  string
+		wantValue string
+	}{
+		{"uint64", uint64(math.MaxInt64), "INT64", `{"value":"9223372036854775807","arrayValues":null,"structValues":null}`},
+		{"nil pointer", nilItem, "STRUCT<name STRING, quantity INT64>", `{"value":null,"arrayValues":null,"structValues":null}`},
+		{"array of structs", []item{{Name: "a", Quantity: &quantity}, {Name: "b"}}, "ARRAY<STRUCT<name STRING, quantity INT64>>",
+			`{"value":null,"arrayValues":[` +
+				`{"value":null,"arrayValues":null,"structValues":{"name":{"value":"a","arrayValues":null,"structValues":null},"quantity":{"value":"2","arrayValues":null,"structValues":null}}},` +
+				`{"value":null,"arrayValues":null,"structValues":{"name":{"value":"b","arrayValues":null,"structValues":null},"quantity":{"value":null,"arrayValues":null,"structValues":null}}}` +
+				`],"structValues":null}`},
+		{"array with untyped nil", []interface{}{nil, 1}, "ARRAY<INT64>",
+			`{"value":null,"arrayValues":[{"value":null,"arrayValues":null,"structValues":null},{"value":"1","arrayValues":null,"structValues":null}],"structValues":null}`},
+		{"merged map fields", []interface{}{map[string]interface{}{"a": 1}, nil, map[string]interface{}{"a": nil, "b": "x"}}, "ARRAY<STRUCT<a INT64, b STRING>>",
+			`{"value":null,"arrayValues":[` +
+				`{"value":null,"arrayValues":null,"structValues":{"a":{"value":"1","arrayValues":null,"structValues":null},"b":{"value":null,"arrayValues":null,"structValues":null}}},` +
+				`{"value":null,"arrayValues":null,"structValues":null},` +
+				`{"value":null,"arrayValues":null,"structValues":{"a":{"value":null,"arrayValues":null,"structValues":null},"b":{"value":"x","arrayValues":null,"structValues":null}}}` +
+				`],"structValues":null}`},
+		{"numeric", big.NewRat(3, 2), "NUMERIC", `{"value":"1.5","arrayValues":null,"structValues":null}`},
+		{"nil numeric", nilRat, "NUMERIC", `{"value":null,"arrayValues":null,"structValues":null}`},
+		{"bignumeric", new(big.Rat).SetFrac64(1, 1<<30), "BIGNUMERIC", `{"value":"0.000000000931322574615478515625","arrayValues":null,"structValues":null}`},
+		{"numeric widened to bignumeric", []*big.Rat{big.NewRat(1, 1), nilRat, new(big.Rat).SetFrac64(1, 1<<30)}, "ARRAY<BIGNUMERIC>",
+			`{"value":null,"arrayValues":[{"value":"1","arrayValues":null,"structValues":null},{"value":null,"arrayValues":null,"structValues":null},{"value":"0.000000000931322574615478515625","arrayValues":null,"structValues":null}],"structValues":null}`},
+	} {
+		queryParameter, e := NewQueryParameter("p", test.value)
+		if e != nil {
+			t.Errorf("%s: %s", test.name, e.Message())
+			continue
+		}
+		if got := testParameterType(&queryParameter.ParameterType); got != test.wantType {
+			t.Errorf("%s: type %s, want %s", test.name, got, test.wantType)
+		}
+		b, err := json.Marshal(queryParameter.ParameterValue)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.wantValue {
+			t.Errorf("%s: value\n%s\nwant\n%s", test.name, b, test.wantValue)
+		}
+	}
+}
+
+func TestNewQueryParameterErrors(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"untyped nil", nil, "parameter p: cannot determine type of untyped nil or nil map"},
+		{"only nil elements", []interface{}{nil, nil}, "parameter p: cannot determine type of nil interface value"},
+		{"conflicting elements", []interface{}{1, "a"}, "parameter p: element 1: conflicting types INT64 and STRING"},
+		{"conflicting fields", []map[string]interface{}{{"a": 1}, {"a": true}}, "parameter p: element 1: field a: conflicting types INT64 and BOOL"},
+		{"uint64 out of range", uint64(math.MaxInt64) + 1, "parameter p: value 9223372036854775808 is out of the INT64 range"},
+		{"uint64 out of range in array", []uint64{1, math.MaxUint64}, "parameter p: value 18446744073709551615 is out of the INT64 range"},
+		{"rational", big.NewRat(1, 3), "parameter p: value 1/3 exceeds the precision or range of BIGNUMERIC"},
+		{"beyond bignumeric", new(big.Rat).SetInt(new(big.Int).Lsh(big.NewInt(1), 200)), "parameter p: value 1606938044258990275541962092341162602522202993782792835301376 exceeds the precision or range of BIGNUMERIC"},
+	} {
+		_, e := NewQueryParameter("p", test.value)
+		if e == nil {
+			t.Errorf("%s: expected an error", test.name)
+			continue
+		}
+		if e.Message() != test.want {
+			t.Errorf("%s: error %q, want %q", test.name, e.Message(), test.want)
+		}
+	}
+}
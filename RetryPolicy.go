@@ -0,0 +1,174 @@
+package googlebigquery
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+
+	go_http "github.com/leapforce-libraries/go_http"
+)
+
+type Operation string
+
+const (
+	OperationDatasetsGet           Operation = "datasets.get"
+	OperationDatasetsList          Operation = "datasets.list"
+	OperationJobsCancel            Operation = "jobs.cancel"
+	OperationJobsDelete            Operation = "jobs.delete"
+	OperationJobsGet               Operation = "jobs.get"
+	OperationJobsGetQueryResults   Operation = "jobs.getQueryResults"
+	OperationJobsInsert            Operation = "jobs.insert"
+	OperationJobsList              Operation = "jobs.list"
+	OperationModelsList            Operation = "models.list"
+	OperationRoutinesList          Operation = "routines.list"
+	OperationRowAccessPoliciesList Operation = "rowAccessPolicies.list"
+	OperationStorageAppendRows     Operation = "storage.appendRows"
+	OperationTablesDelete          Operation = "tables.delete"
+	OperationTablesGet             Operation = "tables.get"
+	OperationTablesInsert          Operation = "tables.insert"
+	OperationTablesList            Operation = "tables.list"
+)
+
+// isIdempotent reports whether executing requestConfig more than once has the same effect as executing it once.
+// jobs.insert is only idempotent when the job carries a client-generated JobId,
+// since a retry then fails with a duplicate error instead of starting a second job, or when it is a dry run.
+func (operation Operation) isIdempotent(requestConfig *go_http.RequestConfig) bool {
+	switch operation {
+	case OperationJobsCancel:
+		// cancelling a job that is already cancelled or done has no further effect
+		return true
+	case OperationJobsInsert:
+		body, ok := requestConfig.BodyModel.(*insertJobRequest)
+		if !ok {
+			return false
+		}
+		if body.Configuration.DryRun != nil && *body.Configuration.DryRun {
+			// a dry run does not start a job
+			return true
+		}
+		return body.JobReference != nil && body.JobReference.JobID != ""
+	}
+
+	switch requestConfig.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// RetryRequest describes a failed request that a RetryPolicy decides upon.
+type RetryRequest struct {
+	Operation  Operation
+	Idempotent bool
+	Attempt    uint // number of attempts made so far, starting at 1
+	StatusCode int  // 0 if no response was received
+	// NetworkError is set if the request failed in transport, e.g. because the connection was reset.
+	// Failures before the request was sent or after the response was received never reach a RetryPolicy.
+	NetworkError bool
+	Reason       string
+	Message      string
+}
+
+// RetryPolicy decides whether and after how long a failed request is retried.
+type RetryPolicy interface {
+	Retry(request *RetryRequest) (time.Duration, bool)
+}
+
+// NoRetryPolicy never retries a failed request
+type NoRetryPolicy struct{}
+
+func (NoRetryPolicy) Retry(request *RetryRequest) (time.Duration, bool) {
+	return 0, false
+}
+
+type ExponentialBackoffRetryPolicy struct {
+	MaxRetries     uint
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// RetryReasons overrules the default set of retriable error reasons
+	RetryReasons *[]string
+	// RetryStatusCodes overrules the default set of retriable http status codes,
+	// it is only used if no reason could be determined
+	RetryStatusCodes *[]int
+}
+
+var defaultRetryReasons = []string{
+	ErrorReasonBackendError,
+	ErrorReasonInternalError,
+	ErrorReasonJobBackendError,
+	ErrorReasonJobInternalError,
+	ErrorReasonJobRateLimitExceeded,
+	ErrorReasonRateLimitExceeded,
+}
+
+var defaultRetryStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+func NewExponentialBackoffRetryPolicy() *ExponentialBackoffRetryPolicy {
+	return &ExponentialBackoffRetryPolicy{
+		MaxRetries:     5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     32 * time.Second,
+		Multiplier:     2,
+	}
+}
+
+func (policy *ExponentialBackoffRetryPolicy) Retry(request *RetryRequest) (time.Duration, bool) {
+	if request == nil || !request.Idempotent || request.Attempt > policy.MaxRetries {
+		return 0, false
+	}
+
+	if !policy.isRetriable(request) {
+		return 0, false
+	}
+
+	backoff := float64(policy.InitialBackoff) * math.Pow(policy.Multiplier, float64(request.Attempt-1))
+	if policy.MaxBackoff > 0 && backoff > float64(policy.MaxBackoff) {
+		backoff = float64(policy.MaxBackoff)
+	}
+
+	// full jitter
+	return time.Duration(rand.Float64() * backoff), true
+}
+
+func (policy *ExponentialBackoffRetryPolicy) isRetriable(request *RetryRequest) bool {
+	if request.NetworkError {
+		return true
+	}
+	if request.StatusCode == 0 {
+		return false
+	}
+
+	if request.Reason != "" {
+		retryReasons := defaultRetryReasons
+		if policy.RetryReasons != nil {
+			retryReasons = *policy.RetryReasons
+		}
+		for _, reason := range retryReasons {
+			if reason == request.Reason {
+				return true
+			}
+		}
+		return false
+	}
+
+	retryStatusCodes := defaultRetryStatusCodes
+	if policy.RetryStatusCodes != nil {
+		retryStatusCodes = *policy.RetryStatusCodes
+	}
+	for _, statusCode := range retryStatusCodes {
+		if statusCode == request.StatusCode {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,55 @@
+package googlebigquery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	go_http "github.com/leapforce-libraries/go_http"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	policy := NewExponentialBackoffRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+
+	for _, test := range []struct {
+		name         string
+		retryPolicy  RetryPolicy
+		wantRequests int64
+		wantError    bool
+	}{
+		{"NoRetryPolicy", NoRetryPolicy{}, 1, true},
+		{"ExponentialBackoffRetryPolicy", policy, 3, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var requests int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt64(&requests, 1) < 3 {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusServiceUnavailable)
+					w.Write([]byte(`{"error":{"code":503,"message":"backend error","errors":[{"reason":"backendError"}]}}`))
+					return
+				}
+				w.Write([]byte(`{}`))
+			}))
+			defer server.Close()
+
+			service := newTestService(t)
+			service.SetRetryPolicy(test.retryPolicy)
+
+			_, _, e := service.httpRequest(context.Background(), OperationTablesGet, &go_http.RequestConfig{
+				Method: http.MethodGet,
+				Url:    server.URL,
+			})
+			if (e != nil) != test.wantError {
+				t.Errorf("error = %v, want error %v", e, test.wantError)
+			}
+			if requests != test.wantRequests {
+				t.Errorf("got %v requests, want %v", requests, test.wantRequests)
+			}
+		})
+	}
+}
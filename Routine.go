@@ -0,0 +1,133 @@
+package googlebigquery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+	go_http "github.com/leapforce-libraries/go_http"
+	go_types "github.com/leapforce-libraries/go_types"
+)
+
+type RoutinesResponse struct {
+	NextPageToken *string   `json:"nextPageToken"`
+	Routines      []Routine `json:"routines"`
+}
+
+type Routine struct {
+	Etag              string               `json:"etag"`
+	RoutineReference  RoutineReference     `json:"routineReference"`
+	RoutineType       string               `json:"routineType"`
+	CreationTime      go_types.Int64String `json:"creationTime"`
+	LastModifiedTime  go_types.Int64String `json:"lastModifiedTime"`
+	Language          *string              `json:"language"`
+	Arguments         *[]RoutineArgument   `json:"arguments"`
+	ReturnType        *StandardSqlDataType `json:"returnType"`
+	ImportedLibraries *[]string            `json:"importedLibraries"`
+	DefinitionBody    *string              `json:"definitionBody"`
+	Description       *string              `json:"description"`
+	DeterminismLevel  *string              `json:"determinismLevel"`
+}
+
+type RoutineArgument struct {
+	Name         *string              `json:"name"`
+	ArgumentKind *string              `json:"argumentKind"`
+	Mode         *string              `json:"mode"`
+	DataType     *StandardSqlDataType `json:"dataType"`
+}
+
+type StandardSqlDataType struct {
+	TypeKind         string               `json:"typeKind"`
+	ArrayElementType *StandardSqlDataType `json:"arrayElementType"`
+	StructType       *struct {
+		Fields []struct {
+			Name *string             `json:"name"`
+			Type StandardSqlDataType `json:"type"`
+		} `json:"fields"`
+	} `json:"structType"`
+}
+
+type GetRoutinesConfig struct {
+	ProjectId  string
+	DatasetId  string
+	Filter     *string
+	ReadMask   *string
+	MaxResults *int
+	MaxItems   *int
+	PageToken  *string
+}
+
+func (service *Service) GetRoutines(config *GetRoutinesConfig) (*[]Routine, *errortools.Error) {
+	return service.GetRoutinesWithContext(context.Background(), config)
+}
+
+func (service *Service) GetRoutinesWithContext(ctx context.Context, config *GetRoutinesConfig) (*[]Routine, *errortools.Error) {
+	return service.RoutinesPagerWithContext(ctx, config).All()
+}
+
+// IterateRoutines returns an iterator that fetches the routines page by page
+func (service *Service) IterateRoutines(config *GetRoutinesConfig) *Iterator[Routine] {
+	return service.IterateRoutinesWithContext(context.Background(), config)
+}
+
+func (service *Service) IterateRoutinesWithContext(ctx context.Context, config *GetRoutinesConfig) *Iterator[Routine] {
+	return service.RoutinesPagerWithContext(ctx, config).Iterator()
+}
+
+func (service *Service) RoutinesPager(config *GetRoutinesConfig) *Pager[Routine] {
+	return service.RoutinesPagerWithContext(context.Background(), config)
+}
+
+func (service *Service) RoutinesPagerWithContext(ctx context.Context, config *GetRoutinesConfig) *Pager[Routine] {
+	if config == nil {
+		return newErrorPager[Routine](errortools.ErrorMessage("GetRoutinesConfig must not be a nil pointer"))
+	}
+
+	pagerConfig := PagerConfig{
+		PageToken: config.PageToken,
+		PageSize:  config.MaxResults,
+		MaxItems:  config.MaxItems,
+	}
+
+	return NewPager(ctx, &pagerConfig, func(ctx context.Context, pageToken *string, pageSize *int) ([]Routine, *string, *errortools.Error) {
+		routinesResponse, e := service.getRoutinesPage(ctx, config, pageToken, pageSize)
+		if e != nil {
+			return nil, nil, e
+		}
+		return routinesResponse.Routines, routinesResponse.NextPageToken, nil
+	})
+}
+
+func (service *Service) getRoutinesPage(ctx context.Context, config *GetRoutinesConfig, pageToken *string, pageSize *int) (*RoutinesResponse, *errortools.Error) {
+	values := url.Values{}
+
+	if config.Filter != nil {
+		values.Set("filter", *config.Filter)
+	}
+	if config.ReadMask != nil {
+		values.Set("readMask", *config.ReadMask)
+	}
+
+	if pageSize != nil {
+		values.Set("maxResults", fmt.Sprintf("%v", *pageSize))
+	}
+	if pageToken != nil {
+		values.Set("pageToken", *pageToken)
+	}
+
+	routinesResponse := RoutinesResponse{}
+
+	requestConfig := go_http.RequestConfig{
+		Method:        http.MethodGet,
+		Url:           service.url(fmt.Sprintf("projects/%s/datasets/%s/routines?%s", config.ProjectId, config.DatasetId, values.Encode())),
+		ResponseModel: &routinesResponse,
+	}
+	_, _, e := service.httpRequest(ctx, OperationRoutinesList, &requestConfig)
+	if e != nil {
+		return nil, e
+	}
+
+	return &routinesResponse, nil
+}
@@ -0,0 +1,99 @@
+package googlebigquery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+	go_http "github.com/leapforce-libraries/go_http"
+)
+
+type RowAccessPoliciesResponse struct {
+	NextPageToken     *string           `json:"nextPageToken"`
+	RowAccessPolicies []RowAccessPolicy `json:"rowAccessPolicies"`
+}
+
+type RowAccessPolicy struct {
+	Etag                     string                   `json:"etag"`
+	RowAccessPolicyReference RowAccessPolicyReference `json:"rowAccessPolicyReference"`
+	FilterPredicate          string                   `json:"filterPredicate"`
+	CreationTime             string                   `json:"creationTime"`
+	LastModifiedTime         string                   `json:"lastModifiedTime"`
+}
+
+type GetRowAccessPoliciesConfig struct {
+	ProjectId  string
+	DatasetId  string
+	TableId    string
+	MaxResults *int
+	MaxItems   *int
+	PageToken  *string
+}
+
+func (service *Service) GetRowAccessPolicies(config *GetRowAccessPoliciesConfig) (*[]RowAccessPolicy, *errortools.Error) {
+	return service.GetRowAccessPoliciesWithContext(context.Background(), config)
+}
+
+func (service *Service) GetRowAccessPoliciesWithContext(ctx context.Context, config *GetRowAccessPoliciesConfig) (*[]RowAccessPolicy, *errortools.Error) {
+	return service.RowAccessPoliciesPagerWithContext(ctx, config).All()
+}
+
+// IterateRowAccessPolicies returns an iterator that fetches the row access policies page by page
+func (service *Service) IterateRowAccessPolicies(config *GetRowAccessPoliciesConfig) *Iterator[RowAccessPolicy] {
+	return service.IterateRowAccessPoliciesWithContext(context.Background(), config)
+}
+
+func (service *Service) IterateRowAccessPoliciesWithContext(ctx context.Context, config *GetRowAccessPoliciesConfig) *Iterator[RowAccessPolicy] {
+	return service.RowAccessPoliciesPagerWithContext(ctx, config).Iterator()
+}
+
+func (service *Service) RowAccessPoliciesPager(config *GetRowAccessPoliciesConfig) *Pager[RowAccessPolicy] {
+	return service.RowAccessPoliciesPagerWithContext(context.Background(), config)
+}
+
+func (service *Service) RowAccessPoliciesPagerWithContext(ctx context.Context, config *GetRowAccessPoliciesConfig) *Pager[RowAccessPolicy] {
+	if config == nil {
+		return newErrorPager[RowAccessPolicy](errortools.ErrorMessage("GetRowAccessPoliciesConfig must not be a nil pointer"))
+	}
+
+	pagerConfig := PagerConfig{
+		PageToken: config.PageToken,
+		PageSize:  config.MaxResults,
+		MaxItems:  config.MaxItems,
+	}
+
+	return NewPager(ctx, &pagerConfig, func(ctx context.Context, pageToken *string, pageSize *int) ([]RowAccessPolicy, *string, *errortools.Error) {
+		rowAccessPoliciesResponse, e := service.getRowAccessPoliciesPage(ctx, config, pageToken, pageSize)
+		if e != nil {
+			return nil, nil, e
+		}
+		return rowAccessPoliciesResponse.RowAccessPolicies, rowAccessPoliciesResponse.NextPageToken, nil
+	})
+}
+
+func (service *Service) getRowAccessPoliciesPage(ctx context.Context, config *GetRowAccessPoliciesConfig, pageToken *string, pageSize *int) (*RowAccessPoliciesResponse, *errortools.Error) {
+	values := url.Values{}
+
+	if pageSize != nil {
+		values.Set("pageSize", fmt.Sprintf("%v", *pageSize))
+	}
+	if pageToken != nil {
+		values.Set("pageToken", *pageToken)
+	}
+
+	rowAccessPoliciesResponse := RowAccessPoliciesResponse{}
+
+	requestConfig := go_http.RequestConfig{
+		Method:        http.MethodGet,
+		Url:           service.url(fmt.Sprintf("projects/%s/datasets/%s/tables/%s/rowAccessPolicies?%s", config.ProjectId, config.DatasetId, config.TableId, values.Encode())),
+		ResponseModel: &rowAccessPoliciesResponse,
+	}
+	_, _, e := service.httpRequest(ctx, OperationRowAccessPoliciesList, &requestConfig)
+	if e != nil {
+		return nil, e
+	}
+
+	return &rowAccessPoliciesResponse, nil
+}
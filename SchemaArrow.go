@@ -0,0 +1,266 @@
+package googlebigquery
+
+import (
+	"strings"
+
+	"github.com/apache/arrow/go/v12/arrow"
+	errortools "github.com/leapforce-libraries/go_errortools"
+)
+
+const (
+	// arrowExtensionName is the field metadata key the Storage Read API uses to mark JSON and GEOGRAPHY columns
+	arrowExtensionName    string = "ARROW:extension:name"
+	arrowSqlTypeJson      string = "google:sqlType:json"
+	arrowSqlTypeGeography string = "google:sqlType:geography"
+)
+
+// TableFieldsToArrowSchema converts the fields to an Arrow schema, as the Storage Read API does.
+//
+// REQUIRED fields are not nullable, REPEATED fields become a list of non-nullable elements and RECORD fields a struct.
+// Types are mapped as follows:
+//
+//	STRING                 utf8
+//	JSON, GEOGRAPHY        utf8 with ARROW:extension:name google:sqlType:json resp. google:sqlType:geography
+//	BYTES                  binary
+//	INTEGER                int64
+//	FLOAT                  float64
+//	BOOLEAN                bool
+//	NUMERIC                decimal128(38, 9), or the precision and scale of the field
+//	BIGNUMERIC             decimal256(76, 38), or the precision and scale of the field
+//	TIMESTAMP              timestamp[us, tz=UTC]
+//	DATE                   date32
+//	TIME                   time64[us]
+//	DATETIME               timestamp[us] without time zone
+//	INTERVAL               month_day_nano_interval
+func TableFieldsToArrowSchema(fields *[]TableFieldSchema) (*arrow.Schema, *errortools.Error) {
+	if fields == nil {
+		return nil, errortools.ErrorMessage("fields must not be a nil pointer")
+	}
+
+	arrowFields, e := tableArrowFields(*fields)
+	if e != nil {
+		return nil, e
+	}
+
+	return arrow.NewSchema(arrowFields, nil), nil
+}
+
+func tableArrowFields(fields []TableFieldSchema) ([]arrow.Field, *errortools.Error) {
+	arrowFields := []arrow.Field{}
+
+	for _, field := range fields {
+		arrowField := arrow.Field{
+			Name:     field.Name,
+			Nullable: strings.ToUpper(field.Mode) != "REQUIRED" && strings.ToUpper(field.Mode) != "REPEATED",
+		}
+
+		switch normalizedFieldType(field.Type) {
+		case "STRING":
+			arrowField.Type = arrow.BinaryTypes.String
+		case "JSON":
+			arrowField.Type = arrow.BinaryTypes.String
+			arrowField.Metadata = arrow.NewMetadata([]string{arrowExtensionName}, []string{arrowSqlTypeJson})
+		case "GEOGRAPHY":
+			arrowField.Type = arrow.BinaryTypes.String
+			arrowField.Metadata = arrow.NewMetadata([]string{arrowExtensionName}, []string{arrowSqlTypeGeography})
+		case "BYTES":
+			arrowField.Type = arrow.BinaryTypes.Binary
+		case "INT64":
+			arrowField.Type = arrow.PrimitiveTypes.Int64
+		case "FLOAT64":
+			arrowField.Type = arrow.PrimitiveTypes.Float64
+		case "BOOL":
+			arrowField.Type = arrow.FixedWidthTypes.Boolean
+		case "NUMERIC":
+			precision, scale := decimalPrecisionScale(field, 38, 9)
+			arrowField.Type = &arrow.Decimal128Type{Precision: precision, Scale: scale}
+		case "BIGNUMERIC":
+			precision, scale := decimalPrecisionScale(field, 76, 38)
+			arrowField.Type = &arrow.Decimal256Type{Precision: precision, Scale: scale}
+		case "TIMESTAMP":
+			arrowField.Type = &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}
+		case "DATE":
+			arrowField.Type = arrow.FixedWidthTypes.Date32
+		case "TIME":
+			arrowField.Type = arrow.FixedWidthTypes.Time64us
+		case "DATETIME":
+			arrowField.Type = &arrow.TimestampType{Unit: arrow.Microsecond}
+		case "INTERVAL":
+			arrowField.Type = arrow.FixedWidthTypes.MonthDayNanoInterval
+		case "STRUCT":
+			nestedFields, e := tableArrowFields(field.Fields)
+			if e != nil {
+				return nil, e
+			}
+			arrowField.Type = arrow.StructOf(nestedFields...)
+		default:
+			return nil, errortools.ErrorMessagef("field %s has unsupported type %s", field.Name, field.Type)
+		}
+
+		if strings.ToUpper(field.Mode) == "REPEATED" {
+			arrowField.Type = arrow.ListOfField(arrow.Field{Name: "element", Type: arrowField.Type, Metadata: arrowField.Metadata})
+			arrowField.Metadata = arrow.Metadata{}
+		}
+
+		arrowFields = append(arrowFields, arrowField)
+	}
+
+	return arrowFields, nil
+}
+
+// decimalPrecisionScale returns the precision and scale of a NUMERIC or BIGNUMERIC field,
+// or the defaults if the field has no precision
+func decimalPrecisionScale(field TableFieldSchema, defaultPrecision int32, defaultScale int32) (int32, int32) {
+	if field.Precision == nil {
+		return defaultPrecision, defaultScale
+	}
+	scale := int32(0)
+	if field.Scale != nil {
+		scale = int32(*field.Scale)
+	}
+	return int32(*field.Precision), scale
+}
+
+// ArrowSchemaToTableFields converts an Arrow schema to table fields. Nullable fields become NULLABLE,
+// other fields REQUIRED, lists REPEATED and structs RECORD. Types are mapped as follows:
+//
+//	utf8, large_utf8                            STRING, or JSON resp. GEOGRAPHY by ARROW:extension:name
+//	binary, large_binary, fixed_size_binary     BYTES
+//	int8 to int64, uint8 to uint32              INTEGER
+//	uint64                                      NUMERIC, since it may exceed the range of INTEGER
+//	float16, float32, float64                   FLOAT
+//	bool                                        BOOLEAN
+//	decimal128, decimal256                      NUMERIC if the scale is at most 9 and the number of integer digits at most 29,
+//	                                            otherwise BIGNUMERIC
+//	date32, date64                              DATE
+//	time32, time64                              TIME
+//	timestamp with time zone                    TIMESTAMP
+//	timestamp without time zone                 DATETIME
+//	month_day_nano_interval                     INTERVAL
+//	dictionary                                  the type of the dictionary values
+//
+// Lists of lists and other types, such as maps and unions, are not supported.
+func ArrowSchemaToTableFields(schema *arrow.Schema) ([]TableFieldSchema, *errortools.Error) {
+	if schema == nil {
+		return nil, errortools.ErrorMessage("schema must not be a nil pointer")
+	}
+
+	return arrowTableFields(schema.Fields(), 1)
+}
+
+func arrowTableFields(arrowFields []arrow.Field, depth int) ([]TableFieldSchema, *errortools.Error) {
+	if depth > maxSchemaNestingDepth {
+		return nil, errortools.ErrorMessagef("schema exceeds the maximum nesting depth of %v", maxSchemaNestingDepth)
+	}
+
+	fields := []TableFieldSchema{}
+
+	for _, arrowField := range arrowFields {
+		field := TableFieldSchema{
+			Name: arrowField.Name,
+			Mode: "REQUIRED",
+		}
+		if arrowField.Nullable {
+			field.Mode = "NULLABLE"
+		}
+
+		dataType := arrowField.Type
+		metadata := arrowField.Metadata
+		if elemField, ok := arrowListElem(dataType); ok {
+			// a nullable list is loaded as a list that may be empty
+			field.Mode = "REPEATED"
+			dataType = elemField.Type
+			metadata = elemField.Metadata
+			if _, ok := arrowListElem(dataType); ok {
+				return nil, errortools.ErrorMessagef("field %s: lists of lists are not supported", field.Name)
+			}
+		}
+
+		fieldType, e := arrowFieldType(dataType, metadata)
+		if e != nil {
+			return nil, errortools.ErrorMessagef("field %s: %s", field.Name, e.Message())
+		}
+		field.Type = fieldType
+
+		if structType, ok := dataType.(*arrow.StructType); ok {
+			nestedFields, e := arrowTableFields(structType.Fields(), depth+1)
+			if e != nil {
+				return nil, e
+			}
+			field.Fields = nestedFields
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+func arrowListElem(dataType arrow.DataType) (arrow.Field, bool) {
+	switch t := dataType.(type) {
+	case *arrow.ListType:
+		return t.ElemField(), true
+	case *arrow.LargeListType:
+		return t.ElemField(), true
+	case *arrow.FixedSizeListType:
+		return t.ElemField(), true
+	}
+	return arrow.Field{}, false
+}
+
+func arrowFieldType(dataType arrow.DataType, metadata arrow.Metadata) (string, *errortools.Error) {
+	switch t := dataType.(type) {
+	case *arrow.DictionaryType:
+		return arrowFieldType(t.ValueType, metadata)
+	case *arrow.Decimal128Type:
+		return decimalFieldType(t.Precision, t.Scale), nil
+	case *arrow.Decimal256Type:
+		return decimalFieldType(t.Precision, t.Scale), nil
+	case *arrow.TimestampType:
+		if t.TimeZone == "" {
+			return "DATETIME", nil
+		}
+		return "TIMESTAMP", nil
+	case *arrow.StructType:
+		return "RECORD", nil
+	}
+
+	switch dataType.ID() {
+	case arrow.STRING, arrow.LARGE_STRING:
+		if i := metadata.FindKey(arrowExtensionName); i >= 0 {
+			switch metadata.Values()[i] {
+			case arrowSqlTypeJson:
+				return "JSON", nil
+			case arrowSqlTypeGeography:
+				return "GEOGRAPHY", nil
+			}
+		}
+		return "STRING", nil
+	case arrow.BINARY, arrow.LARGE_BINARY, arrow.FIXED_SIZE_BINARY:
+		return "BYTES", nil
+	case arrow.INT8, arrow.INT16, arrow.INT32, arrow.INT64, arrow.UINT8, arrow.UINT16, arrow.UINT32:
+		return "INTEGER", nil
+	case arrow.UINT64:
+		return "NUMERIC", nil
+	case arrow.FLOAT16, arrow.FLOAT32, arrow.FLOAT64:
+		return "FLOAT", nil
+	case arrow.BOOL:
+		return "BOOLEAN", nil
+	case arrow.DATE32, arrow.DATE64:
+		return "DATE", nil
+	case arrow.TIME32, arrow.TIME64:
+		return "TIME", nil
+	case arrow.INTERVAL_MONTH_DAY_NANO:
+		return "INTERVAL", nil
+	}
+
+	return "", errortools.ErrorMessagef("unsupported Arrow type %s", dataType.String())
+}
+
+// decimalFieldType returns the smallest decimal type that holds a decimal of the given precision and scale
+func decimalFieldType(precision int32, scale int32) string {
+	if scale <= int32(numericScale) && precision-scale <= 29 {
+		return "NUMERIC"
+	}
+	return "BIGNUMERIC"
+}
@@ -0,0 +1,298 @@
+package googlebigquery
+
+import (
+	"encoding/json"
+	"strings"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+)
+
+type avroSchemaRecord struct {
+	Type   string            `json:"type"`
+	Name   string            `json:"name"`
+	Fields []avroSchemaField `json:"fields"`
+}
+
+type avroSchemaField struct {
+	Name    string          `json:"name"`
+	Type    interface{}     `json:"type"`
+	Doc     string          `json:"doc,omitempty"`
+	Default json.RawMessage `json:"default,omitempty"`
+}
+
+type avroSchemaArray struct {
+	Type  string      `json:"type"`
+	Items interface{} `json:"items"`
+}
+
+type avroSchemaLogicalType struct {
+	Type        string `json:"type"`
+	LogicalType string `json:"logicalType,omitempty"`
+	SqlType     string `json:"sqlType,omitempty"`
+	Precision   int64  `json:"precision,omitempty"`
+	Scale       int64  `json:"scale,omitempty"`
+}
+
+// TableFieldsToAvroSchema converts the fields to the JSON of an Avro record schema named name.
+//
+// NULLABLE fields become a union of null and the type with a null default, REPEATED fields an array
+// and RECORD fields a nested record named after the field. Types are mapped as BigQuery exports them:
+//
+//	STRING                 string
+//	BYTES                  bytes
+//	INTEGER                long
+//	FLOAT                  double
+//	BOOLEAN                boolean
+//	NUMERIC                bytes with logical type decimal, precision 38 and scale 9 unless the field has its own
+//	BIGNUMERIC             bytes with logical type decimal, precision 77 and scale 38 unless the field has its own
+//	TIMESTAMP              long with logical type timestamp-micros
+//	DATE                   int with logical type date
+//	TIME                   long with logical type time-micros
+//	DATETIME               string with logical type datetime
+//	JSON, GEOGRAPHY        string with sqlType JSON resp. GEOGRAPHY
+//	INTERVAL               string with sqlType INTERVAL
+func TableFieldsToAvroSchema(fields *[]TableFieldSchema, name string) (string, *errortools.Error) {
+	if fields == nil {
+		return "", errortools.ErrorMessage("fields must not be a nil pointer")
+	}
+	if !isAvroName(name) {
+		return "", errortools.ErrorMessagef("invalid Avro record name %s", name)
+	}
+
+	recordNames := map[string]bool{name: true}
+	avroFields, e := avroSchemaFields(*fields, recordNames)
+	if e != nil {
+		return "", e
+	}
+
+	b, err := json.Marshal(avroSchemaRecord{Type: "record", Name: name, Fields: avroFields})
+	if err != nil {
+		return "", errortools.ErrorMessage(err)
+	}
+
+	return string(b), nil
+}
+
+func avroSchemaFields(fields []TableFieldSchema, recordNames map[string]bool) ([]avroSchemaField, *errortools.Error) {
+	avroFields := []avroSchemaField{}
+
+	for _, field := range fields {
+		if !isAvroName(field.Name) {
+			return nil, errortools.ErrorMessagef("field name %s is not a valid Avro name", field.Name)
+		}
+
+		var avroFieldType interface{}
+
+		switch normalizedFieldType(field.Type) {
+		case "STRING":
+			avroFieldType = "string"
+		case "BYTES":
+			avroFieldType = "bytes"
+		case "INT64":
+			avroFieldType = "long"
+		case "FLOAT64":
+			avroFieldType = "double"
+		case "BOOL":
+			avroFieldType = "boolean"
+		case "NUMERIC", "BIGNUMERIC":
+			decimal := avroSchemaLogicalType{Type: "bytes", LogicalType: "decimal", Precision: 38, Scale: 9}
+			if normalizedFieldType(field.Type) == "BIGNUMERIC" {
+				decimal.Precision, decimal.Scale = 77, 38
+			}
+			if field.Precision != nil {
+				decimal.Precision, decimal.Scale = int64(*field.Precision), 0
+				if field.Scale != nil {
+					decimal.Scale = int64(*field.Scale)
+				}
+			}
+			avroFieldType = decimal
+		case "TIMESTAMP":
+			avroFieldType = avroSchemaLogicalType{Type: "long", LogicalType: "timestamp-micros"}
+		case "DATE":
+			avroFieldType = avroSchemaLogicalType{Type: "int", LogicalType: "date"}
+		case "TIME":
+			avroFieldType = avroSchemaLogicalType{Type: "long", LogicalType: "time-micros"}
+		case "DATETIME":
+			avroFieldType = avroSchemaLogicalType{Type: "string", LogicalType: "datetime"}
+		case "JSON", "GEOGRAPHY", "INTERVAL":
+			avroFieldType = avroSchemaLogicalType{Type: "string", SqlType: normalizedFieldType(field.Type)}
+		case "STRUCT":
+			nestedFields, e := avroSchemaFields(field.Fields, recordNames)
+			if e != nil {
+				return nil, e
+			}
+			avroFieldType = avroSchemaRecord{Type: "record", Name: protoMessageName(field.Name, recordNames), Fields: nestedFields}
+		default:
+			return nil, errortools.ErrorMessagef("field %s has unsupported type %s", field.Name, field.Type)
+		}
+
+		avroField := avroSchemaField{
+			Name: field.Name,
+			Doc:  field.Description,
+		}
+
+		switch strings.ToUpper(field.Mode) {
+		case "REQUIRED":
+			avroField.Type = avroFieldType
+		case "REPEATED":
+			avroField.Type = avroSchemaArray{Type: "array", Items: avroFieldType}
+		default:
+			avroField.Type = []interface{}{"null", avroFieldType}
+			avroField.Default = json.RawMessage("null")
+		}
+
+		avroFields = append(avroFields, avroField)
+	}
+
+	return avroFields, nil
+}
+
+func isAvroName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// AvroSchemaToTableFields converts the JSON of an Avro record schema to table fields, as a load job with
+// useAvroLogicalTypes does. Unions of null and a type become NULLABLE fields, arrays REPEATED fields and
+// other fields REQUIRED. Types are mapped as follows:
+//
+//	string, enum                          STRING, or JSON, GEOGRAPHY resp. INTERVAL by sqlType
+//	string with logical type datetime     DATETIME
+//	bytes, fixed                          BYTES
+//	int, long                             INTEGER
+//	float, double                         FLOAT
+//	boolean                               BOOLEAN
+//	decimal                               NUMERIC if the scale is at most 9 and the number of integer digits at most 29,
+//	                                      otherwise BIGNUMERIC
+//	date                                  DATE
+//	time-millis, time-micros              TIME
+//	timestamp-millis, timestamp-micros    TIMESTAMP
+//	local-timestamp-millis/micros         DATETIME
+//	record                                RECORD
+//
+// Other unions, maps and arrays of arrays or of nullable values are not supported.
+func AvroSchemaToTableFields(schema string) ([]TableFieldSchema, *errortools.Error) {
+	record, e := parseAvroSchema(schema)
+	if e != nil {
+		return nil, e
+	}
+
+	return avroTableFields(record, 1)
+}
+
+func avroTableFields(record *avroType, depth int) ([]TableFieldSchema, *errortools.Error) {
+	if depth > maxSchemaNestingDepth {
+		return nil, errortools.ErrorMessagef("record %s exceeds the maximum nesting depth of %v", record.Name, maxSchemaNestingDepth)
+	}
+
+	fields := []TableFieldSchema{}
+
+	for _, avroField := range record.Fields {
+		field := TableFieldSchema{
+			Name:        avroField.Name,
+			Mode:        "REQUIRED",
+			Description: avroField.Doc,
+		}
+
+		t := avroField.Type
+		if t.Type == "union" {
+			nonNull := []*avroType{}
+			for _, member := range t.Union {
+				if member.Type != "null" {
+					nonNull = append(nonNull, member)
+				}
+			}
+			if len(nonNull) != 1 || len(t.Union) != 2 {
+				return nil, errortools.ErrorMessagef("field %s: only unions of null and a single type are supported", field.Name)
+			}
+			field.Mode = "NULLABLE"
+			t = nonNull[0]
+		}
+		if t.Type == "array" {
+			// BigQuery has no NULL arrays, a nullable array is loaded as an array that may be empty
+			field.Mode = "REPEATED"
+			t = t.Items
+			if t.Type == "array" || t.Type == "union" {
+				return nil, errortools.ErrorMessagef("field %s: arrays of %ss are not supported", field.Name, t.Type)
+			}
+		}
+
+		fieldType, e := avroFieldType(t)
+		if e != nil {
+			return nil, errortools.ErrorMessagef("field %s: %s", field.Name, e.Message())
+		}
+		field.Type = fieldType
+
+		if fieldType == "RECORD" {
+			nestedFields, e := avroTableFields(t, depth+1)
+			if e != nil {
+				return nil, e
+			}
+			field.Fields = nestedFields
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+func avroFieldType(t *avroType) (string, *errortools.Error) {
+	switch t.LogicalType {
+	case "decimal":
+		if t.Type == "bytes" || t.Type == "fixed" {
+			return decimalFieldType(int32(t.Precision), int32(t.Scale)), nil
+		}
+	case "date":
+		if t.Type == "int" {
+			return "DATE", nil
+		}
+	case "time-millis", "time-micros":
+		if t.Type == "int" || t.Type == "long" {
+			return "TIME", nil
+		}
+	case "timestamp-millis", "timestamp-micros":
+		if t.Type == "long" {
+			return "TIMESTAMP", nil
+		}
+	case "local-timestamp-millis", "local-timestamp-micros":
+		if t.Type == "long" {
+			return "DATETIME", nil
+		}
+	case "datetime":
+		if t.Type == "string" {
+			return "DATETIME", nil
+		}
+	}
+
+	switch t.Type {
+	case "string", "enum":
+		switch strings.ToUpper(t.SqlType) {
+		case "JSON", "GEOGRAPHY", "INTERVAL", "DATETIME":
+			return strings.ToUpper(t.SqlType), nil
+		}
+		return "STRING", nil
+	case "bytes", "fixed":
+		return "BYTES", nil
+	case "int", "long":
+		return "INTEGER", nil
+	case "float", "double":
+		return "FLOAT", nil
+	case "boolean":
+		return "BOOLEAN", nil
+	case "record":
+		return "RECORD", nil
+	}
+
+	return "", errortools.ErrorMessagef("unsupported Avro type %s", t.Type)
+}
@@ -0,0 +1,310 @@
+package googlebigquery
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+	go_types "github.com/leapforce-libraries/go_types"
+)
+
+// maxSchemaNestingDepth is the maximum number of nested RECORD levels BigQuery allows
+const maxSchemaNestingDepth int = 15
+
+var schemaFieldTypes = map[string]bool{
+	"STRING":     true,
+	"BYTES":      true,
+	"INTEGER":    true,
+	"INT64":      true,
+	"FLOAT":      true,
+	"FLOAT64":    true,
+	"NUMERIC":    true,
+	"BIGNUMERIC": true,
+	"BOOLEAN":    true,
+	"BOOL":       true,
+	"TIMESTAMP":  true,
+	"DATE":       true,
+	"TIME":       true,
+	"DATETIME":   true,
+	"GEOGRAPHY":  true,
+	"JSON":       true,
+	"INTERVAL":   true,
+	"RECORD":     true,
+	"STRUCT":     true,
+}
+
+// schemaFileField is a field as written by bq show --schema and read by bq mk --schema
+type schemaFileField struct {
+	Name        string                `json:"name"`
+	Type        string                `json:"type"`
+	Mode        string                `json:"mode,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Fields      []schemaFileField     `json:"fields,omitempty"`
+	PolicyTags  *schemaFilePolicyTags `json:"policyTags,omitempty"`
+	MaxLength   *schemaFileNumber     `json:"maxLength,omitempty"`
+	Precision   *schemaFileNumber     `json:"precision,omitempty"`
+	Scale       *schemaFileNumber     `json:"scale,omitempty"`
+}
+
+// schemaFileNumber is read from a number or a numeric string, and written as a string as the API does
+type schemaFileNumber json.Number
+
+func (n schemaFileNumber) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(n))
+}
+
+func (n *schemaFileNumber) UnmarshalJSON(b []byte) error {
+	var number json.Number
+	err := json.Unmarshal(b, &number)
+	if err != nil {
+		return err
+	}
+	*n = schemaFileNumber(number)
+	return nil
+}
+
+type schemaFilePolicyTags struct {
+	Names []string `json:"names"`
+}
+
+// ParseSchemaJSON parses a schema in the JSON format of the bq command-line tool, an array of fields.
+// An object with a fields array, as in the schema property of a table, is accepted as well.
+// Type names and modes are upper-cased, the schema is validated with ValidateSchema.
+func ParseSchemaJSON(b []byte) ([]TableFieldSchema, *errortools.Error) {
+	var fileFields []schemaFileField
+
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] == '{' {
+		var schema struct {
+			Fields []schemaFileField `json:"fields"`
+		}
+		err := json.Unmarshal(b, &schema)
+		if err != nil {
+			return nil, errortools.ErrorMessagef("invalid schema JSON: %s", err.Error())
+		}
+		fileFields = schema.Fields
+	} else {
+		err := json.Unmarshal(b, &fileFields)
+		if err != nil {
+			return nil, errortools.ErrorMessagef("invalid schema JSON: %s", err.Error())
+		}
+	}
+
+	fields, e := tableFieldsFromSchemaFile(fileFields, "")
+	if e != nil {
+		return nil, e
+	}
+
+	e = ValidateSchema(fields)
+	if e != nil {
+		return nil, e
+	}
+
+	return fields, nil
+}
+
+func tableFieldsFromSchemaFile(fileFields []schemaFileField, path string) ([]TableFieldSchema, *errortools.Error) {
+	fields := []TableFieldSchema{}
+
+	for _, fileField := range fileFields {
+		field := TableFieldSchema{
+			Name:        fileField.Name,
+			Type:        strings.ToUpper(fileField.Type),
+			Mode:        strings.ToUpper(fileField.Mode),
+			Description: fileField.Description,
+		}
+		fieldPath := path + fileField.Name
+
+		if fileField.PolicyTags != nil {
+			field.PolicyTags.Names = fileField.PolicyTags.Names
+		}
+
+		for _, number := range []struct {
+			name   string
+			value  *schemaFileNumber
+			target **go_types.Int64String
+		}{
+			{"maxLength", fileField.MaxLength, &field.MaxLength},
+			{"precision", fileField.Precision, &field.Precision},
+			{"scale", fileField.Scale, &field.Scale},
+		} {
+			if number.value == nil {
+				continue
+			}
+			i, err := strconv.ParseInt(string(*number.value), 10, 64)
+			if err != nil {
+				return nil, errortools.ErrorMessagef("field %s: invalid %s %s", fieldPath, number.name, string(*number.value))
+			}
+			value := go_types.Int64String(i)
+			*number.target = &value
+		}
+
+		if len(fileField.Fields) > 0 {
+			nestedFields, e := tableFieldsFromSchemaFile(fileField.Fields, fieldPath+".")
+			if e != nil {
+				return nil, e
+			}
+			field.Fields = nestedFields
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// ReadSchemaFile reads a schema file in the JSON format of the bq command-line tool
+func ReadSchemaFile(path string) (*TableSchema, *errortools.Error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errortools.ErrorMessage(err)
+	}
+
+	fields, e := ParseSchemaJSON(b)
+	if e != nil {
+		return nil, errortools.ErrorMessagef("%s: %s", path, e.Message())
+	}
+
+	return &TableSchema{Fields: fields}, nil
+}
+
+// MarshalSchemaJSON formats the fields in the JSON format of the bq command-line tool, indented by two spaces.
+// Empty attributes are omitted, as in the output of bq show --schema --format=prettyjson.
+func MarshalSchemaJSON(fields []TableFieldSchema) ([]byte, *errortools.Error) {
+	e := ValidateSchema(fields)
+	if e != nil {
+		return nil, e
+	}
+
+	b, err := json.MarshalIndent(schemaFileFields(fields), "", "  ")
+	if err != nil {
+		return nil, errortools.ErrorMessage(err)
+	}
+
+	return append(b, '\n'), nil
+}
+
+func schemaFileFields(fields []TableFieldSchema) []schemaFileField {
+	fileFields := []schemaFileField{}
+
+	for _, field := range fields {
+		fileField := schemaFileField{
+			Name:        field.Name,
+			Type:        field.Type,
+			Mode:        field.Mode,
+			Description: field.Description,
+		}
+		if len(field.PolicyTags.Names) > 0 {
+			fileField.PolicyTags = &schemaFilePolicyTags{Names: field.PolicyTags.Names}
+		}
+		if field.MaxLength != nil {
+			n := schemaFileNumber(strconv.FormatInt(int64(*field.MaxLength), 10))
+			fileField.MaxLength = &n
+		}
+		if field.Precision != nil {
+			n := schemaFileNumber(strconv.FormatInt(int64(*field.Precision), 10))
+			fileField.Precision = &n
+		}
+		if field.Scale != nil {
+			n := schemaFileNumber(strconv.FormatInt(int64(*field.Scale), 10))
+			fileField.Scale = &n
+		}
+		if len(field.Fields) > 0 {
+			fileField.Fields = schemaFileFields(field.Fields)
+		}
+
+		fileFields = append(fileFields, fileField)
+	}
+
+	return fileFields
+}
+
+// WriteSchemaFile writes the schema to a file in the JSON format of the bq command-line tool
+func WriteSchemaFile(path string, schema *TableSchema) *errortools.Error {
+	if schema == nil {
+		return errortools.ErrorMessage("TableSchema must not be a nil pointer")
+	}
+
+	b, e := MarshalSchemaJSON(schema.Fields)
+	if e != nil {
+		return e
+	}
+
+	err := os.WriteFile(path, b, 0644)
+	if err != nil {
+		return errortools.ErrorMessage(err)
+	}
+
+	return nil
+}
+
+// ValidateSchema checks the names, types and modes of the fields, and the nesting depth of RECORD fields
+func ValidateSchema(fields []TableFieldSchema) *errortools.Error {
+	if len(fields) == 0 {
+		return errortools.ErrorMessage("schema has no fields")
+	}
+
+	return validateSchemaFields(fields, "", 1)
+}
+
+func validateSchemaFields(fields []TableFieldSchema, path string, depth int) *errortools.Error {
+	names := map[string]bool{}
+
+	for _, field := range fields {
+		fieldPath := path + field.Name
+
+		if field.Name == "" {
+			return errortools.ErrorMessagef("field without name in %s", strings.TrimSuffix(path, "."))
+		}
+		if len(field.Name) > 300 {
+			return errortools.ErrorMessagef("field %s: name exceeds 300 characters", fieldPath)
+		}
+		// column names are case-insensitive
+		if names[strings.ToLower(field.Name)] {
+			return errortools.ErrorMessagef("duplicate field %s", fieldPath)
+		}
+		names[strings.ToLower(field.Name)] = true
+
+		fieldType := strings.ToUpper(field.Type)
+		if !schemaFieldTypes[fieldType] {
+			return errortools.ErrorMessagef("field %s: invalid type %s", fieldPath, field.Type)
+		}
+
+		switch strings.ToUpper(field.Mode) {
+		case "", "NULLABLE", "REQUIRED", "REPEATED":
+		default:
+			return errortools.ErrorMessagef("field %s: invalid mode %s", fieldPath, field.Mode)
+		}
+
+		if field.MaxLength != nil && fieldType != "STRING" && fieldType != "BYTES" {
+			return errortools.ErrorMessagef("field %s: maxLength is only allowed for STRING and BYTES", fieldPath)
+		}
+		if (field.Precision != nil || field.Scale != nil) && fieldType != "NUMERIC" && fieldType != "BIGNUMERIC" {
+			return errortools.ErrorMessagef("field %s: precision and scale are only allowed for NUMERIC and BIGNUMERIC", fieldPath)
+		}
+
+		if fieldType != "RECORD" && fieldType != "STRUCT" {
+			if len(field.Fields) > 0 {
+				return errortools.ErrorMessagef("field %s of type %s cannot have nested fields", fieldPath, field.Type)
+			}
+			continue
+		}
+
+		if len(field.Fields) == 0 {
+			return errortools.ErrorMessagef("field %s of type %s has no nested fields", fieldPath, field.Type)
+		}
+		if depth >= maxSchemaNestingDepth {
+			return errortools.ErrorMessagef("field %s exceeds the maximum nesting depth of %v", fieldPath, maxSchemaNestingDepth)
+		}
+
+		e := validateSchemaFields(field.Fields, fieldPath+".", depth+1)
+		if e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,285 @@
+package googlebigquery
+
+import (
+	"math"
+	"strings"
+
+	"github.com/apache/arrow/go/v12/parquet"
+	"github.com/apache/arrow/go/v12/parquet/schema"
+	errortools "github.com/leapforce-libraries/go_errortools"
+)
+
+// TableFieldsToParquetSchema converts the fields to a Parquet schema, as BigQuery exports them.
+//
+// NULLABLE fields are optional, REQUIRED fields required, REPEATED fields a three-level LIST of required elements
+// and RECORD fields a group. Types are mapped as follows:
+//
+//	STRING, GEOGRAPHY      BYTE_ARRAY annotated STRING
+//	JSON                   BYTE_ARRAY annotated JSON
+//	BYTES                  BYTE_ARRAY
+//	INTEGER                INT64
+//	FLOAT                  DOUBLE
+//	BOOLEAN                BOOLEAN
+//	NUMERIC                FIXED_LEN_BYTE_ARRAY annotated DECIMAL(38, 9), or the precision and scale of the field
+//	BIGNUMERIC             FIXED_LEN_BYTE_ARRAY annotated DECIMAL(76, 38), or the precision and scale of the field
+//	TIMESTAMP              INT64 annotated TIMESTAMP(isAdjustedToUTC=true, MICROS)
+//	DATE                   INT32 annotated DATE
+//	TIME                   INT64 annotated TIME(isAdjustedToUTC=false, MICROS)
+//	DATETIME               INT64 annotated TIMESTAMP(isAdjustedToUTC=false, MICROS)
+//
+// INTERVAL has no Parquet equivalent.
+func TableFieldsToParquetSchema(fields *[]TableFieldSchema) (*schema.Schema, *errortools.Error) {
+	if fields == nil {
+		return nil, errortools.ErrorMessage("fields must not be a nil pointer")
+	}
+
+	nodes, e := parquetNodes(*fields)
+	if e != nil {
+		return nil, e
+	}
+
+	root, err := schema.NewGroupNode("schema", parquet.Repetitions.Required, nodes, -1)
+	if err != nil {
+		return nil, errortools.ErrorMessage(err)
+	}
+
+	return schema.NewSchema(root), nil
+}
+
+func parquetNodes(fields []TableFieldSchema) (schema.FieldList, *errortools.Error) {
+	nodes := schema.FieldList{}
+
+	for _, field := range fields {
+		repetition := parquet.Repetitions.Optional
+		switch strings.ToUpper(field.Mode) {
+		case "REQUIRED", "REPEATED":
+			repetition = parquet.Repetitions.Required
+		}
+
+		name := field.Name
+		if strings.ToUpper(field.Mode) == "REPEATED" {
+			name = "element"
+		}
+
+		node, e := parquetNode(field, name, repetition)
+		if e != nil {
+			return nil, e
+		}
+
+		if strings.ToUpper(field.Mode) == "REPEATED" {
+			list, err := schema.NewGroupNode("list", parquet.Repetitions.Repeated, schema.FieldList{node}, -1)
+			if err != nil {
+				return nil, errortools.ErrorMessagef("field %s: %s", field.Name, err.Error())
+			}
+			node, err = schema.NewGroupNodeLogical(field.Name, parquet.Repetitions.Required, schema.FieldList{list}, schema.NewListLogicalType(), -1)
+			if err != nil {
+				return nil, errortools.ErrorMessagef("field %s: %s", field.Name, err.Error())
+			}
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	return nodes, nil
+}
+
+func parquetNode(field TableFieldSchema, name string, repetition parquet.Repetition) (schema.Node, *errortools.Error) {
+	var node schema.Node
+	var err error
+
+	switch normalizedFieldType(field.Type) {
+	case "STRING", "GEOGRAPHY":
+		node, err = schema.NewPrimitiveNodeLogical(name, repetition, schema.StringLogicalType{}, parquet.Types.ByteArray, -1, -1)
+	case "JSON":
+		node, err = schema.NewPrimitiveNodeLogical(name, repetition, schema.JSONLogicalType{}, parquet.Types.ByteArray, -1, -1)
+	case "BYTES":
+		node, err = schema.NewPrimitiveNode(name, repetition, parquet.Types.ByteArray, -1, -1)
+	case "INT64":
+		node, err = schema.NewPrimitiveNode(name, repetition, parquet.Types.Int64, -1, -1)
+	case "FLOAT64":
+		node, err = schema.NewPrimitiveNode(name, repetition, parquet.Types.Double, -1, -1)
+	case "BOOL":
+		node, err = schema.NewPrimitiveNode(name, repetition, parquet.Types.Boolean, -1, -1)
+	case "NUMERIC", "BIGNUMERIC":
+		precision, scale := decimalPrecisionScale(field, 38, 9)
+		if normalizedFieldType(field.Type) == "BIGNUMERIC" {
+			precision, scale = decimalPrecisionScale(field, 76, 38)
+		}
+		if precision < 1 || scale < 0 || scale > precision {
+			return nil, errortools.ErrorMessagef("field %s has invalid precision %v and scale %v", field.Name, precision, scale)
+		}
+		node, err = schema.NewPrimitiveNodeLogical(name, repetition, schema.NewDecimalLogicalType(precision, scale), parquet.Types.FixedLenByteArray, decimalByteLength(precision), -1)
+	case "TIMESTAMP":
+		node, err = schema.NewPrimitiveNodeLogical(name, repetition, schema.NewTimestampLogicalType(true, schema.TimeUnitMicros), parquet.Types.Int64, -1, -1)
+	case "DATETIME":
+		node, err = schema.NewPrimitiveNodeLogical(name, repetition, schema.NewTimestampLogicalType(false, schema.TimeUnitMicros), parquet.Types.Int64, -1, -1)
+	case "DATE":
+		node, err = schema.NewPrimitiveNodeLogical(name, repetition, schema.DateLogicalType{}, parquet.Types.Int32, -1, -1)
+	case "TIME":
+		node, err = schema.NewPrimitiveNodeLogical(name, repetition, schema.NewTimeLogicalType(false, schema.TimeUnitMicros), parquet.Types.Int64, -1, -1)
+	case "STRUCT":
+		nodes, e := parquetNodes(field.Fields)
+		if e != nil {
+			return nil, e
+		}
+		node, err = schema.NewGroupNode(name, repetition, nodes, -1)
+	default:
+		return nil, errortools.ErrorMessagef("field %s has unsupported type %s", field.Name, field.Type)
+	}
+	if err != nil {
+		return nil, errortools.ErrorMessagef("field %s: %s", field.Name, err.Error())
+	}
+
+	return node, nil
+}
+
+// decimalByteLength returns the number of bytes of the two's complement of an unscaled decimal of the given precision
+func decimalByteLength(precision int32) int {
+	return int(math.Ceil((float64(precision)*math.Log2(10) + 1) / 8))
+}
+
+// ParquetSchemaToTableFields converts a Parquet schema to table fields, as a load job with
+// enableListInference does. Optional fields become NULLABLE, required fields REQUIRED,
+// LIST groups and repeated fields REPEATED and other groups RECORD. Types are mapped as follows:
+//
+//	BYTE_ARRAY annotated STRING or ENUM         STRING
+//	BYTE_ARRAY annotated JSON                   JSON
+//	BYTE_ARRAY, FIXED_LEN_BYTE_ARRAY            BYTES
+//	INT32, INT64                                INTEGER, or NUMERIC if annotated as an unsigned 64 bit integer
+//	FLOAT, DOUBLE                               FLOAT
+//	BOOLEAN                                     BOOLEAN
+//	DECIMAL                                     NUMERIC if the scale is at most 9 and the number of integer digits at most 29,
+//	                                            otherwise BIGNUMERIC
+//	DATE                                        DATE
+//	TIME                                        TIME
+//	TIMESTAMP(isAdjustedToUTC=true), INT96      TIMESTAMP
+//	TIMESTAMP(isAdjustedToUTC=false)            DATETIME
+//
+// MAP groups and lists of lists are not supported.
+func ParquetSchemaToTableFields(parquetSchema *schema.Schema) ([]TableFieldSchema, *errortools.Error) {
+	if parquetSchema == nil {
+		return nil, errortools.ErrorMessage("schema must not be a nil pointer")
+	}
+
+	return parquetTableFields(parquetSchema.Root(), 1)
+}
+
+func parquetTableFields(group *schema.GroupNode, depth int) ([]TableFieldSchema, *errortools.Error) {
+	if depth > maxSchemaNestingDepth {
+		return nil, errortools.ErrorMessagef("schema exceeds the maximum nesting depth of %v", maxSchemaNestingDepth)
+	}
+
+	fields := []TableFieldSchema{}
+
+	for i := 0; i < group.NumFields(); i++ {
+		node := group.Field(i)
+
+		field := TableFieldSchema{
+			Name: node.Name(),
+		}
+
+		switch node.RepetitionType() {
+		case parquet.Repetitions.Required:
+			field.Mode = "REQUIRED"
+		case parquet.Repetitions.Repeated:
+			field.Mode = "REPEATED"
+		default:
+			field.Mode = "NULLABLE"
+		}
+
+		if element, ok := parquetListElement(node); ok {
+			// a nullable list is loaded as a list that may be empty
+			field.Mode = "REPEATED"
+			node = element
+			if _, ok := parquetListElement(node); ok || node.RepetitionType() == parquet.Repetitions.Repeated {
+				return nil, errortools.ErrorMessagef("field %s: lists of lists are not supported", field.Name)
+			}
+		}
+
+		switch n := node.(type) {
+		case *schema.GroupNode:
+			if _, ok := n.LogicalType().(schema.MapLogicalType); ok || n.ConvertedType() == schema.ConvertedTypes.Map || n.ConvertedType() == schema.ConvertedTypes.MapKeyValue {
+				return nil, errortools.ErrorMessagef("field %s: maps are not supported", field.Name)
+			}
+			nestedFields, e := parquetTableFields(n, depth+1)
+			if e != nil {
+				return nil, e
+			}
+			field.Type = "RECORD"
+			field.Fields = nestedFields
+		case *schema.PrimitiveNode:
+			fieldType, e := parquetFieldType(n)
+			if e != nil {
+				return nil, errortools.ErrorMessagef("field %s: %s", field.Name, e.Message())
+			}
+			field.Type = fieldType
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// parquetListElement returns the element of a LIST group, in the three-level as well as the legacy two-level structure
+func parquetListElement(node schema.Node) (schema.Node, bool) {
+	group, ok := node.(*schema.GroupNode)
+	if !ok || node.RepetitionType() == parquet.Repetitions.Repeated {
+		return nil, false
+	}
+	if _, ok := group.LogicalType().(schema.ListLogicalType); !ok && group.ConvertedType() != schema.ConvertedTypes.List {
+		return nil, false
+	}
+	if group.NumFields() != 1 || group.Field(0).RepetitionType() != parquet.Repetitions.Repeated {
+		return nil, false
+	}
+
+	repeated := group.Field(0)
+	repeatedGroup, ok := repeated.(*schema.GroupNode)
+	if !ok || repeatedGroup.NumFields() != 1 || repeated.Name() == "array" || repeated.Name() == group.Name()+"_tuple" {
+		// two-level structure, the repeated field is the element
+		return repeated, true
+	}
+
+	return repeatedGroup.Field(0), true
+}
+
+func parquetFieldType(node *schema.PrimitiveNode) (string, *errortools.Error) {
+	switch t := node.LogicalType().(type) {
+	case schema.StringLogicalType, schema.EnumLogicalType:
+		return "STRING", nil
+	case schema.JSONLogicalType:
+		return "JSON", nil
+	case *schema.DecimalLogicalType:
+		return decimalFieldType(t.Precision(), t.Scale()), nil
+	case schema.DateLogicalType:
+		return "DATE", nil
+	case *schema.TimeLogicalType:
+		return "TIME", nil
+	case *schema.TimestampLogicalType:
+		if t.IsAdjustedToUTC() {
+			return "TIMESTAMP", nil
+		}
+		return "DATETIME", nil
+	case *schema.IntLogicalType:
+		if t.BitWidth() == 64 && !t.IsSigned() {
+			return "NUMERIC", nil
+		}
+		return "INTEGER", nil
+	}
+
+	switch node.PhysicalType() {
+	case parquet.Types.Boolean:
+		return "BOOLEAN", nil
+	case parquet.Types.Int32, parquet.Types.Int64:
+		return "INTEGER", nil
+	case parquet.Types.Int96:
+		return "TIMESTAMP", nil
+	case parquet.Types.Float, parquet.Types.Double:
+		return "FLOAT", nil
+	case parquet.Types.ByteArray, parquet.Types.FixedLenByteArray:
+		return "BYTES", nil
+	}
+
+	return "", errortools.ErrorMessagef("unsupported Parquet type %s", node.PhysicalType().String())
+}
@@ -1,33 +1,206 @@
 package googlebigquery
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	errortools "github.com/leapforce-libraries/go_errortools"
 	google "github.com/leapforce-libraries/go_google"
+	go_http "github.com/leapforce-libraries/go_http"
+	oauth2 "github.com/leapforce-libraries/go_oauth2"
 )
 
 const (
-	apiName string = "GoogleBigQuery"
-	apiUrl  string = "https://bigquery.googleapis.com/bigquery/v2"
+	apiName      string = "GoogleBigQuery"
+	apiUrl       string = "https://bigquery.googleapis.com/bigquery/v2"
+	apiUploadUrl string = "https://bigquery.googleapis.com/upload/bigquery/v2"
+	// the OAuth2 endpoints of Google, as used by the google service
+	oAuth2AuthUrl     string = "https://accounts.google.com/o/oauth2/v2/auth"
+	oAuth2TokenUrl    string = "https://oauth2.googleapis.com/token"
+	oAuth2RedirectUrl string = "http://localhost:8080/oauth/redirect"
 )
 
 type Service struct {
-	googleService *google.Service
+	googleService    *google.Service
+	oAuth2Service    *oauth2.Service
+	retryPolicy      RetryPolicy
+	defaultLocation  *string
+	datasetLocations sync.Map
+	onDemandPricing  *OnDemandPricing
+	queryGuardrails  *QueryGuardrails
+	errorResponse    atomic.Pointer[google.ErrorResponse]
 }
 
+// NewServiceWithOAuth2 creates a service that sends its requests through an OAuth2 service of its own,
+// configured as the google service does. Unlike the google service it decodes error responses into a model
+// that includes the error reasons, see AsError.
 func NewServiceWithOAuth2(cfg *google.ServiceWithOAuth2Config) (*Service, *errortools.Error) {
 	googleService, e := google.NewServiceWithOAuth2(cfg)
 	if e != nil {
 		return nil, e
 	}
-	return &Service{googleService}, nil
+
+	redirectUrl := oAuth2RedirectUrl
+	if cfg.RedirectUrl != nil {
+		redirectUrl = *cfg.RedirectUrl
+	}
+
+	oAuth2Service, e := oauth2.NewService(&oauth2.ServiceConfig{
+		ClientId:        cfg.ClientId,
+		ClientSecret:    cfg.ClientSecret,
+		RedirectUrl:     redirectUrl,
+		AuthUrl:         oAuth2AuthUrl,
+		TokenUrl:        oAuth2TokenUrl,
+		RefreshMargin:   cfg.RefreshMargin,
+		TokenHttpMethod: http.MethodPost,
+		TokenSource:     cfg.TokenSource,
+	})
+	if e != nil {
+		return nil, e
+	}
+
+	return &Service{googleService: googleService, oAuth2Service: oAuth2Service}, nil
 }
 
 func (service *Service) url(path string) string {
 	return fmt.Sprintf("%s/%s", apiUrl, path)
 }
 
+func (service *Service) uploadUrl(path string) string {
+	return fmt.Sprintf("%s/%s", apiUploadUrl, path)
+}
+
+// SetRetryPolicy replaces the default retry behaviour by retryPolicy.
+// By default the http service retries requests that failed with a retriable status code, whatever the operation,
+// and Storage Write appends are retried by an ExponentialBackoffRetryPolicy.
+// Pass NoRetryPolicy{} to disable retries, or nil to restore the default behaviour.
+func (service *Service) SetRetryPolicy(retryPolicy RetryPolicy) {
+	service.retryPolicy = retryPolicy
+}
+
+// httpRequest executes requestConfig unless ctx is already done.
+// The underlying http service does not accept a context, so a request that
+// is in flight is not interrupted; cancellation is honoured between requests
+// and while waiting for a retry.
+func (service *Service) httpRequest(ctx context.Context, operation Operation, requestConfig *go_http.RequestConfig) (*http.Request, *http.Response, *errortools.Error) {
+	return service.httpRequestIdempotent(ctx, operation, operation.isIdempotent(requestConfig), requestConfig)
+}
+
+// httpRequestIdempotent is httpRequest for requests whose idempotency cannot be derived from requestConfig
+func (service *Service) httpRequestIdempotent(ctx context.Context, operation Operation, idempotent bool, requestConfig *go_http.RequestConfig) (*http.Request, *http.Response, *errortools.Error) {
+	if service.retryPolicy != nil {
+		// the retry policy takes over retrying from the http service
+		maxRetries := uint(0)
+		requestConfig.MaxRetries = &maxRetries
+	}
+
+	for attempt := uint(1); ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, errortools.ErrorMessage(err)
+		}
+
+		errorResponse := errorResponse{}
+		requestConfig.ErrorModel = &errorResponse
+
+		request, response, e := service.oAuth2Service.HttpRequest(requestConfig)
+		if e == nil {
+			return request, response, nil
+		}
+
+		err := service.responseError(e, response, &errorResponse)
+		if service.retryPolicy == nil {
+			return request, response, e
+		}
+
+		networkError := request != nil && response == nil
+		if err == nil {
+			if !networkError {
+				// e.g. the token could not be refreshed or the response could not be decoded
+				return request, response, e
+			}
+			err = NewError(e)
+		}
+
+		retryRequest := RetryRequest{
+			Operation:    operation,
+			Idempotent:   idempotent,
+			Attempt:      attempt,
+			StatusCode:   err.StatusCode,
+			NetworkError: networkError,
+			Reason:       err.Reason(),
+			Message:      err.Message,
+		}
+
+		wait, retry := service.retryPolicy.Retry(&retryRequest)
+		if !retry {
+			return request, response, e
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return request, response, e
+		case <-timer.C:
+		}
+	}
+}
+
+// errorResponse is the error model of the BigQuery API.
+// Unlike google.ErrorResponse it includes the errors list that holds the error reasons.
+type errorResponse struct {
+	Error struct {
+		Code    int          `json:"code"`
+		Message string       `json:"message"`
+		Status  string       `json:"status"`
+		Errors  []ErrorProto `json:"errors"`
+	} `json:"error"`
+}
+
+// responseError returns the *Error of e if e was caused by an error response, and keeps it next to e, see AsError.
+// Like the google service it replaces the message of e by the message of the error response.
+func (service *Service) responseError(e *errortools.Error, response *http.Response, errorResponse *errorResponse) *Error {
+	if response == nil || response.StatusCode/100 == 2 {
+		return nil
+	}
+
+	service.errorResponse.Store(&google.ErrorResponse{Error: struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		Details []struct {
+			Type   string `json:"@type"`
+			Errors []struct {
+				ErrorCode map[string]string `json:"errorCode"`
+				Message   string            `json:"message"`
+			} `json:"errors"`
+			RequestId string `json:"requestId"`
+		} `json:"details"`
+	}{
+		Code:    errorResponse.Error.Code,
+		Message: errorResponse.Error.Message,
+		Status:  errorResponse.Error.Status,
+	}})
+
+	if errorResponse.Error.Message != "" {
+		e.SetMessage(errorResponse.Error.Message)
+	}
+
+	err := Error{
+		StatusCode: response.StatusCode,
+		Status:     errorResponse.Error.Status,
+		Message:    e.Message(),
+		Errors:     errorResponse.Error.Errors,
+	}
+	setError(e, &err)
+
+	return AsError(e)
+}
+
 func (service *Service) ApiName() string {
 	return apiName
 }
@@ -37,13 +210,16 @@ func (service *Service) ApiKey() string {
 }
 
 func (service *Service) ApiCallCount() int64 {
-	return service.googleService.ApiCallCount()
+	return service.oAuth2Service.ApiCallCount()
 }
 
 func (service *Service) ApiReset() {
-	service.googleService.ApiReset()
+	service.oAuth2Service.ApiReset()
 }
 
+// ErrorResponse returns the error response of the last request that failed, of any goroutine.
+//
+// Deprecated: use AsError on the error returned by a method, which reflects that request only.
 func (service *Service) ErrorResponse() *google.ErrorResponse {
-	return service.googleService.ErrorResponse()
+	return service.errorResponse.Load()
 }
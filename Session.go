@@ -0,0 +1,153 @@
+package googlebigquery
+
+import (
+	"context"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+)
+
+const connectionPropertySessionId string = "session_id"
+
+// Session is a BigQuery session, queries run in the same session share
+// temporary tables, variables and transactions.
+type Session struct {
+	service   *Service
+	ProjectId string
+	SessionId string
+	Location  string
+}
+
+type CreateSessionConfig struct {
+	ProjectId      string
+	Location       *string
+	DefaultDataset *DatasetReference
+}
+
+func (service *Service) CreateSession(config *CreateSessionConfig) (*Session, *errortools.Error) {
+	return service.CreateSessionWithContext(context.Background(), config)
+}
+
+func (service *Service) CreateSessionWithContext(ctx context.Context, config *CreateSessionConfig) (*Session, *errortools.Error) {
+	if config == nil {
+		return nil, errortools.ErrorMessage("CreateSessionConfig must not be a nil pointer")
+	}
+
+	createSession := true
+
+	job, e := service.RunJobWithContext(ctx, &InsertJobConfig{
+		ProjectId: config.ProjectId,
+		Location:  config.Location,
+		Configuration: JobConfiguration{
+			Query: &JobConfigurationQuery{
+				Query:          "SELECT 1",
+				DefaultDataset: config.DefaultDataset,
+				CreateSession:  &createSession,
+			},
+		},
+	})
+	if e != nil {
+		return nil, e
+	}
+
+	if job.Statistics.SessionInfo == nil || job.Statistics.SessionInfo.SessionId == "" {
+		return nil, errortools.ErrorMessage("BigQuery did not return a session id")
+	}
+
+	return &Session{
+		service:   service,
+		ProjectId: config.ProjectId,
+		SessionId: job.Statistics.SessionInfo.SessionId,
+		Location:  job.JobReference.Location,
+	}, nil
+}
+
+// Query runs the query in the session and waits for it to be done
+func (session *Session) Query(query JobConfigurationQuery) (*Job, *errortools.Error) {
+	return session.QueryWithContext(context.Background(), query)
+}
+
+func (session *Session) QueryWithContext(ctx context.Context, query JobConfigurationQuery) (*Job, *errortools.Error) {
+	connectionProperties := []ConnectionProperty{}
+	if query.ConnectionProperties != nil {
+		for _, connectionProperty := range *query.ConnectionProperties {
+			if connectionProperty.Key != connectionPropertySessionId {
+				connectionProperties = append(connectionProperties, connectionProperty)
+			}
+		}
+	}
+	connectionProperties = append(connectionProperties, ConnectionProperty{
+		Key:   connectionPropertySessionId,
+		Value: session.SessionId,
+	})
+	query.ConnectionProperties = &connectionProperties
+
+	location := session.Location
+
+	return session.service.RunJobWithContext(ctx, &InsertJobConfig{
+		ProjectId: session.ProjectId,
+		Location:  &location,
+		Configuration: JobConfiguration{
+			Query: &query,
+		},
+	})
+}
+
+// Close terminates the session
+func (session *Session) Close() *errortools.Error {
+	return session.CloseWithContext(context.Background())
+}
+
+func (session *Session) CloseWithContext(ctx context.Context) *errortools.Error {
+	_, e := session.QueryWithContext(ctx, JobConfigurationQuery{
+		Query: "CALL BQ.ABORT_SESSION()",
+	})
+	return e
+}
+
+// RunInTransaction runs fn within a transaction in the session.
+// The transaction is committed if fn succeeds and rolled back if fn or the commit fails.
+func (session *Session) RunInTransaction(fn func(session *Session) *errortools.Error) *errortools.Error {
+	return session.RunInTransactionWithContext(context.Background(), fn)
+}
+
+func (session *Session) RunInTransactionWithContext(ctx context.Context, fn func(session *Session) *errortools.Error) *errortools.Error {
+	_, e := session.QueryWithContext(ctx, JobConfigurationQuery{
+		Query: "BEGIN TRANSACTION",
+	})
+	if e != nil {
+		return e
+	}
+
+	e = fn(session)
+	if e == nil {
+		_, e = session.QueryWithContext(ctx, JobConfigurationQuery{
+			Query: "COMMIT TRANSACTION",
+		})
+		if e == nil {
+			return nil
+		}
+	}
+
+	// a failed statement may already have ended the transaction, so a failing rollback is ignored;
+	// the rollback is not bound to ctx so that it also runs after ctx is cancelled
+	_, _ = session.QueryWithContext(context.Background(), JobConfigurationQuery{
+		Query: "ROLLBACK TRANSACTION",
+	})
+
+	return e
+}
+
+// RunInTransaction creates a session, runs fn within a transaction in it and closes the session
+func (service *Service) RunInTransaction(config *CreateSessionConfig, fn func(session *Session) *errortools.Error) *errortools.Error {
+	return service.RunInTransactionWithContext(context.Background(), config, fn)
+}
+
+func (service *Service) RunInTransactionWithContext(ctx context.Context, config *CreateSessionConfig, fn func(session *Session) *errortools.Error) *errortools.Error {
+	session, e := service.CreateSessionWithContext(ctx, config)
+	if e != nil {
+		return e
+	}
+	defer session.CloseWithContext(context.Background())
+
+	return session.RunInTransactionWithContext(ctx, fn)
+}
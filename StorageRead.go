@@ -0,0 +1,462 @@
+package googlebigquery
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	errortools "github.com/leapforce-libraries/go_errortools"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	storageApiEndpoint     string = "bigquerystorage.googleapis.com:443"
+	storageMaxMessageSize  int    = 128 << 20
+	storageReadMaxResumes  int    = 3
+	StorageDataFormatArrow string = "ARROW"
+	StorageDataFormatAvro  string = "AVRO"
+)
+
+// StorageReadClient reads tables through the BigQuery Storage Read API
+type StorageReadClient struct {
+	conn   grpc.ClientConnInterface
+	closer io.Closer
+	client storagepb.BigQueryReadClient
+}
+
+type StorageReadClientConfig struct {
+	// Conn is used instead of dialing the Storage API, e.g. a connection to a local fake server.
+	// It is not closed by Close.
+	Conn grpc.ClientConnInterface
+	// Endpoint defaults to bigquerystorage.googleapis.com:443
+	Endpoint *string
+	// DialOptions replace the default TLS transport and the OAuth2 token of the service
+	DialOptions []grpc.DialOption
+}
+
+func (service *Service) NewStorageReadClient(config *StorageReadClientConfig) (*StorageReadClient, *errortools.Error) {
+	if config == nil {
+		return nil, errortools.ErrorMessage("StorageReadClientConfig must not be a nil pointer")
+	}
+
+	conn, closer, e := service.storageConn(config.Conn, config.Endpoint, config.DialOptions)
+	if e != nil {
+		return nil, e
+	}
+
+	return &StorageReadClient{
+		conn:   conn,
+		closer: closer,
+		client: storagepb.NewBigQueryReadClient(conn),
+	}, nil
+}
+
+// storageConn returns conn if set, otherwise it dials the Storage API
+func (service *Service) storageConn(conn grpc.ClientConnInterface, endpoint *string, dialOptions []grpc.DialOption) (grpc.ClientConnInterface, io.Closer, *errortools.Error) {
+	if conn != nil {
+		return conn, nil, nil
+	}
+
+	target := storageApiEndpoint
+	if endpoint != nil {
+		target = *endpoint
+	}
+
+	if len(dialOptions) == 0 {
+		dialOptions = []grpc.DialOption{
+			grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{})),
+			grpc.WithPerRPCCredentials(&storageCredentials{service: service}),
+		}
+	}
+	dialOptions = append(dialOptions, grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(storageMaxMessageSize), grpc.MaxCallSendMsgSize(storageMaxMessageSize)))
+
+	clientConn, err := grpc.Dial(target, dialOptions...)
+	if err != nil {
+		return nil, nil, errortools.ErrorMessage(err)
+	}
+
+	return clientConn, clientConn, nil
+}
+
+// Close closes the connection to the Storage API, unless it was provided by the caller
+func (client *StorageReadClient) Close() *errortools.Error {
+	if client.closer == nil {
+		return nil
+	}
+
+	err := client.closer.Close()
+	if err != nil {
+		return errortools.ErrorMessage(err)
+	}
+
+	return nil
+}
+
+// storageCredentials passes the OAuth2 access token of the service to gRPC requests
+type storageCredentials struct {
+	service *Service
+}
+
+func (c *storageCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	if c.service == nil || c.service.oAuth2Service == nil {
+		// the service was not created by NewServiceWithOAuth2, so it has no token source
+		return nil, errors.New("the service has no OAuth2 token, provide credentials through DialOptions")
+	}
+
+	token, e := c.service.oAuth2Service.ValidateToken()
+	if e != nil {
+		return nil, errors.New(e.Message())
+	}
+	if token == nil || token.AccessToken == nil {
+		return nil, errors.New("no access token available")
+	}
+
+	return map[string]string{"authorization": fmt.Sprintf("Bearer %s", *token.AccessToken)}, nil
+}
+
+func (c *storageCredentials) RequireTransportSecurity() bool {
+	return true
+}
+
+func storageTablePath(table TableReference) string {
+	return fmt.Sprintf("projects/%s/datasets/%s/tables/%s", table.ProjectID, table.DatasetID, table.TableID)
+}
+
+// storageError converts a gRPC error
+func storageError(err error) *errortools.Error {
+	if s, ok := status.FromError(err); ok {
+		return errortools.ErrorMessagef("%s: %s", s.Code().String(), s.Message())
+	}
+	return errortools.ErrorMessage(err)
+}
+
+type CreateReadSessionConfig struct {
+	// ProjectId of the project billed for the read
+	ProjectId string
+	Table     TableReference
+	// SelectedFields are the columns to read, defaults to all columns
+	SelectedFields []string
+	// RowRestriction is a SQL filter on the rows to read, e.g. "country = 'NL'"
+	RowRestriction *string
+	// DataFormat is StorageDataFormatArrow (default) or StorageDataFormatAvro
+	DataFormat *string
+	// MaxStreams limits the number of streams, the Storage API decides if nil
+	MaxStreams   *int
+	SnapshotTime *time.Time
+}
+
+// ReadSession is a snapshot of a table split in streams that can be read concurrently
+type ReadSession struct {
+	client            *StorageReadClient
+	Name              string
+	Table             TableReference
+	DataFormat        string
+	Streams           []string
+	EstimatedRowCount int64
+	ExpireTime        *time.Time
+	arrowSchema       []byte
+	avroSchema        *avroType
+}
+
+func (client *StorageReadClient) CreateReadSession(config *CreateReadSessionConfig) (*ReadSession, *errortools.Error) {
+	return client.CreateReadSessionWithContext(context.Background(), config)
+}
+
+func (client *StorageReadClient) CreateReadSessionWithContext(ctx context.Context, config *CreateReadSessionConfig) (*ReadSession, *errortools.Error) {
+	if config == nil {
+		return nil, errortools.ErrorMessage("CreateReadSessionConfig must not be a nil pointer")
+	}
+
+	dataFormat := storagepb.DataFormat_ARROW
+	if config.DataFormat != nil {
+		switch *config.DataFormat {
+		case StorageDataFormatArrow:
+		case StorageDataFormatAvro:
+			dataFormat = storagepb.DataFormat_AVRO
+		default:
+			return nil, errortools.ErrorMessagef("invalid DataFormat %s", *config.DataFormat)
+		}
+	}
+
+	readOptions := storagepb.ReadSession_TableReadOptions{
+		SelectedFields: config.SelectedFields,
+	}
+	if config.RowRestriction != nil {
+		readOptions.RowRestriction = *config.RowRestriction
+	}
+
+	tablePath := storageTablePath(config.Table)
+
+	request := storagepb.CreateReadSessionRequest{
+		Parent: fmt.Sprintf("projects/%s", config.ProjectId),
+		ReadSession: &storagepb.ReadSession{
+			Table:       tablePath,
+			DataFormat:  dataFormat,
+			ReadOptions: &readOptions,
+		},
+	}
+	if config.MaxStreams != nil {
+		request.MaxStreamCount = int32(*config.MaxStreams)
+	}
+	if config.SnapshotTime != nil {
+		request.ReadSession.TableModifiers = &storagepb.ReadSession_TableModifiers{
+			SnapshotTime: timestamppb.New(*config.SnapshotTime),
+		}
+	}
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-goog-request-params", fmt.Sprintf("read_session.table=%s", tablePath))
+
+	session, err := client.client.CreateReadSession(ctx, &request)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	readSession := ReadSession{
+		client:            client,
+		Name:              session.Name,
+		Table:             config.Table,
+		DataFormat:        session.DataFormat.String(),
+		EstimatedRowCount: session.EstimatedRowCount,
+	}
+	if session.ExpireTime != nil {
+		expireTime := session.ExpireTime.AsTime()
+		readSession.ExpireTime = &expireTime
+	}
+	for _, stream := range session.Streams {
+		readSession.Streams = append(readSession.Streams, stream.Name)
+	}
+
+	switch session.DataFormat {
+	case storagepb.DataFormat_ARROW:
+		if session.GetArrowSchema() == nil {
+			return nil, errortools.ErrorMessage("read session has no Arrow schema")
+		}
+		readSession.arrowSchema = session.GetArrowSchema().SerializedSchema
+	case storagepb.DataFormat_AVRO:
+		if session.GetAvroSchema() == nil {
+			return nil, errortools.ErrorMessage("read session has no Avro schema")
+		}
+		avroSchema, e := parseAvroSchema(session.GetAvroSchema().Schema)
+		if e != nil {
+			return nil, e
+		}
+		readSession.avroSchema = avroSchema
+	default:
+		return nil, errortools.ErrorMessagef("unsupported data format %s", session.DataFormat.String())
+	}
+
+	return &readSession, nil
+}
+
+// ReadRows reads all streams concurrently, onRows is called concurrently for different streams
+// and the order of the rows across streams is undefined
+func (session *ReadSession) ReadRows(onRows func(rows []TableRow) *errortools.Error) *errortools.Error {
+	return session.ReadRowsWithContext(context.Background(), onRows)
+}
+
+func (session *ReadSession) ReadRowsWithContext(ctx context.Context, onRows func(rows []TableRow) *errortools.Error) *errortools.Error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var e *errortools.Error
+
+	for _, stream := range session.Streams {
+		wg.Add(1)
+		go func(stream string) {
+			defer wg.Done()
+
+			_e := session.ReadStreamWithContext(ctx, stream, onRows)
+			if _e != nil {
+				once.Do(func() {
+					e = _e
+					cancel()
+				})
+			}
+		}(stream)
+	}
+
+	wg.Wait()
+
+	return e
+}
+
+func (session *ReadSession) ReadStream(stream string, onRows func(rows []TableRow) *errortools.Error) *errortools.Error {
+	return session.ReadStreamWithContext(context.Background(), stream, onRows)
+}
+
+// ReadStreamWithContext reads a single stream, resuming at the last offset if the connection breaks
+func (session *ReadSession) ReadStreamWithContext(ctx context.Context, stream string, onRows func(rows []TableRow) *errortools.Error) *errortools.Error {
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-goog-request-params", fmt.Sprintf("read_stream=%s", stream))
+
+	var offset int64
+	resumes := 0
+
+	for {
+		if ctx.Err() != nil {
+			return errortools.ErrorMessage(ctx.Err())
+		}
+
+		readRowsClient, err := session.client.client.ReadRows(ctx, &storagepb.ReadRowsRequest{
+			ReadStream: stream,
+			Offset:     offset,
+		})
+		if err == nil {
+			for {
+				var response *storagepb.ReadRowsResponse
+				response, err = readRowsClient.Recv()
+				if err != nil {
+					break
+				}
+
+				rows, e := session.decodeRows(response)
+				if e != nil {
+					return e
+				}
+				offset += int64(len(rows))
+				resumes = 0
+
+				if len(rows) == 0 {
+					continue
+				}
+				e = onRows(rows)
+				if e != nil {
+					return e
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+
+		if resumes >= storageReadMaxResumes || !isResumableStorageError(err) {
+			return storageError(err)
+		}
+		resumes++
+
+		timer := time.NewTimer(time.Duration(resumes) * time.Second)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errortools.ErrorMessage(ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+func isResumableStorageError(err error) bool {
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+
+	switch s.Code() {
+	case codes.Unavailable:
+		return true
+	case codes.Internal:
+		// the connection was reset by the server
+		return strings.Contains(s.Message(), "RST_STREAM") || strings.Contains(s.Message(), "Received unexpected EOS")
+	}
+
+	return false
+}
+
+func (session *ReadSession) decodeRows(response *storagepb.ReadRowsResponse) ([]TableRow, *errortools.Error) {
+	if batch := response.GetArrowRecordBatch(); batch != nil {
+		return decodeArrowRows(session.arrowSchema, batch.SerializedRecordBatch)
+	}
+	if avroRows := response.GetAvroRows(); avroRows != nil {
+		return decodeAvroRows(session.avroSchema, avroRows.SerializedBinaryRows, avroRows.RowCount)
+	}
+	return nil, nil
+}
+
+// The functions below format values as tabledata.list does, so that rows read through the
+// Storage API are interchangeable with rows read through the REST API
+
+func recordCell(values []interface{}) interface{} {
+	f := make([]interface{}, len(values))
+	for i, value := range values {
+		f[i] = map[string]interface{}{"v": value}
+	}
+	return map[string]interface{}{"f": f}
+}
+
+func repeatedCell(values []interface{}) interface{} {
+	v := make([]interface{}, len(values))
+	for i, value := range values {
+		v[i] = map[string]interface{}{"v": value}
+	}
+	return v
+}
+
+// floatCell formats a float like Java's Double.toString, as the REST API does
+func floatCell(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+
+	abs := math.Abs(f)
+	if abs == 0 || (abs >= 1e-3 && abs < 1e7) {
+		s := strconv.FormatFloat(f, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s
+	}
+
+	s := strconv.FormatFloat(f, 'E', -1, 64)
+	mantissa, exponent, _ := strings.Cut(s, "E")
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+	exp, _ := strconv.Atoi(exponent)
+
+	return fmt.Sprintf("%sE%v", mantissa, exp)
+}
+
+// timestampCell formats microseconds since the epoch as seconds, as the REST API does
+func timestampCell(micros int64) string {
+	return floatCell(float64(micros) / 1e6)
+}
+
+func dateCell(days int64) string {
+	return time.Unix(days*86400, 0).UTC().Format("2006-01-02")
+}
+
+func timeCell(micros int64) string {
+	return time.UnixMicro(micros).UTC().Format("15:04:05.999999")
+}
+
+func datetimeCell(t time.Time) string {
+	return t.Format("2006-01-02T15:04:05.999999")
+}
+
+// decimalCell formats an unscaled integer with the given scale, without trailing zeros
+func decimalCell(unscaled *big.Int, scale int) string {
+	s := new(big.Rat).SetFrac(unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)).FloatString(scale)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
@@ -0,0 +1,116 @@
+package googlebigquery
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/apache/arrow/go/v12/arrow"
+	"github.com/apache/arrow/go/v12/arrow/array"
+	"github.com/apache/arrow/go/v12/arrow/ipc"
+	errortools "github.com/leapforce-libraries/go_errortools"
+)
+
+// decodeArrowRows decodes a serialized Arrow record batch, given the serialized schema of the read session
+func decodeArrowRows(serializedSchema []byte, serializedRecordBatch []byte) ([]TableRow, *errortools.Error) {
+	reader, err := ipc.NewReader(io.MultiReader(bytes.NewReader(serializedSchema), bytes.NewReader(serializedRecordBatch)))
+	if err != nil {
+		return nil, errortools.ErrorMessage(err)
+	}
+	defer reader.Release()
+
+	rows := []TableRow{}
+
+	for reader.Next() {
+		record := reader.Record()
+
+		for i := 0; i < int(record.NumRows()); i++ {
+			row := TableRow{F: make([]TableCell, record.NumCols())}
+			for j, column := range record.Columns() {
+				value, e := arrowCell(column, i)
+				if e != nil {
+					return nil, errortools.ErrorMessagef("column %s: %s", record.ColumnName(j), e.Message())
+				}
+				row.F[j] = TableCell{V: value}
+			}
+			rows = append(rows, row)
+		}
+	}
+	if reader.Err() != nil {
+		return nil, errortools.ErrorMessage(reader.Err())
+	}
+
+	return rows, nil
+}
+
+func arrowCell(column arrow.Array, i int) (interface{}, *errortools.Error) {
+	if column.IsNull(i) {
+		return nil, nil
+	}
+
+	switch a := column.(type) {
+	case *array.Int64:
+		return strconv.FormatInt(a.Value(i), 10), nil
+	case *array.Float64:
+		return floatCell(a.Value(i)), nil
+	case *array.Boolean:
+		return strconv.FormatBool(a.Value(i)), nil
+	case *array.String:
+		return a.Value(i), nil
+	case *array.Binary:
+		return base64.StdEncoding.EncodeToString(a.Value(i)), nil
+	case *array.Date32:
+		return dateCell(int64(a.Value(i))), nil
+	case *array.Time64:
+		return timeCell(arrowMicros(int64(a.Value(i)), a.DataType().(*arrow.Time64Type).Unit)), nil
+	case *array.Timestamp:
+		timestampType := a.DataType().(*arrow.TimestampType)
+		micros := arrowMicros(int64(a.Value(i)), timestampType.Unit)
+		if timestampType.TimeZone == "" {
+			// DATETIME
+			return datetimeCell(time.UnixMicro(micros).UTC()), nil
+		}
+		return timestampCell(micros), nil
+	case *array.Decimal128:
+		return decimalCell(a.Value(i).BigInt(), int(a.DataType().(*arrow.Decimal128Type).Scale)), nil
+	case *array.Decimal256:
+		return decimalCell(a.Value(i).BigInt(), int(a.DataType().(*arrow.Decimal256Type).Scale)), nil
+	case *array.List:
+		start, end := a.ValueOffsets(i)
+		values := []interface{}{}
+		for j := start; j < end; j++ {
+			value, e := arrowCell(a.ListValues(), int(j))
+			if e != nil {
+				return nil, e
+			}
+			values = append(values, value)
+		}
+		return repeatedCell(values), nil
+	case *array.Struct:
+		values := make([]interface{}, a.NumField())
+		for j := 0; j < a.NumField(); j++ {
+			value, e := arrowCell(a.Field(j), i)
+			if e != nil {
+				return nil, e
+			}
+			values[j] = value
+		}
+		return recordCell(values), nil
+	}
+
+	return nil, errortools.ErrorMessagef("unsupported Arrow type %s", column.DataType().String())
+}
+
+func arrowMicros(value int64, unit arrow.TimeUnit) int64 {
+	switch unit {
+	case arrow.Second:
+		return value * 1e6
+	case arrow.Millisecond:
+		return value * 1e3
+	case arrow.Nanosecond:
+		return value / 1e3
+	}
+	return value
+}
@@ -0,0 +1,340 @@
+package googlebigquery
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+)
+
+// avroType is the subset of an Avro schema needed to decode the rows of the Storage Read API
+// and to convert Avro schemas to table schemas
+type avroType struct {
+	Type        string
+	Name        string
+	LogicalType string
+	SqlType     string
+	Precision   int
+	Scale       int
+	Size        int
+	Fields      []avroField
+	Items       *avroType
+	Union       []*avroType
+	Symbols     []string
+}
+
+type avroField struct {
+	Name string
+	Doc  string
+	Type *avroType
+}
+
+func parseAvroSchema(schema string) (*avroType, *errortools.Error) {
+	var raw interface{}
+	err := json.Unmarshal([]byte(schema), &raw)
+	if err != nil {
+		return nil, errortools.ErrorMessagef("invalid Avro schema: %s", err.Error())
+	}
+
+	t, e := parseAvroType(raw, map[string]*avroType{})
+	if e != nil {
+		return nil, e
+	}
+	if t.Type != "record" {
+		return nil, errortools.ErrorMessagef("Avro schema is of type %s instead of record", t.Type)
+	}
+
+	return t, nil
+}
+
+func parseAvroType(raw interface{}, named map[string]*avroType) (*avroType, *errortools.Error) {
+	switch r := raw.(type) {
+	case string:
+		switch r {
+		case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+			return &avroType{Type: r}, nil
+		}
+		if t, ok := named[r]; ok {
+			return t, nil
+		}
+		return nil, errortools.ErrorMessagef("unknown Avro type %s", r)
+	case []interface{}:
+		t := avroType{Type: "union"}
+		for _, member := range r {
+			memberType, e := parseAvroType(member, named)
+			if e != nil {
+				return nil, e
+			}
+			t.Union = append(t.Union, memberType)
+		}
+		return &t, nil
+	case map[string]interface{}:
+		typeName, _ := r["type"].(string)
+		if typeName == "" {
+			// the type itself is a schema, e.g. {"type": {"type": "array", ...}}
+			return parseAvroType(r["type"], named)
+		}
+
+		t := avroType{Type: typeName}
+		t.Name, _ = r["name"].(string)
+		t.LogicalType, _ = r["logicalType"].(string)
+		t.SqlType, _ = r["sqlType"].(string)
+		if precision, ok := r["precision"].(float64); ok {
+			t.Precision = int(precision)
+		}
+		if scale, ok := r["scale"].(float64); ok {
+			t.Scale = int(scale)
+		}
+		if size, ok := r["size"].(float64); ok {
+			t.Size = int(size)
+		}
+
+		switch typeName {
+		case "record":
+			if t.Name != "" {
+				named[t.Name] = &t
+			}
+			fields, _ := r["fields"].([]interface{})
+			for _, field := range fields {
+				f, _ := field.(map[string]interface{})
+				name, _ := f["name"].(string)
+				doc, _ := f["doc"].(string)
+				fieldType, e := parseAvroType(f["type"], named)
+				if e != nil {
+					return nil, e
+				}
+				t.Fields = append(t.Fields, avroField{Name: name, Doc: doc, Type: fieldType})
+			}
+		case "array":
+			items, e := parseAvroType(r["items"], named)
+			if e != nil {
+				return nil, e
+			}
+			t.Items = items
+		case "enum":
+			symbols, _ := r["symbols"].([]interface{})
+			for _, symbol := range symbols {
+				s, _ := symbol.(string)
+				t.Symbols = append(t.Symbols, s)
+			}
+			if t.Name != "" {
+				named[t.Name] = &t
+			}
+		case "fixed":
+			if t.Name != "" {
+				named[t.Name] = &t
+			}
+		case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+		default:
+			return nil, errortools.ErrorMessagef("unknown Avro type %s", typeName)
+		}
+
+		return &t, nil
+	}
+
+	return nil, errortools.ErrorMessage("invalid Avro schema")
+}
+
+// avroDecoder decodes the Avro binary encoding
+type avroDecoder struct {
+	b   []byte
+	pos int
+}
+
+// decodeAvroRows decodes rowCount concatenated Avro records
+func decodeAvroRows(schema *avroType, serializedBinaryRows []byte, rowCount int64) ([]TableRow, *errortools.Error) {
+	if schema == nil {
+		return nil, errortools.ErrorMessage("read session has no Avro schema")
+	}
+
+	decoder := avroDecoder{b: serializedBinaryRows}
+	rows := []TableRow{}
+
+	for decoder.pos < len(decoder.b) && (rowCount <= 0 || int64(len(rows)) < rowCount) {
+		row := TableRow{F: make([]TableCell, len(schema.Fields))}
+		for i, field := range schema.Fields {
+			value, e := decoder.cell(field.Type)
+			if e != nil {
+				return nil, errortools.ErrorMessagef("column %s: %s", field.Name, e.Message())
+			}
+			row.F[i] = TableCell{V: value}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func (decoder *avroDecoder) cell(t *avroType) (interface{}, *errortools.Error) {
+	switch t.Type {
+	case "null":
+		return nil, nil
+	case "union":
+		index, e := decoder.long()
+		if e != nil {
+			return nil, e
+		}
+		if index < 0 || int(index) >= len(t.Union) {
+			return nil, errortools.ErrorMessagef("invalid union index %v", index)
+		}
+		return decoder.cell(t.Union[index])
+	case "boolean":
+		if decoder.pos >= len(decoder.b) {
+			return nil, errortools.ErrorMessage("unexpected end of Avro data")
+		}
+		v := decoder.b[decoder.pos] != 0
+		decoder.pos++
+		return strconv.FormatBool(v), nil
+	case "int", "long":
+		v, e := decoder.long()
+		if e != nil {
+			return nil, e
+		}
+		switch t.LogicalType {
+		case "date":
+			return dateCell(v), nil
+		case "time-micros":
+			return timeCell(v), nil
+		case "time-millis":
+			return timeCell(v * 1e3), nil
+		case "timestamp-micros":
+			return timestampCell(v), nil
+		case "timestamp-millis":
+			return timestampCell(v * 1e3), nil
+		}
+		return strconv.FormatInt(v, 10), nil
+	case "float":
+		b, e := decoder.fixed(4)
+		if e != nil {
+			return nil, e
+		}
+		return floatCell(float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))), nil
+	case "double":
+		b, e := decoder.fixed(8)
+		if e != nil {
+			return nil, e
+		}
+		return floatCell(math.Float64frombits(binary.LittleEndian.Uint64(b))), nil
+	case "string":
+		b, e := decoder.bytes()
+		if e != nil {
+			return nil, e
+		}
+		s := string(b)
+		if strings.EqualFold(t.SqlType, "DATETIME") || t.LogicalType == "datetime" {
+			// the Avro encoding separates date and time with a space
+			s = strings.Replace(s, " ", "T", 1)
+		}
+		return s, nil
+	case "bytes", "fixed":
+		var b []byte
+		var e *errortools.Error
+		if t.Type == "fixed" {
+			b, e = decoder.fixed(t.Size)
+		} else {
+			b, e = decoder.bytes()
+		}
+		if e != nil {
+			return nil, e
+		}
+		if t.LogicalType == "decimal" {
+			return decimalCell(twosComplement(b), t.Scale), nil
+		}
+		return base64.StdEncoding.EncodeToString(b), nil
+	case "enum":
+		index, e := decoder.long()
+		if e != nil {
+			return nil, e
+		}
+		if index < 0 || int(index) >= len(t.Symbols) {
+			return nil, errortools.ErrorMessagef("invalid enum index %v", index)
+		}
+		return t.Symbols[index], nil
+	case "array":
+		values := []interface{}{}
+		for {
+			count, e := decoder.long()
+			if e != nil {
+				return nil, e
+			}
+			if count == 0 {
+				break
+			}
+			if count < 0 {
+				// a negative count is followed by the size of the block in bytes
+				count = -count
+				_, e = decoder.long()
+				if e != nil {
+					return nil, e
+				}
+			}
+			for i := int64(0); i < count; i++ {
+				value, e := decoder.cell(t.Items)
+				if e != nil {
+					return nil, e
+				}
+				values = append(values, value)
+			}
+		}
+		return repeatedCell(values), nil
+	case "record":
+		values := make([]interface{}, len(t.Fields))
+		for i, field := range t.Fields {
+			value, e := decoder.cell(field.Type)
+			if e != nil {
+				return nil, e
+			}
+			values[i] = value
+		}
+		return recordCell(values), nil
+	}
+
+	return nil, errortools.ErrorMessagef("unsupported Avro type %s", t.Type)
+}
+
+// long decodes a zigzag encoded variable-length integer
+func (decoder *avroDecoder) long() (int64, *errortools.Error) {
+	v, n := binary.Uvarint(decoder.b[decoder.pos:])
+	if n <= 0 {
+		return 0, errortools.ErrorMessage("invalid Avro integer")
+	}
+	decoder.pos += n
+
+	return int64(v>>1) ^ -int64(v&1), nil
+}
+
+func (decoder *avroDecoder) bytes() ([]byte, *errortools.Error) {
+	length, e := decoder.long()
+	if e != nil {
+		return nil, e
+	}
+	if length < 0 {
+		return nil, errortools.ErrorMessagef("invalid Avro length %v", length)
+	}
+
+	return decoder.fixed(int(length))
+}
+
+func (decoder *avroDecoder) fixed(size int) ([]byte, *errortools.Error) {
+	if decoder.pos+size > len(decoder.b) {
+		return nil, errortools.ErrorMessage("unexpected end of Avro data")
+	}
+	b := decoder.b[decoder.pos : decoder.pos+size]
+	decoder.pos += size
+
+	return b, nil
+}
+
+// twosComplement decodes a big-endian two's complement integer
+func twosComplement(b []byte) *big.Int {
+	i := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		i.Sub(i, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+	return i
+}
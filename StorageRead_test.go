@@ -0,0 +1,215 @@
+package googlebigquery
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"github.com/apache/arrow/go/v12/arrow"
+	"github.com/apache/arrow/go/v12/arrow/array"
+	"github.com/apache/arrow/go/v12/arrow/ipc"
+	"github.com/apache/arrow/go/v12/arrow/memory"
+	errortools "github.com/leapforce-libraries/go_errortools"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const testAvroSchema = `{"type":"record","name":"Root","fields":[{"name":"name","type":["null","string"]},{"name":"n","type":["null","long"]}]}`
+
+type testRow struct {
+	name string
+	n    int64
+}
+
+// testStreams holds the rows the fake server returns per stream
+var testStreams = map[string][]testRow{
+	"streams/0": {{"a", 1}, {"b", 2}},
+	"streams/1": {{"c", 3}},
+}
+
+// fakeReadServer serves testStreams in the data format requested by CreateReadSession
+type fakeReadServer struct {
+	storagepb.UnimplementedBigQueryReadServer
+	t          *testing.T
+	dataFormat storagepb.DataFormat
+}
+
+func (server *fakeReadServer) CreateReadSession(ctx context.Context, request *storagepb.CreateReadSessionRequest) (*storagepb.ReadSession, error) {
+	server.dataFormat = request.ReadSession.DataFormat
+
+	session := storagepb.ReadSession{
+		Name:       "sessions/test",
+		Table:      request.ReadSession.Table,
+		DataFormat: request.ReadSession.DataFormat,
+	}
+	for _, stream := range []string{"streams/0", "streams/1"} {
+		session.Streams = append(session.Streams, &storagepb.ReadStream{Name: stream})
+	}
+
+	switch request.ReadSession.DataFormat {
+	case storagepb.DataFormat_AVRO:
+		session.Schema = &storagepb.ReadSession_AvroSchema{AvroSchema: &storagepb.AvroSchema{Schema: testAvroSchema}}
+	default:
+		schema, _ := testArrowBatch(server.t, nil)
+		session.Schema = &storagepb.ReadSession_ArrowSchema{ArrowSchema: &storagepb.ArrowSchema{SerializedSchema: schema}}
+	}
+
+	return &session, nil
+}
+
+func (server *fakeReadServer) ReadRows(request *storagepb.ReadRowsRequest, stream storagepb.BigQueryRead_ReadRowsServer) error {
+	rows := testStreams[request.ReadStream][request.Offset:]
+
+	response := storagepb.ReadRowsResponse{RowCount: int64(len(rows))}
+	switch server.dataFormat {
+	case storagepb.DataFormat_AVRO:
+		response.Rows = &storagepb.ReadRowsResponse_AvroRows{AvroRows: &storagepb.AvroRows{SerializedBinaryRows: testAvroRows(rows)}}
+	default:
+		_, batch := testArrowBatch(server.t, rows)
+		response.Rows = &storagepb.ReadRowsResponse_ArrowRecordBatch{ArrowRecordBatch: &storagepb.ArrowRecordBatch{SerializedRecordBatch: batch}}
+	}
+
+	return stream.Send(&response)
+}
+
+// testArrowBatch serializes rows as the Storage API does, with the schema separate from the record batch
+func testArrowBatch(t *testing.T, rows []testRow) ([]byte, []byte) {
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "n", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+	}, nil)
+
+	// a stream without records holds the schema followed by an end-of-stream marker of 8 bytes
+	schemaOnly := bytes.Buffer{}
+	writer := ipc.NewWriter(&schemaOnly, ipc.WithSchema(schema))
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	serializedSchema := schemaOnly.Bytes()[:schemaOnly.Len()-8]
+
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer builder.Release()
+	for _, row := range rows {
+		builder.Field(0).(*array.StringBuilder).Append(row.name)
+		builder.Field(1).(*array.Int64Builder).Append(row.n)
+	}
+	record := builder.NewRecord()
+	defer record.Release()
+
+	stream := bytes.Buffer{}
+	writer = ipc.NewWriter(&stream, ipc.WithSchema(schema))
+	if err := writer.Write(record); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return serializedSchema, stream.Bytes()[len(serializedSchema):]
+}
+
+// testAvroRows serializes rows in the Avro binary encoding of testAvroSchema
+func testAvroRows(rows []testRow) []byte {
+	long := func(b []byte, v int64) []byte {
+		u := uint64(v<<1) ^ uint64(v>>63)
+		for u >= 0x80 {
+			b = append(b, byte(u)|0x80)
+			u >>= 7
+		}
+		return append(b, byte(u))
+	}
+
+	b := []byte{}
+	for _, row := range rows {
+		// union index 1 selects the non-null branch
+		b = long(b, 1)
+		b = long(b, int64(len(row.name)))
+		b = append(b, row.name...)
+		b = long(b, 1)
+		b = long(b, row.n)
+	}
+	return b
+}
+
+func newTestStorageReadClient(t *testing.T) *StorageReadClient {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	storagepb.RegisterBigQueryReadServer(server, &fakeReadServer{t: t})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	client, e := (&Service{}).NewStorageReadClient(&StorageReadClientConfig{Conn: conn})
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+	return client
+}
+
+func TestReadSession(t *testing.T) {
+	for _, dataFormat := range []string{StorageDataFormatArrow, StorageDataFormatAvro} {
+		t.Run(dataFormat, func(t *testing.T) {
+			client := newTestStorageReadClient(t)
+
+			session, e := client.CreateReadSession(&CreateReadSessionConfig{
+				ProjectId:  "project",
+				Table:      TableReference{ProjectID: "project", DatasetID: "dataset", TableID: "table"},
+				DataFormat: &dataFormat,
+			})
+			if e != nil {
+				t.Fatal(e.Message())
+			}
+			if session.DataFormat != dataFormat {
+				t.Errorf("DataFormat = %s, want %s", session.DataFormat, dataFormat)
+			}
+			if len(session.Streams) != 2 {
+				t.Fatalf("got %v streams, want 2", len(session.Streams))
+			}
+
+			var mutex sync.Mutex
+			got := []string{}
+			e = session.ReadRows(func(rows []TableRow) *errortools.Error {
+				mutex.Lock()
+				defer mutex.Unlock()
+				for _, row := range rows {
+					got = append(got, row.F[0].V.(string)+"="+row.F[1].V.(string))
+				}
+				return nil
+			})
+			if e != nil {
+				t.Fatal(e.Message())
+			}
+
+			sort.Strings(got)
+			want := []string{"a=1", "b=2", "c=3"}
+			if len(got) != len(want) {
+				t.Fatalf("got rows %v, want %v", got, want)
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Errorf("row %v = %s, want %s", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStorageCredentialsWithoutOAuth2(t *testing.T) {
+	_, err := (&storageCredentials{service: &Service{}}).GetRequestMetadata(context.Background())
+	if err == nil {
+		t.Fatal("expected an error for a service without OAuth2 token")
+	}
+}
@@ -0,0 +1,567 @@
+package googlebigquery
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	errortools "github.com/leapforce-libraries/go_errortools"
+	go_types "github.com/leapforce-libraries/go_types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	WriteStreamTypeDefault   string = "DEFAULT"
+	WriteStreamTypeCommitted string = "COMMITTED"
+	WriteStreamTypePending   string = "PENDING"
+	WriteStreamTypeBuffered  string = "BUFFERED"
+	storageWriteMessageName  string = "Row"
+)
+
+// StorageWriteClient writes rows to tables through the BigQuery Storage Write API
+type StorageWriteClient struct {
+	service *Service
+	conn    grpc.ClientConnInterface
+	closer  io.Closer
+	client  storagepb.BigQueryWriteClient
+}
+
+type StorageWriteClientConfig struct {
+	// Conn is used instead of dialing the Storage API, e.g. a connection to a local fake server.
+	// It is not closed by Close.
+	Conn grpc.ClientConnInterface
+	// Endpoint defaults to bigquerystorage.googleapis.com:443
+	Endpoint *string
+	// DialOptions replace the default TLS transport and the OAuth2 token of the service,
+	// they are required for services that were not created with OAuth2
+	DialOptions []grpc.DialOption
+}
+
+func (service *Service) NewStorageWriteClient(config *StorageWriteClientConfig) (*StorageWriteClient, *errortools.Error) {
+	if config == nil {
+		return nil, errortools.ErrorMessage("StorageWriteClientConfig must not be a nil pointer")
+	}
+
+	conn, closer, e := service.storageConn(config.Conn, config.Endpoint, config.DialOptions)
+	if e != nil {
+		return nil, e
+	}
+
+	return &StorageWriteClient{
+		service: service,
+		conn:    conn,
+		closer:  closer,
+		client:  storagepb.NewBigQueryWriteClient(conn),
+	}, nil
+}
+
+// Close closes the connection to the Storage API, unless it was provided by the caller
+func (client *StorageWriteClient) Close() *errortools.Error {
+	if client.closer == nil {
+		return nil
+	}
+
+	err := client.closer.Close()
+	if err != nil {
+		return errortools.ErrorMessage(err)
+	}
+
+	return nil
+}
+
+type StorageWriterConfig struct {
+	Table TableReference
+	// StreamType is one of:
+	//   WriteStreamTypeDefault (default): rows are visible immediately, appends are at-least-once
+	//   WriteStreamTypeCommitted: rows are visible immediately, appends are exactly-once through offsets
+	//   WriteStreamTypePending: rows become visible when the stream is finalized and committed with BatchCommit
+	//   WriteStreamTypeBuffered: rows become visible when they are flushed
+	StreamType *string
+	// Schema defaults to the schema of the table
+	Schema *TableSchema
+	// AllowDuplicates permits retrying failed appends to the default stream,
+	// at the risk of writing rows more than once
+	AllowDuplicates bool
+	// UpdateSchema makes the writer switch to the schema the Storage API reports after the table schema changed,
+	// so that subsequent appends can contain the added columns
+	UpdateSchema bool
+}
+
+// StorageWriter appends rows to a single write stream
+type StorageWriter struct {
+	client          *StorageWriteClient
+	Table           TableReference
+	Stream          string
+	StreamType      string
+	allowDuplicates bool
+	updateSchema    bool
+	// UpdatedSchema is the latest table schema reported by the Storage API, nil if the schema did not change
+	UpdatedSchema *TableSchema
+	schema        *ProtoSchema
+	mutex         sync.Mutex
+	appendClient  storagepb.BigQueryWrite_AppendRowsClient
+	cancel        context.CancelFunc
+	schemaSent    bool
+	offset        int64
+}
+
+func (client *StorageWriteClient) NewStorageWriter(config *StorageWriterConfig) (*StorageWriter, *errortools.Error) {
+	return client.NewStorageWriterWithContext(context.Background(), config)
+}
+
+// NewStorageWriterWithContext opens the default stream of the table or creates a new write stream
+func (client *StorageWriteClient) NewStorageWriterWithContext(ctx context.Context, config *StorageWriterConfig) (*StorageWriter, *errortools.Error) {
+	if config == nil {
+		return nil, errortools.ErrorMessage("StorageWriterConfig must not be a nil pointer")
+	}
+
+	streamType := WriteStreamTypeDefault
+	if config.StreamType != nil {
+		streamType = *config.StreamType
+	}
+
+	tablePath := storageTablePath(config.Table)
+
+	writer := StorageWriter{
+		client:          client,
+		Table:           config.Table,
+		StreamType:      streamType,
+		allowDuplicates: config.AllowDuplicates,
+		updateSchema:    config.UpdateSchema,
+	}
+
+	var tableSchema *storagepb.TableSchema
+
+	switch streamType {
+	case WriteStreamTypeDefault:
+		writer.Stream = fmt.Sprintf("%s/streams/_default", tablePath)
+
+		if config.Schema == nil {
+			stream, err := client.client.GetWriteStream(metadata.AppendToOutgoingContext(ctx, "x-goog-request-params", fmt.Sprintf("name=%s", writer.Stream)), &storagepb.GetWriteStreamRequest{
+				Name: writer.Stream,
+				View: storagepb.WriteStreamView_FULL,
+			})
+			if err != nil {
+				return nil, storageError(err)
+			}
+			tableSchema = stream.TableSchema
+		}
+	case WriteStreamTypeCommitted, WriteStreamTypePending, WriteStreamTypeBuffered:
+		stream, err := client.client.CreateWriteStream(metadata.AppendToOutgoingContext(ctx, "x-goog-request-params", fmt.Sprintf("parent=%s", tablePath)), &storagepb.CreateWriteStreamRequest{
+			Parent: tablePath,
+			WriteStream: &storagepb.WriteStream{
+				Type: storagepb.WriteStream_Type(storagepb.WriteStream_Type_value[streamType]),
+			},
+		})
+		if err != nil {
+			return nil, storageError(err)
+		}
+		writer.Stream = stream.Name
+		tableSchema = stream.TableSchema
+	default:
+		return nil, errortools.ErrorMessagef("invalid StreamType %s", streamType)
+	}
+
+	var fields []TableFieldSchema
+	if config.Schema != nil {
+		fields = config.Schema.Fields
+	} else {
+		if tableSchema == nil {
+			return nil, errortools.ErrorMessagef("write stream %s has no table schema", writer.Stream)
+		}
+		fields = storageTableFields(tableSchema.Fields)
+	}
+
+	schema, e := NewProtoSchema(fields, storageWriteMessageName)
+	if e != nil {
+		return nil, e
+	}
+	writer.schema = schema
+
+	return &writer, nil
+}
+
+// Offset returns the offset at which the next append to a committed, pending or buffered stream is written
+func (writer *StorageWriter) Offset() int64 {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	return writer.offset
+}
+
+// SetOffset sets the offset of the next append, e.g. to resume writing to a stream after a restart
+func (writer *StorageWriter) SetOffset(offset int64) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.offset = offset
+}
+
+// Append writes rows, a slice of structs or of maps with string keys, in a single request
+func (writer *StorageWriter) Append(rows interface{}) *errortools.Error {
+	return writer.AppendWithContext(context.Background(), rows)
+}
+
+func (writer *StorageWriter) AppendWithContext(ctx context.Context, rows interface{}) *errortools.Error {
+	v := indirect(reflect.ValueOf(rows))
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return errortools.ErrorMessage("rows must be a slice")
+	}
+	if v.Len() == 0 {
+		return nil
+	}
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	serializedRows := make([][]byte, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		message, e := writer.schema.NewMessage(v.Index(i).Interface())
+		if e != nil {
+			return errortools.ErrorMessagef("row %v: %s", i, e.Message())
+		}
+		b, err := proto.Marshal(message)
+		if err != nil {
+			return errortools.ErrorMessagef("row %v: %s", i, err.Error())
+		}
+		serializedRows[i] = b
+	}
+
+	retryPolicy := writer.client.service.retryPolicy
+	if retryPolicy == nil {
+		// the http service does not handle gRPC requests, so appends get a policy of their own
+		retryPolicy = NewExponentialBackoffRetryPolicy()
+	}
+
+	for attempt := uint(1); ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return errortools.ErrorMessage(err)
+		}
+
+		response, err := writer.send(ctx, serializedRows)
+		if err != nil {
+			// the connection is unusable, the next attempt reconnects
+			writer.disconnect()
+		} else if s := response.GetError(); s != nil {
+			if s.Code == int32(codes.AlreadyExists) && writer.StreamType != WriteStreamTypeDefault {
+				// the rows were written by an earlier attempt
+				writer.offset += int64(len(serializedRows))
+				return nil
+			}
+			if len(response.RowErrors) > 0 {
+				return storageRowErrors(s.Message, response.RowErrors)
+			}
+			err = status.ErrorProto(s)
+		} else {
+			if writer.StreamType != WriteStreamTypeDefault {
+				writer.offset += int64(len(serializedRows))
+			}
+			return writer.setUpdatedSchema(response.UpdatedSchema)
+		}
+
+		s, _ := status.FromError(err)
+		statusCode := storageStatusCode(s.Code())
+		retryRequest := RetryRequest{
+			Operation:  OperationStorageAppendRows,
+			Idempotent: writer.StreamType != WriteStreamTypeDefault || writer.allowDuplicates,
+			Attempt:    attempt,
+			StatusCode: statusCode,
+			// the Storage API does not return error reasons, the status code decides
+			NetworkError: statusCode == 0,
+			Message:      s.Message(),
+		}
+
+		wait, retry := retryPolicy.Retry(&retryRequest)
+		if !retry {
+			return storageError(err)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return storageError(err)
+		case <-timer.C:
+		}
+	}
+}
+
+// send sends a single append request and waits for its response, connecting first if needed
+func (writer *StorageWriter) send(ctx context.Context, serializedRows [][]byte) (*storagepb.AppendRowsResponse, error) {
+	if writer.appendClient == nil {
+		// the connection outlives ctx, since it is shared by subsequent appends
+		streamCtx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(context.Background(), "x-goog-request-params", fmt.Sprintf("write_stream=%s", writer.Stream)))
+		appendClient, err := writer.client.client.AppendRows(streamCtx)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		writer.appendClient = appendClient
+		writer.cancel = cancel
+		writer.schemaSent = false
+	}
+
+	protoData := storagepb.AppendRowsRequest_ProtoData{
+		Rows: &storagepb.ProtoRows{SerializedRows: serializedRows},
+	}
+	request := storagepb.AppendRowsRequest{
+		Rows: &storagepb.AppendRowsRequest_ProtoRows{ProtoRows: &protoData},
+	}
+	if !writer.schemaSent {
+		// only the first request of a connection identifies the stream and the schema
+		request.WriteStream = writer.Stream
+		protoData.WriterSchema = &storagepb.ProtoSchema{ProtoDescriptor: writer.schema.descriptor}
+	}
+	if writer.StreamType != WriteStreamTypeDefault {
+		request.Offset = wrapperspb.Int64(writer.offset)
+	}
+
+	// abort the request if ctx is done before the response arrives
+	done := make(chan struct{})
+	defer close(done)
+	go func(cancel context.CancelFunc) {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-done:
+		}
+	}(writer.cancel)
+
+	err := writer.appendClient.Send(&request)
+	if err == io.EOF {
+		// the stream was closed by the server, Recv returns the actual error
+		_, err = writer.appendClient.Recv()
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	writer.schemaSent = true
+
+	return writer.appendClient.Recv()
+}
+
+func (writer *StorageWriter) disconnect() {
+	if writer.appendClient == nil {
+		return
+	}
+
+	writer.appendClient.CloseSend()
+	writer.cancel()
+	writer.appendClient = nil
+	writer.cancel = nil
+}
+
+// setUpdatedSchema keeps the schema reported after a schema change of the table,
+// and switches to it if the writer was configured to do so
+func (writer *StorageWriter) setUpdatedSchema(tableSchema *storagepb.TableSchema) *errortools.Error {
+	if tableSchema == nil {
+		return nil
+	}
+
+	fields := storageTableFields(tableSchema.Fields)
+	writer.UpdatedSchema = &TableSchema{Fields: fields}
+
+	if !writer.updateSchema {
+		return nil
+	}
+
+	schema, e := NewProtoSchema(fields, storageWriteMessageName)
+	if e != nil {
+		return e
+	}
+	writer.schema = schema
+
+	// the writer schema can only be sent with the first request of a connection
+	writer.disconnect()
+
+	return nil
+}
+
+func (writer *StorageWriter) Finalize() (int64, *errortools.Error) {
+	return writer.FinalizeWithContext(context.Background())
+}
+
+// FinalizeWithContext closes the stream for appends and returns its row count,
+// pending streams must be finalized before they can be committed with BatchCommit
+func (writer *StorageWriter) FinalizeWithContext(ctx context.Context) (int64, *errortools.Error) {
+	if writer.StreamType == WriteStreamTypeDefault {
+		return 0, errortools.ErrorMessage("the default stream cannot be finalized")
+	}
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.disconnect()
+
+	response, err := writer.client.client.FinalizeWriteStream(metadata.AppendToOutgoingContext(ctx, "x-goog-request-params", fmt.Sprintf("name=%s", writer.Stream)), &storagepb.FinalizeWriteStreamRequest{
+		Name: writer.Stream,
+	})
+	if err != nil {
+		return 0, storageError(err)
+	}
+
+	return response.RowCount, nil
+}
+
+func (writer *StorageWriter) Flush(offset int64) (int64, *errortools.Error) {
+	return writer.FlushWithContext(context.Background(), offset)
+}
+
+// FlushWithContext makes the rows of a buffered stream up to and including offset visible,
+// it returns the offset up to which rows were flushed
+func (writer *StorageWriter) FlushWithContext(ctx context.Context, offset int64) (int64, *errortools.Error) {
+	if writer.StreamType != WriteStreamTypeBuffered {
+		return 0, errortools.ErrorMessagef("only buffered streams can be flushed, stream is of type %s", writer.StreamType)
+	}
+
+	response, err := writer.client.client.FlushRows(metadata.AppendToOutgoingContext(ctx, "x-goog-request-params", fmt.Sprintf("write_stream=%s", writer.Stream)), &storagepb.FlushRowsRequest{
+		WriteStream: writer.Stream,
+		Offset:      wrapperspb.Int64(offset),
+	})
+	if err != nil {
+		return 0, storageError(err)
+	}
+
+	return response.Offset, nil
+}
+
+// Close closes the connection of the writer, it does not finalize the stream
+func (writer *StorageWriter) Close() {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	writer.disconnect()
+}
+
+func (client *StorageWriteClient) BatchCommit(table TableReference, streams []string) (*time.Time, *errortools.Error) {
+	return client.BatchCommitWithContext(context.Background(), table, streams)
+}
+
+// BatchCommitWithContext atomically makes the rows of finalized pending streams visible,
+// it returns the commit time
+func (client *StorageWriteClient) BatchCommitWithContext(ctx context.Context, table TableReference, streams []string) (*time.Time, *errortools.Error) {
+	tablePath := storageTablePath(table)
+
+	response, err := client.client.BatchCommitWriteStreams(metadata.AppendToOutgoingContext(ctx, "x-goog-request-params", fmt.Sprintf("parent=%s", tablePath)), &storagepb.BatchCommitWriteStreamsRequest{
+		Parent:       tablePath,
+		WriteStreams: streams,
+	})
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	if len(response.StreamErrors) > 0 {
+		messages := []string{}
+		for _, streamError := range response.StreamErrors {
+			messages = append(messages, fmt.Sprintf("%s: %s (%s)", streamError.Entity, streamError.ErrorMessage, streamError.Code.String()))
+		}
+		return nil, errortools.ErrorMessagef("commit failed: %s", strings.Join(messages, "; "))
+	}
+
+	if response.CommitTime == nil {
+		// the commit time is only left out when the commit failed
+		return nil, errortools.ErrorMessage("commit failed: no commit time returned")
+	}
+	commitTime := response.CommitTime.AsTime()
+
+	return &commitTime, nil
+}
+
+func storageRowErrors(message string, rowErrors []*storagepb.RowError) *errortools.Error {
+	messages := []string{}
+	for _, rowError := range rowErrors {
+		messages = append(messages, fmt.Sprintf("row %v: %s", rowError.Index, rowError.Message))
+	}
+
+	return errortools.ErrorMessagef("%s: %s", message, strings.Join(messages, "; "))
+}
+
+// storageStatusCode maps a gRPC code to the http status code a RetryPolicy decides upon
+func storageStatusCode(code codes.Code) int {
+	switch code {
+	case codes.OK, codes.Unknown:
+		// no status received, e.g. the connection was reset
+		return 0
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unavailable, codes.Aborted:
+		// Aborted is transient for the Storage Write API, e.g. after a concurrent schema update
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	}
+
+	return http.StatusInternalServerError
+}
+
+// storageTableFields converts the schema returned by the Storage API to the schema of the REST API
+func storageTableFields(storageFields []*storagepb.TableFieldSchema) []TableFieldSchema {
+	fields := []TableFieldSchema{}
+
+	for _, storageField := range storageFields {
+		field := TableFieldSchema{
+			Name:        storageField.Name,
+			Type:        storageField.Type.String(),
+			Mode:        storageField.Mode.String(),
+			Description: storageField.Description,
+		}
+
+		switch storageField.Type {
+		case storagepb.TableFieldSchema_INT64:
+			field.Type = "INTEGER"
+		case storagepb.TableFieldSchema_DOUBLE:
+			field.Type = "FLOAT"
+		case storagepb.TableFieldSchema_BOOL:
+			field.Type = "BOOLEAN"
+		case storagepb.TableFieldSchema_STRUCT:
+			field.Type = "RECORD"
+			field.Fields = storageTableFields(storageField.Fields)
+		}
+		if storageField.Mode == storagepb.TableFieldSchema_MODE_UNSPECIFIED {
+			field.Mode = "NULLABLE"
+		}
+
+		if storageField.MaxLength != 0 {
+			maxLength := go_types.Int64String(storageField.MaxLength)
+			field.MaxLength = &maxLength
+		}
+		if storageField.Precision != 0 {
+			precision := go_types.Int64String(storageField.Precision)
+			field.Precision = &precision
+		}
+		if storageField.Scale != 0 {
+			scale := go_types.Int64String(storageField.Scale)
+			field.Scale = &scale
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields
+}
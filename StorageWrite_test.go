@@ -0,0 +1,317 @@
+package googlebigquery
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var testWriteSchema = &storagepb.TableSchema{Fields: []*storagepb.TableFieldSchema{
+	{Name: "name", Type: storagepb.TableFieldSchema_STRING},
+}}
+
+// fakeWriteServer keeps the rows appended per stream and applies the offset semantics of the Storage Write API
+type fakeWriteServer struct {
+	storagepb.UnimplementedBigQueryWriteServer
+	mutex sync.Mutex
+	rows  map[string][][]byte
+	// offsets holds the offset of each append request received
+	offsets []int64
+	// writerSchemaFields holds the number of fields of the writer schema of each connection
+	writerSchemaFields []int
+	// dropResponses is the number of appends after which the connection is reset instead of responding
+	dropResponses int
+	// updatedSchema is reported in the response to the next append
+	updatedSchema *storagepb.TableSchema
+	finalized     map[string]bool
+	noCommitTime  bool
+}
+
+func (server *fakeWriteServer) CreateWriteStream(ctx context.Context, request *storagepb.CreateWriteStreamRequest) (*storagepb.WriteStream, error) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	name := fmt.Sprintf("%s/streams/%v", request.Parent, len(server.rows))
+	server.rows[name] = [][]byte{}
+
+	return &storagepb.WriteStream{
+		Name:        name,
+		Type:        request.WriteStream.Type,
+		TableSchema: testWriteSchema,
+	}, nil
+}
+
+func (server *fakeWriteServer) AppendRows(stream storagepb.BigQueryWrite_AppendRowsServer) error {
+	writeStream := ""
+
+	for {
+		request, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		server.mutex.Lock()
+		if writeStream == "" {
+			writeStream = request.WriteStream
+			server.writerSchemaFields = append(server.writerSchemaFields, len(request.GetProtoRows().WriterSchema.ProtoDescriptor.Field))
+		}
+
+		rows := request.GetProtoRows().Rows.SerializedRows
+		offset := request.Offset.GetValue()
+		server.offsets = append(server.offsets, offset)
+
+		response := storagepb.AppendRowsResponse{}
+		switch count := int64(len(server.rows[writeStream])); {
+		case server.finalized[writeStream]:
+			response.Response = &storagepb.AppendRowsResponse_Error{Error: status.New(codes.FailedPrecondition, "stream is finalized").Proto()}
+		case offset < count:
+			response.Response = &storagepb.AppendRowsResponse_Error{Error: status.New(codes.AlreadyExists, "rows already written").Proto()}
+		case offset > count:
+			response.Response = &storagepb.AppendRowsResponse_Error{Error: status.New(codes.OutOfRange, "offset beyond the end of the stream").Proto()}
+		default:
+			server.rows[writeStream] = append(server.rows[writeStream], rows...)
+			if server.dropResponses > 0 {
+				server.dropResponses--
+				server.mutex.Unlock()
+				return status.Error(codes.Unavailable, "connection reset")
+			}
+			response.Response = &storagepb.AppendRowsResponse_AppendResult_{AppendResult: &storagepb.AppendRowsResponse_AppendResult{}}
+			response.UpdatedSchema = server.updatedSchema
+			server.updatedSchema = nil
+		}
+		server.mutex.Unlock()
+
+		err = stream.Send(&response)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (server *fakeWriteServer) FinalizeWriteStream(ctx context.Context, request *storagepb.FinalizeWriteStreamRequest) (*storagepb.FinalizeWriteStreamResponse, error) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.finalized[request.Name] = true
+
+	return &storagepb.FinalizeWriteStreamResponse{RowCount: int64(len(server.rows[request.Name]))}, nil
+}
+
+func (server *fakeWriteServer) BatchCommitWriteStreams(ctx context.Context, request *storagepb.BatchCommitWriteStreamsRequest) (*storagepb.BatchCommitWriteStreamsResponse, error) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	response := storagepb.BatchCommitWriteStreamsResponse{}
+	for _, stream := range request.WriteStreams {
+		if !server.finalized[stream] {
+			response.StreamErrors = append(response.StreamErrors, &storagepb.StorageError{
+				Code:         storagepb.StorageError_STREAM_NOT_FOUND,
+				Entity:       stream,
+				ErrorMessage: "stream is not finalized",
+			})
+		}
+	}
+	if len(response.StreamErrors) == 0 && !server.noCommitTime {
+		response.CommitTime = timestamppb.Now()
+	}
+
+	return &response, nil
+}
+
+func newTestStorageWriteClient(t *testing.T) (*StorageWriteClient, *fakeWriteServer) {
+	fakeServer := fakeWriteServer{
+		rows:      map[string][][]byte{},
+		finalized: map[string]bool{},
+	}
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	storagepb.RegisterBigQueryWriteServer(server, &fakeServer)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	retryPolicy := NewExponentialBackoffRetryPolicy()
+	retryPolicy.InitialBackoff = time.Millisecond
+	service := Service{}
+	service.SetRetryPolicy(retryPolicy)
+
+	client, e := service.NewStorageWriteClient(&StorageWriteClientConfig{Conn: conn})
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+	return client, &fakeServer
+}
+
+func newTestStorageWriter(t *testing.T, client *StorageWriteClient, streamType string, updateSchema bool) *StorageWriter {
+	writer, e := client.NewStorageWriter(&StorageWriterConfig{
+		Table:        TableReference{ProjectID: "project", DatasetID: "dataset", TableID: "table"},
+		StreamType:   &streamType,
+		UpdateSchema: updateSchema,
+	})
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+	t.Cleanup(writer.Close)
+	return writer
+}
+
+func testWriteRows(names ...string) []map[string]interface{} {
+	rows := []map[string]interface{}{}
+	for _, name := range names {
+		rows = append(rows, map[string]interface{}{"name": name})
+	}
+	return rows
+}
+
+func TestStorageWriterOffsets(t *testing.T) {
+	client, server := newTestStorageWriteClient(t)
+	writer := newTestStorageWriter(t, client, WriteStreamTypeCommitted, false)
+
+	for _, rows := range [][]map[string]interface{}{testWriteRows("a", "b"), testWriteRows("c")} {
+		e := writer.Append(rows)
+		if e != nil {
+			t.Fatal(e.Message())
+		}
+	}
+
+	if writer.Offset() != 3 {
+		t.Errorf("Offset() = %v, want 3", writer.Offset())
+	}
+	if fmt.Sprint(server.offsets) != "[0 2]" {
+		t.Errorf("appended at offsets %v, want [0 2]", server.offsets)
+	}
+	if len(server.rows[writer.Stream]) != 3 {
+		t.Errorf("stream holds %v rows, want 3", len(server.rows[writer.Stream]))
+	}
+
+	// an append at an offset beyond the end of the stream is not retried
+	writer.SetOffset(10)
+	if e := writer.Append(testWriteRows("d")); e == nil {
+		t.Error("expected an error for an offset beyond the end of the stream")
+	}
+}
+
+func TestStorageWriterRetryAlreadyExists(t *testing.T) {
+	client, server := newTestStorageWriteClient(t)
+	writer := newTestStorageWriter(t, client, WriteStreamTypeCommitted, false)
+
+	// the rows are written, but the response is lost
+	server.dropResponses = 1
+
+	e := writer.Append(testWriteRows("a", "b"))
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+	e = writer.Append(testWriteRows("c"))
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+
+	if fmt.Sprint(server.offsets) != "[0 0 2]" {
+		t.Errorf("appended at offsets %v, want [0 0 2]", server.offsets)
+	}
+	if len(server.rows[writer.Stream]) != 3 {
+		t.Errorf("stream holds %v rows, want 3 without duplicates", len(server.rows[writer.Stream]))
+	}
+	if writer.Offset() != 3 {
+		t.Errorf("Offset() = %v, want 3", writer.Offset())
+	}
+}
+
+func TestStorageWriterUpdateSchema(t *testing.T) {
+	client, server := newTestStorageWriteClient(t)
+	writer := newTestStorageWriter(t, client, WriteStreamTypeCommitted, true)
+
+	server.updatedSchema = &storagepb.TableSchema{Fields: []*storagepb.TableFieldSchema{
+		{Name: "name", Type: storagepb.TableFieldSchema_STRING},
+		{Name: "n", Type: storagepb.TableFieldSchema_INT64},
+	}}
+
+	e := writer.Append(testWriteRows("a"))
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+	if writer.UpdatedSchema == nil || len(writer.UpdatedSchema.Fields) != 2 {
+		t.Fatalf("UpdatedSchema = %+v, want the reported schema", writer.UpdatedSchema)
+	}
+
+	e = writer.Append([]map[string]interface{}{{"name": "b", "n": 1}})
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+
+	// the writer reconnects to send the new writer schema
+	if fmt.Sprint(server.writerSchemaFields) != "[1 2]" {
+		t.Errorf("writer schemas of the connections have %v fields, want [1 2]", server.writerSchemaFields)
+	}
+}
+
+func TestStorageWriterBatchCommit(t *testing.T) {
+	client, server := newTestStorageWriteClient(t)
+	table := TableReference{ProjectID: "project", DatasetID: "dataset", TableID: "table"}
+
+	streams := []string{}
+	for _, names := range [][]string{{"a", "b"}, {"c"}} {
+		writer := newTestStorageWriter(t, client, WriteStreamTypePending, false)
+		e := writer.Append(testWriteRows(names...))
+		if e != nil {
+			t.Fatal(e.Message())
+		}
+		streams = append(streams, writer.Stream)
+
+		if len(streams) == 2 {
+			// committing a stream that is not finalized fails
+			if _, e := client.BatchCommit(table, streams); e == nil {
+				t.Error("expected an error for a stream that is not finalized")
+			}
+		}
+
+		rowCount, e := writer.Finalize()
+		if e != nil {
+			t.Fatal(e.Message())
+		}
+		if rowCount != int64(len(names)) {
+			t.Errorf("Finalize() = %v, want %v", rowCount, len(names))
+		}
+		if e := writer.Append(testWriteRows("d")); e == nil {
+			t.Error("expected an error for an append to a finalized stream")
+		}
+	}
+
+	commitTime, e := client.BatchCommit(table, streams)
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+	if commitTime == nil {
+		t.Error("BatchCommit returned no commit time")
+	}
+
+	server.noCommitTime = true
+	if _, e := client.BatchCommit(table, streams); e == nil {
+		t.Error("expected an error for a commit without commit time")
+	}
+}
@@ -2,6 +2,7 @@ package googlebigquery
 
 import (
 	"cloud.google.com/go/bigquery"
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -230,53 +231,74 @@ type GetTablesConfig struct {
 	ProjectId  string
 	DatasetId  string
 	MaxResults *int
+	MaxItems   *int
 	PageToken  *string
 }
 
 func (service *Service) GetTables(config *GetTablesConfig) (*[]Table, *errortools.Error) {
-	if config == nil {
-		return nil, errortools.ErrorMessage("GetTablesConfig must not be a nil pointer")
-	}
+	return service.GetTablesWithContext(context.Background(), config)
+}
 
-	values := url.Values{}
+func (service *Service) GetTablesWithContext(ctx context.Context, config *GetTablesConfig) (*[]Table, *errortools.Error) {
+	return service.TablesPagerWithContext(ctx, config).All()
+}
 
-	if config.MaxResults != nil {
-		values.Set("maxResults", fmt.Sprintf("%v", *config.MaxResults))
-	}
-	pageToken := config.PageToken
+// IterateTables returns an iterator that fetches the tables page by page
+func (service *Service) IterateTables(config *GetTablesConfig) *Iterator[Table] {
+	return service.IterateTablesWithContext(context.Background(), config)
+}
 
-	tables := []Table{}
+func (service *Service) IterateTablesWithContext(ctx context.Context, config *GetTablesConfig) *Iterator[Table] {
+	return service.TablesPagerWithContext(ctx, config).Iterator()
+}
 
-	for {
-		if pageToken != nil {
-			values.Set("pageToken", *pageToken)
-		}
+func (service *Service) TablesPager(config *GetTablesConfig) *Pager[Table] {
+	return service.TablesPagerWithContext(context.Background(), config)
+}
+
+func (service *Service) TablesPagerWithContext(ctx context.Context, config *GetTablesConfig) *Pager[Table] {
+	if config == nil {
+		return newErrorPager[Table](errortools.ErrorMessage("GetTablesConfig must not be a nil pointer"))
+	}
 
-		tablesReponse := TablesResponse{}
+	pagerConfig := PagerConfig{
+		PageToken: config.PageToken,
+		PageSize:  config.MaxResults,
+		MaxItems:  config.MaxItems,
+	}
 
-		requestConfig := go_http.RequestConfig{
-			Method:        http.MethodGet,
-			Url:           service.url(fmt.Sprintf("projects/%s/datasets/%s/tables?%s", config.ProjectId, config.DatasetId, values.Encode())),
-			ResponseModel: &tablesReponse,
-		}
-		_, _, e := service.googleService.HttpRequest(&requestConfig)
+	return NewPager(ctx, &pagerConfig, func(ctx context.Context, pageToken *string, pageSize *int) ([]Table, *string, *errortools.Error) {
+		tablesResponse, e := service.getTablesPage(ctx, config, pageToken, pageSize)
 		if e != nil {
-			return nil, e
+			return nil, nil, e
 		}
+		return tablesResponse.Tables, tablesResponse.NextPageToken, nil
+	})
+}
 
-		tables = append(tables, tablesReponse.Tables...)
+func (service *Service) getTablesPage(ctx context.Context, config *GetTablesConfig, pageToken *string, pageSize *int) (*TablesResponse, *errortools.Error) {
+	values := url.Values{}
 
-		if config.PageToken != nil {
-			break
-		}
-		if tablesReponse.NextPageToken == nil {
-			break
-		}
+	if pageSize != nil {
+		values.Set("maxResults", fmt.Sprintf("%v", *pageSize))
+	}
+	if pageToken != nil {
+		values.Set("pageToken", *pageToken)
+	}
 
-		pageToken = tablesReponse.NextPageToken
+	tablesResponse := TablesResponse{}
+
+	requestConfig := go_http.RequestConfig{
+		Method:        http.MethodGet,
+		Url:           service.url(fmt.Sprintf("projects/%s/datasets/%s/tables?%s", config.ProjectId, config.DatasetId, values.Encode())),
+		ResponseModel: &tablesResponse,
+	}
+	_, _, e := service.httpRequest(ctx, OperationTablesList, &requestConfig)
+	if e != nil {
+		return nil, e
 	}
 
-	return &tables, nil
+	return &tablesResponse, nil
 }
 
 type GetTableConfig struct {
@@ -286,6 +308,10 @@ type GetTableConfig struct {
 }
 
 func (service *Service) GetTable(config *GetTableConfig) (*Table, *errortools.Error) {
+	return service.GetTableWithContext(context.Background(), config)
+}
+
+func (service *Service) GetTableWithContext(ctx context.Context, config *GetTableConfig) (*Table, *errortools.Error) {
 	if config == nil {
 		return nil, errortools.ErrorMessage("GetTablesConfig must not be a nil pointer")
 	}
@@ -297,7 +323,7 @@ func (service *Service) GetTable(config *GetTableConfig) (*Table, *errortools.Er
 		Url:           service.url(fmt.Sprintf("projects/%s/datasets/%s/tables/%s", config.ProjectId, config.DatasetId, config.TableId)),
 		ResponseModel: &table,
 	}
-	_, _, e := service.googleService.HttpRequest(&requestConfig)
+	_, _, e := service.httpRequest(ctx, OperationTablesGet, &requestConfig)
 	if e != nil {
 		return nil, e
 	}
@@ -306,6 +332,10 @@ func (service *Service) GetTable(config *GetTableConfig) (*Table, *errortools.Er
 }
 
 func (service *Service) DeleteTable(config *GetTableConfig) *errortools.Error {
+	return service.DeleteTableWithContext(context.Background(), config)
+}
+
+func (service *Service) DeleteTableWithContext(ctx context.Context, config *GetTableConfig) *errortools.Error {
 	if config == nil {
 		return errortools.ErrorMessage("GetTablesConfig must not be a nil pointer")
 	}
@@ -314,7 +344,7 @@ func (service *Service) DeleteTable(config *GetTableConfig) *errortools.Error {
 		Method: http.MethodDelete,
 		Url:    service.url(fmt.Sprintf("projects/%s/datasets/%s/tables/%s", config.ProjectId, config.DatasetId, config.TableId)),
 	}
-	_, _, e := service.googleService.HttpRequest(&requestConfig)
+	_, _, e := service.httpRequest(ctx, OperationTablesDelete, &requestConfig)
 	if e != nil {
 		return e
 	}
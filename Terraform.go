@@ -0,0 +1,536 @@
+package googlebigquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+)
+
+var hclIdentifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// GenerateTerraform renders the datasets and tables as google_bigquery_dataset and google_bigquery_table resources
+// of the Terraform Google provider, each preceded by an import block, so that terraform plan imports the existing
+// datasets and tables into the state instead of creating them. Table schemas are embedded as JSON in the format of
+// the bq command-line tool and tables refer to the resource of their dataset if it is exported as well.
+// Resources are named after the dataset and the table and ordered by their full name.
+//
+// The list endpoints return part of the metadata only, e.g. GetTables returns no schemas or view queries, so
+// the datasets and tables should be fetched with GetDataset and GetTable for a complete definition.
+func GenerateTerraform(datasets []Dataset, tables []Table) ([]byte, *errortools.Error) {
+	sortedDatasets := make([]Dataset, len(datasets))
+	copy(sortedDatasets, datasets)
+	sort.SliceStable(sortedDatasets, func(i, j int) bool {
+		return terraformDatasetKey(sortedDatasets[i].DatasetReference.ProjectID, sortedDatasets[i].DatasetReference.DatasetID) < terraformDatasetKey(sortedDatasets[j].DatasetReference.ProjectID, sortedDatasets[j].DatasetReference.DatasetID)
+	})
+
+	sortedTables := make([]Table, len(tables))
+	copy(sortedTables, tables)
+	sort.SliceStable(sortedTables, func(i, j int) bool {
+		return goStructTableName(sortedTables[i].TableReference) < goStructTableName(sortedTables[j].TableReference)
+	})
+
+	names := map[string]bool{}
+	datasetResources := map[string]string{}
+	blocks := []*hclBlock{}
+
+	for _, dataset := range sortedDatasets {
+		reference := dataset.DatasetReference
+		name := hclName(reference.DatasetID, names)
+		datasetResources[terraformDatasetKey(reference.ProjectID, reference.DatasetID)] = name
+
+		resource, e := terraformDataset(dataset, name)
+		if e != nil {
+			return nil, errortools.ErrorMessagef("dataset %s: %s", terraformDatasetKey(reference.ProjectID, reference.DatasetID), e.Message())
+		}
+
+		blocks = append(blocks,
+			terraformImport("google_bigquery_dataset."+name, fmt.Sprintf("projects/%s/datasets/%s", reference.ProjectID, reference.DatasetID)),
+			resource,
+		)
+	}
+
+	for _, table := range sortedTables {
+		reference := table.TableReference
+		name := hclName(reference.DatasetID+"_"+reference.TableID, names)
+
+		datasetId := hclString(reference.DatasetID)
+		if datasetResource, ok := datasetResources[terraformDatasetKey(reference.ProjectID, reference.DatasetID)]; ok {
+			datasetId = fmt.Sprintf("google_bigquery_dataset.%s.dataset_id", datasetResource)
+		}
+
+		resource, e := terraformTable(table, name, datasetId)
+		if e != nil {
+			return nil, errortools.ErrorMessagef("table %s: %s", goStructTableName(reference), e.Message())
+		}
+
+		blocks = append(blocks,
+			terraformImport("google_bigquery_table."+name, fmt.Sprintf("projects/%s/datasets/%s/tables/%s", reference.ProjectID, reference.DatasetID, reference.TableID)),
+			resource,
+		)
+	}
+
+	var hcl strings.Builder
+	for i, block := range blocks {
+		if i > 0 {
+			hcl.WriteString("\n")
+		}
+		block.write(&hcl, "")
+	}
+
+	return []byte(hcl.String()), nil
+}
+
+func terraformDatasetKey(projectId string, datasetId string) string {
+	return fmt.Sprintf("%s.%s", projectId, datasetId)
+}
+
+func terraformImport(to string, id string) *hclBlock {
+	block := hclBlock{header: "import"}
+	block.attribute("to", to)
+	block.attribute("id", hclString(id))
+	return &block
+}
+
+func terraformDataset(dataset Dataset, name string) (*hclBlock, *errortools.Error) {
+	resource := hclBlock{header: fmt.Sprintf("resource \"google_bigquery_dataset\" %s", hclString(name))}
+
+	resource.attribute("project", hclString(dataset.DatasetReference.ProjectID))
+	resource.attribute("dataset_id", hclString(dataset.DatasetReference.DatasetID))
+	if dataset.Location != "" {
+		resource.attribute("location", hclString(dataset.Location))
+	}
+	if dataset.FriendlyName != nil && *dataset.FriendlyName != "" {
+		resource.attribute("friendly_name", hclString(*dataset.FriendlyName))
+	}
+	if dataset.Description != nil && *dataset.Description != "" {
+		resource.attribute("description", hclString(*dataset.Description))
+	}
+	if dataset.DefaultTableExpirationMS != nil {
+		resource.attribute("default_table_expiration_ms", strconv.FormatInt(int64(*dataset.DefaultTableExpirationMS), 10))
+	}
+	if dataset.DefaultPartitionExpirationMS != nil {
+		resource.attribute("default_partition_expiration_ms", strconv.FormatInt(int64(*dataset.DefaultPartitionExpirationMS), 10))
+	}
+
+	if dataset.Labels != nil {
+		labels := map[string]string{}
+		err := json.Unmarshal(*dataset.Labels, &labels)
+		if err != nil {
+			return nil, errortools.ErrorMessagef("invalid labels: %s", err.Error())
+		}
+		if len(labels) > 0 {
+			resource.attribute("labels", hclMap(labels))
+		}
+	}
+
+	if dataset.Access != nil {
+		for _, access := range *dataset.Access {
+			block := resource.block("access")
+			if access.Role != "" {
+				block.attribute("role", hclString(access.Role))
+			}
+			for _, member := range []struct {
+				name  string
+				value *string
+			}{
+				{"user_by_email", access.UserByEmail},
+				{"group_by_email", access.GroupByEmail},
+				{"domain", access.Domain},
+				{"special_group", access.SpecialGroup},
+				{"iam_member", access.IAMMember},
+			} {
+				if member.value != nil && *member.value != "" {
+					block.attribute(member.name, hclString(*member.value))
+				}
+			}
+			if access.View != nil {
+				view := block.block("view")
+				view.attribute("project_id", hclString(access.View.ProjectID))
+				view.attribute("dataset_id", hclString(access.View.DatasetID))
+				view.attribute("table_id", hclString(access.View.TableID))
+			}
+			if access.Routine != nil {
+				routine := block.block("routine")
+				routine.attribute("project_id", hclString(access.Routine.ProjectID))
+				routine.attribute("dataset_id", hclString(access.Routine.DatasetID))
+				routine.attribute("routine_id", hclString(access.Routine.RoutineID))
+			}
+		}
+	}
+
+	return &resource, nil
+}
+
+func terraformTable(table Table, name string, datasetId string) (*hclBlock, *errortools.Error) {
+	resource := hclBlock{header: fmt.Sprintf("resource \"google_bigquery_table\" %s", hclString(name))}
+
+	resource.attribute("project", hclString(table.TableReference.ProjectID))
+	resource.attribute("dataset_id", datasetId)
+	resource.attribute("table_id", hclString(table.TableReference.TableID))
+	if table.FriendlyName != nil && *table.FriendlyName != "" {
+		resource.attribute("friendly_name", hclString(*table.FriendlyName))
+	}
+	if table.Description != nil && *table.Description != "" {
+		resource.attribute("description", hclString(*table.Description))
+	}
+	if table.ExpirationTime != nil {
+		resource.attribute("expiration_time", strconv.FormatInt(int64(*table.ExpirationTime), 10))
+	}
+	if table.RequirePartitionFilter != nil {
+		resource.attribute("require_partition_filter", strconv.FormatBool(*table.RequirePartitionFilter))
+	}
+	if table.Clustering != nil && len(table.Clustering.Fields) > 0 {
+		resource.attribute("clustering", hclStringList(table.Clustering.Fields))
+	}
+	if table.Labels != nil && len(*table.Labels) > 0 {
+		resource.attribute("labels", hclMap(*table.Labels))
+	}
+
+	if table.TimePartitioning != nil {
+		block := resource.block("time_partitioning")
+		block.attribute("type", hclString(table.TimePartitioning.Type))
+		if table.TimePartitioning.Field != nil && *table.TimePartitioning.Field != "" {
+			block.attribute("field", hclString(*table.TimePartitioning.Field))
+		}
+		if table.TimePartitioning.ExpirationMS != nil {
+			block.attribute("expiration_ms", strconv.FormatInt(int64(*table.TimePartitioning.ExpirationMS), 10))
+		}
+	}
+
+	if table.RangePartitioning != nil {
+		block := resource.block("range_partitioning")
+		block.attribute("field", hclString(table.RangePartitioning.Field))
+		r := block.block("range")
+		r.attribute("start", table.RangePartitioning.Range.Start)
+		r.attribute("end", table.RangePartitioning.Range.End)
+		r.attribute("interval", table.RangePartitioning.Range.Interval)
+	}
+
+	if table.EncryptionConfiguration != nil && table.EncryptionConfiguration.KMSKeyName != "" {
+		block := resource.block("encryption_configuration")
+		block.attribute("kms_key_name", hclString(table.EncryptionConfiguration.KMSKeyName))
+	}
+
+	if table.View != nil {
+		block := resource.block("view")
+		block.attribute("query", hclText(table.View.Query))
+		useLegacySQL := table.View.UseLegacySQL != nil && *table.View.UseLegacySQL
+		block.attribute("use_legacy_sql", strconv.FormatBool(useLegacySQL))
+	}
+
+	if table.MaterializedView != nil {
+		block := resource.block("materialized_view")
+		block.attribute("query", hclText(table.MaterializedView.Query))
+		if table.MaterializedView.EnableRefresh != nil {
+			block.attribute("enable_refresh", strconv.FormatBool(*table.MaterializedView.EnableRefresh))
+		}
+		if table.MaterializedView.RefreshIntervalMS != nil {
+			block.attribute("refresh_interval_ms", strconv.FormatInt(int64(*table.MaterializedView.RefreshIntervalMS), 10))
+		}
+	}
+
+	if table.ExternalDataConfiguration != nil {
+		terraformExternalDataConfiguration(resource.block("external_data_configuration"), table.ExternalDataConfiguration)
+	}
+
+	// the schema of a view follows from its query
+	if table.Schema != nil && table.View == nil && table.MaterializedView == nil {
+		b, e := MarshalSchemaJSON(table.Schema.Fields)
+		if e != nil {
+			return nil, e
+		}
+		resource.attribute("schema", hclHeredoc(string(b)))
+	}
+
+	return &resource, nil
+}
+
+func terraformExternalDataConfiguration(block *hclBlock, config *ExternalDataConfiguration) {
+	autodetect := config.Autodetect != nil && *config.Autodetect
+	block.attribute("autodetect", strconv.FormatBool(autodetect))
+	if config.SourceFormat != nil {
+		block.attribute("source_format", hclString(*config.SourceFormat))
+	}
+	block.attribute("source_uris", hclStringList(config.SourceURIs))
+	if config.Compression != nil {
+		block.attribute("compression", hclString(*config.Compression))
+	}
+	if config.ConnectionId != nil && *config.ConnectionId != "" {
+		block.attribute("connection_id", hclString(*config.ConnectionId))
+	}
+	if config.IgnoreUnknownValues != nil {
+		block.attribute("ignore_unknown_values", strconv.FormatBool(*config.IgnoreUnknownValues))
+	}
+	if config.MaxBadRecords != nil {
+		block.attribute("max_bad_records", strconv.FormatInt(*config.MaxBadRecords, 10))
+	}
+
+	if config.CSVOptions != nil {
+		csvOptions := block.block("csv_options")
+		// the provider requires the quote
+		quote := "\""
+		if config.CSVOptions.Quote != nil {
+			quote = *config.CSVOptions.Quote
+		}
+		csvOptions.attribute("quote", hclString(quote))
+		if config.CSVOptions.FieldDelimiter != nil {
+			csvOptions.attribute("field_delimiter", hclString(*config.CSVOptions.FieldDelimiter))
+		}
+		if config.CSVOptions.SkipLeadingRows != nil {
+			csvOptions.attribute("skip_leading_rows", strconv.FormatInt(int64(*config.CSVOptions.SkipLeadingRows), 10))
+		}
+		if config.CSVOptions.AllowQuotedNewlines != nil {
+			csvOptions.attribute("allow_quoted_newlines", strconv.FormatBool(*config.CSVOptions.AllowQuotedNewlines))
+		}
+		if config.CSVOptions.AllowJaggedRows != nil {
+			csvOptions.attribute("allow_jagged_rows", strconv.FormatBool(*config.CSVOptions.AllowJaggedRows))
+		}
+		if config.CSVOptions.Encoding != nil {
+			csvOptions.attribute("encoding", hclString(*config.CSVOptions.Encoding))
+		}
+	}
+
+	if config.GoogleSheetsOptions != nil {
+		sheetsOptions := block.block("google_sheets_options")
+		if config.GoogleSheetsOptions.Range != nil {
+			sheetsOptions.attribute("range", hclString(*config.GoogleSheetsOptions.Range))
+		}
+		if config.GoogleSheetsOptions.SkipLeadingRows != nil {
+			sheetsOptions.attribute("skip_leading_rows", strconv.FormatInt(int64(*config.GoogleSheetsOptions.SkipLeadingRows), 10))
+		}
+	}
+
+	if config.HivePartitioningOptions != nil {
+		hiveOptions := block.block("hive_partitioning_options")
+		if config.HivePartitioningOptions.Mode != nil {
+			hiveOptions.attribute("mode", hclString(*config.HivePartitioningOptions.Mode))
+		}
+		if config.HivePartitioningOptions.SourceURIPrefix != nil {
+			hiveOptions.attribute("source_uri_prefix", hclString(*config.HivePartitioningOptions.SourceURIPrefix))
+		}
+		if config.HivePartitioningOptions.RequirePartitionFilter != nil {
+			hiveOptions.attribute("require_partition_filter", strconv.FormatBool(*config.HivePartitioningOptions.RequirePartitionFilter))
+		}
+	}
+
+	if config.ParquetOptions != nil {
+		parquetOptions := block.block("parquet_options")
+		if config.ParquetOptions.EnumAsString != nil {
+			parquetOptions.attribute("enum_as_string", strconv.FormatBool(*config.ParquetOptions.EnumAsString))
+		}
+		if config.ParquetOptions.EnableListInference != nil {
+			parquetOptions.attribute("enable_list_inference", strconv.FormatBool(*config.ParquetOptions.EnableListInference))
+		}
+	}
+}
+
+// hclBlock is an HCL block with attributes and nested blocks, in the layout of terraform fmt
+type hclBlock struct {
+	header string
+	items  []hclItem
+}
+
+type hclItem struct {
+	name  string
+	value string // the expression of an attribute
+	block *hclBlock
+}
+
+func (block *hclBlock) attribute(name string, value string) {
+	block.items = append(block.items, hclItem{name: name, value: value})
+}
+
+func (block *hclBlock) block(header string) *hclBlock {
+	nested := hclBlock{header: header}
+	block.items = append(block.items, hclItem{block: &nested})
+	return &nested
+}
+
+// write writes the block, aligning the equals signs of consecutive single-line attributes and
+// separating multi-line attributes and nested blocks by blank lines
+func (block *hclBlock) write(hcl *strings.Builder, indent string) {
+	hcl.WriteString(fmt.Sprintf("%s%s {\n", indent, block.header))
+
+	inner := indent + "  "
+	for i := 0; i < len(block.items); {
+		if i > 0 {
+			hcl.WriteString("\n")
+		}
+
+		item := block.items[i]
+		if item.block != nil {
+			item.block.write(hcl, inner)
+			i++
+			continue
+		}
+		if strings.Contains(item.value, "\n") {
+			lines := strings.Split(item.value, "\n")
+			hcl.WriteString(fmt.Sprintf("%s%s = %s\n", inner, item.name, lines[0]))
+			for _, line := range lines[1:] {
+				if line != "" {
+					line = inner + line
+				}
+				hcl.WriteString(line + "\n")
+			}
+			i++
+			continue
+		}
+
+		j := i
+		width := 0
+		for ; j < len(block.items) && block.items[j].block == nil && !strings.Contains(block.items[j].value, "\n"); j++ {
+			if len(block.items[j].name) > width {
+				width = len(block.items[j].name)
+			}
+		}
+		for _, item := range block.items[i:j] {
+			hcl.WriteString(fmt.Sprintf("%s%-*s = %s\n", inner, width, item.name, item.value))
+		}
+		i = j
+	}
+
+	hcl.WriteString(fmt.Sprintf("%s}\n", indent))
+}
+
+// hclName derives a unique resource name from a name
+func hclName(name string, names map[string]bool) string {
+	var identifier strings.Builder
+	for _, r := range name {
+		if r == '_' || r == '-' || (r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))) {
+			identifier.WriteRune(r)
+		} else {
+			identifier.WriteRune('_')
+		}
+	}
+
+	base := identifier.String()
+	if !hclIdentifierRegexp.MatchString(base) {
+		base = "_" + base
+	}
+
+	unique := base
+	for i := 2; names[unique]; i++ {
+		unique = fmt.Sprintf("%s_%v", base, i)
+	}
+	names[unique] = true
+
+	return unique
+}
+
+// hclString returns s as an HCL string literal, escaping template sequences
+func hclString(s string) string {
+	var literal strings.Builder
+	literal.WriteString("\"")
+	for i, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			literal.WriteString("\\" + string(r))
+		case r == '\n':
+			literal.WriteString("\\n")
+		case r == '\r':
+			literal.WriteString("\\r")
+		case r == '\t':
+			literal.WriteString("\\t")
+		case (r == '$' || r == '%') && strings.HasPrefix(s[i+1:], "{"):
+			literal.WriteString(string(r) + string(r))
+		case !unicode.IsPrint(r) && r > 0xffff:
+			literal.WriteString(fmt.Sprintf("\\U%08X", r))
+		case !unicode.IsPrint(r):
+			literal.WriteString(fmt.Sprintf("\\u%04X", r))
+		default:
+			literal.WriteRune(r)
+		}
+	}
+	literal.WriteString("\"")
+	return literal.String()
+}
+
+func hclStringList(values []string) string {
+	literals := []string{}
+	for _, value := range values {
+		literals = append(literals, hclString(value))
+	}
+	return fmt.Sprintf("[%s]", strings.Join(literals, ", "))
+}
+
+// hclMap returns an object of the map in the order of its keys, with aligned equals signs
+func hclMap(m map[string]string) string {
+	keys := []string{}
+	width := 0
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	names := map[string]string{}
+	for _, key := range keys {
+		names[key] = key
+		if !hclIdentifierRegexp.MatchString(key) {
+			names[key] = hclString(key)
+		}
+		if len(names[key]) > width {
+			width = len(names[key])
+		}
+	}
+
+	var object strings.Builder
+	object.WriteString("{\n")
+	for _, key := range keys {
+		object.WriteString(fmt.Sprintf("  %-*s = %s\n", width, names[key], hclString(m[key])))
+	}
+	object.WriteString("}")
+
+	return object.String()
+}
+
+// hclHeredoc returns a heredoc of text, which must end with a newline
+func hclHeredoc(text string) string {
+	text = strings.NewReplacer("${", "$${", "%{", "%%{").Replace(text)
+
+	var heredoc strings.Builder
+	heredoc.WriteString("<<-EOT\n")
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		if line != "" {
+			line = "  " + line
+		}
+		heredoc.WriteString(line + "\n")
+	}
+	heredoc.WriteString("EOT")
+
+	return heredoc.String()
+}
+
+// hclText returns a string literal for a single line and otherwise a heredoc, that preserves the exact text
+func hclText(text string) string {
+	if !strings.Contains(strings.TrimSuffix(text, "\n"), "\n") {
+		return hclString(text)
+	}
+
+	// an indented heredoc strips the smallest indentation of its lines, so it only preserves text
+	// of which a line is not indented
+	unindented := false
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "EOT" {
+			return hclString(text)
+		}
+		if strings.TrimSpace(line) != "" && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			unindented = true
+		}
+	}
+	if !unindented {
+		return hclString(text)
+	}
+
+	if strings.HasSuffix(text, "\n") {
+		return hclHeredoc(text)
+	}
+	heredoc := hclHeredoc(text + "\n")
+	// the heredoc ends with a newline that the text does not
+	return fmt.Sprintf("chomp(%s\n)", heredoc)
+}
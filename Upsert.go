@@ -0,0 +1,382 @@
+package googlebigquery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+	go_http "github.com/leapforce-libraries/go_http"
+	go_types "github.com/leapforce-libraries/go_types"
+)
+
+// stagingTableExpiration is the time after which BigQuery drops a staging table that was not deleted,
+// e.g. because the process was killed during the upsert
+const stagingTableExpiration = 24 * time.Hour
+
+type UpsertConfig struct {
+	ProjectId string
+	Location  *string
+	Target    TableReference
+	// KeyColumns identify a row, NULL keys match NULL keys
+	KeyColumns []string
+	// Rows (see LoadRowsConfig) or SourceURIs provide the rows to upsert
+	Rows         interface{}
+	SourceURIs   []string
+	SourceFormat *string // format of SourceURIs, defaults to NEWLINE_DELIMITED_JSON
+	// Schema of the rows, defaults to the schema of the target table
+	Schema *TableSchema
+	// StagingDataset defaults to the dataset of the target table
+	StagingDataset *DatasetReference
+	// IgnoreExtraColumns ignores columns in Schema that the target table does not have,
+	// by default these fail the upsert
+	IgnoreExtraColumns bool
+	// UpdateColumns are updated for matching rows, defaults to all non-key columns
+	UpdateColumns *[]string
+	// DisableUpdate leaves matching rows untouched
+	DisableUpdate bool
+	// DisableInsert skips rows without a matching row in the target table
+	DisableInsert bool
+	// DeleteCondition deletes matching rows for which the condition holds instead of updating them,
+	// reference the target table as T and the staged rows as S, e.g. "S.deleted"
+	DeleteCondition *string
+	// DeleteNotMatched deletes rows from the target table that are not in the staged rows
+	DeleteNotMatched bool
+}
+
+// Upsert loads the rows into a staging table that expires after a day, merges them into the target table on the key columns
+// and drops the staging table. It returns the merge job.
+// The staged rows must be unique on the key columns, otherwise BigQuery fails the merge.
+// If the merge succeeded but the staging table could not be dropped, the merge job is returned along with the error.
+func (service *Service) Upsert(config *UpsertConfig) (*Job, *errortools.Error) {
+	return service.UpsertWithContext(context.Background(), config)
+}
+
+func (service *Service) UpsertWithContext(ctx context.Context, config *UpsertConfig) (job *Job, e *errortools.Error) {
+	if config == nil {
+		return nil, errortools.ErrorMessage("UpsertConfig must not be a nil pointer")
+	}
+	if len(config.KeyColumns) == 0 {
+		return nil, errortools.ErrorMessage("KeyColumns must not be empty")
+	}
+	if (config.Rows == nil) == (len(config.SourceURIs) == 0) {
+		return nil, errortools.ErrorMessage("either Rows or SourceURIs must be provided")
+	}
+
+	target, e := service.GetTableWithContext(ctx, &GetTableConfig{
+		ProjectId: config.Target.ProjectID,
+		DatasetId: config.Target.DatasetID,
+		TableId:   config.Target.TableID,
+	})
+	if e != nil {
+		return nil, e
+	}
+	if target.Schema == nil {
+		return nil, errortools.ErrorMessagef("table %s has no schema", config.Target.TableID)
+	}
+
+	stagingSchema := target.Schema
+	if config.Schema != nil {
+		stagingSchema = config.Schema
+	}
+
+	columns, e := upsertColumns(config, target.Schema, stagingSchema)
+	if e != nil {
+		return nil, e
+	}
+
+	location := config.Location
+	if location == nil && target.Location != "" {
+		location = &target.Location
+	}
+
+	staging := TableReference{
+		ProjectID: config.Target.ProjectID,
+		DatasetID: config.Target.DatasetID,
+		TableID:   NewJobId(fmt.Sprintf("_staging_%s_", config.Target.TableID)),
+	}
+	if config.StagingDataset != nil {
+		staging.ProjectID = config.StagingDataset.ProjectID
+		staging.DatasetID = config.StagingDataset.DatasetID
+	}
+
+	e = service.createStagingTable(ctx, staging, stagingSchema)
+	if e != nil {
+		return nil, e
+	}
+	defer func() {
+		deleteError := service.DeleteTableWithContext(context.Background(), &GetTableConfig{
+			ProjectId: staging.ProjectID,
+			DatasetId: staging.DatasetID,
+			TableId:   staging.TableID,
+		})
+		// if the upsert failed that error prevails, the staging table expires anyway
+		if deleteError != nil && e == nil {
+			e = errortools.ErrorMessagef("staging table %s could not be deleted: %s", staging.TableID, deleteError.Message())
+		}
+	}()
+
+	createDisposition := CreateDispositionCreateNever
+	writeDisposition := WriteDispositionWriteAppend
+
+	if config.Rows != nil {
+		_, e = service.LoadRowsWithContext(ctx, &LoadRowsConfig{
+			ProjectId:         config.ProjectId,
+			Location:          location,
+			DestinationTable:  staging,
+			Schema:            stagingSchema,
+			CreateDisposition: &createDisposition,
+			WriteDisposition:  &writeDisposition,
+			Rows:              config.Rows,
+		})
+	} else {
+		sourceFormat := SourceFormatNewlineDelimitedJSON
+		if config.SourceFormat != nil {
+			sourceFormat = *config.SourceFormat
+		}
+		_, e = service.RunJobWithContext(ctx, &InsertJobConfig{
+			ProjectId: config.ProjectId,
+			Location:  location,
+			Configuration: JobConfiguration{
+				Load: &JobConfigurationLoad{
+					SourceURIs:        config.SourceURIs,
+					Schema:            stagingSchema,
+					DestinationTable:  staging,
+					CreateDisposition: &createDisposition,
+					WriteDisposition:  &writeDisposition,
+					SourceFormat:      &sourceFormat,
+				},
+			},
+		})
+	}
+	if e != nil {
+		return nil, e
+	}
+
+	statement, e := mergeStatement(config, staging, columns)
+	if e != nil {
+		return nil, e
+	}
+
+	return service.RunJobWithContext(ctx, &InsertJobConfig{
+		ProjectId: config.ProjectId,
+		Location:  location,
+		Configuration: JobConfiguration{
+			Query: &JobConfigurationQuery{
+				Query: statement,
+			},
+		},
+	})
+}
+
+// insertStagingTableRequest is the body of the tables.insert request that creates the staging table
+type insertStagingTableRequest struct {
+	TableReference TableReference       `json:"tableReference"`
+	Schema         *TableSchema         `json:"schema"`
+	ExpirationTime go_types.Int64String `json:"expirationTime"`
+}
+
+// createStagingTable creates the staging table with an expiration, which a load job cannot set
+func (service *Service) createStagingTable(ctx context.Context, staging TableReference, schema *TableSchema) *errortools.Error {
+	body := insertStagingTableRequest{
+		TableReference: staging,
+		Schema:         schema,
+		ExpirationTime: go_types.Int64String(time.Now().Add(stagingTableExpiration).UnixMilli()),
+	}
+
+	requestConfig := go_http.RequestConfig{
+		Method:    http.MethodPost,
+		Url:       service.url(fmt.Sprintf("projects/%s/datasets/%s/tables", staging.ProjectID, staging.DatasetID)),
+		BodyModel: &body,
+	}
+	_, _, e := service.httpRequest(ctx, OperationTablesInsert, &requestConfig)
+	if e != nil {
+		return e
+	}
+
+	return nil
+}
+
+// upsertColumns returns the columns of the staging schema that the target schema has as well,
+// named as in the target schema
+func upsertColumns(config *UpsertConfig, targetSchema *TableSchema, stagingSchema *TableSchema) ([]string, *errortools.Error) {
+	targetFields := map[string]TableFieldSchema{}
+	for _, field := range targetSchema.Fields {
+		targetFields[strings.ToLower(field.Name)] = field
+	}
+
+	columns := []string{}
+	stagingColumns := map[string]bool{}
+
+	for _, field := range stagingSchema.Fields {
+		targetField, ok := targetFields[strings.ToLower(field.Name)]
+		if !ok {
+			if config.IgnoreExtraColumns {
+				continue
+			}
+			return nil, errortools.ErrorMessagef("column %s does not exist in table %s", field.Name, config.Target.TableID)
+		}
+		e := compareUpsertField(field.Name, field, targetField)
+		if e != nil {
+			return nil, errortools.ErrorMessagef("%s in table %s", e.Message(), config.Target.TableID)
+		}
+		columns = append(columns, targetField.Name)
+		stagingColumns[strings.ToLower(field.Name)] = true
+	}
+
+	for _, keyColumn := range config.KeyColumns {
+		if !stagingColumns[strings.ToLower(keyColumn)] {
+			return nil, errortools.ErrorMessagef("key column %s does not exist in both the rows and table %s", keyColumn, config.Target.TableID)
+		}
+	}
+
+	if config.UpdateColumns != nil {
+		isKey := map[string]bool{}
+		for _, keyColumn := range config.KeyColumns {
+			isKey[strings.ToLower(keyColumn)] = true
+		}
+		for _, updateColumn := range *config.UpdateColumns {
+			if !stagingColumns[strings.ToLower(updateColumn)] {
+				return nil, errortools.ErrorMessagef("update column %s does not exist in both the rows and table %s", updateColumn, config.Target.TableID)
+			}
+			if isKey[strings.ToLower(updateColumn)] {
+				return nil, errortools.ErrorMessagef("update column %s is a key column", updateColumn)
+			}
+		}
+	}
+
+	return columns, nil
+}
+
+// compareUpsertField returns an error if the staged field cannot be assigned to the target field,
+// i.e. if their types differ, one is REPEATED and the other is not, or the fields of a RECORD differ.
+// NULLABLE and REQUIRED are not compared, BigQuery checks the values when merging.
+func compareUpsertField(path string, field TableFieldSchema, targetField TableFieldSchema) *errortools.Error {
+	fieldType := normalizedFieldType(field.Type)
+	targetType := normalizedFieldType(targetField.Type)
+	if fieldType != targetType {
+		return errortools.ErrorMessagef("column %s is of type %s but of type %s", path, field.Type, targetField.Type)
+	}
+
+	repeated := strings.EqualFold(field.Mode, "REPEATED")
+	targetRepeated := strings.EqualFold(targetField.Mode, "REPEATED")
+	if repeated != targetRepeated {
+		return errortools.ErrorMessagef("column %s is of mode %s but of mode %s", path, fieldMode(field), fieldMode(targetField))
+	}
+
+	if fieldType != "STRUCT" {
+		return nil
+	}
+
+	// a STRUCT is assigned field by field in order
+	if len(field.Fields) != len(targetField.Fields) {
+		return errortools.ErrorMessagef("column %s has %v fields but %v fields", path, len(field.Fields), len(targetField.Fields))
+	}
+	for i := range field.Fields {
+		if !strings.EqualFold(field.Fields[i].Name, targetField.Fields[i].Name) {
+			return errortools.ErrorMessagef("field %v of column %s is %s but %s", i+1, path, field.Fields[i].Name, targetField.Fields[i].Name)
+		}
+		e := compareUpsertField(path+"."+field.Fields[i].Name, field.Fields[i], targetField.Fields[i])
+		if e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// fieldMode returns the mode of the field, which defaults to NULLABLE
+func fieldMode(field TableFieldSchema) string {
+	if field.Mode == "" {
+		return "NULLABLE"
+	}
+	return strings.ToUpper(field.Mode)
+}
+
+// normalizedFieldType maps legacy type names to their standard SQL equivalent
+func normalizedFieldType(fieldType string) string {
+	switch strings.ToUpper(fieldType) {
+	case "INTEGER":
+		return "INT64"
+	case "FLOAT":
+		return "FLOAT64"
+	case "BOOLEAN":
+		return "BOOL"
+	case "RECORD":
+		return "STRUCT"
+	}
+	return strings.ToUpper(fieldType)
+}
+
+func mergeStatement(config *UpsertConfig, staging TableReference, columns []string) (string, *errortools.Error) {
+	isKey := map[string]bool{}
+	on := []string{}
+	for _, keyColumn := range config.KeyColumns {
+		isKey[strings.ToLower(keyColumn)] = true
+		on = append(on, fmt.Sprintf("T.%s IS NOT DISTINCT FROM S.%s", quoteIdentifier(keyColumn), quoteIdentifier(keyColumn)))
+	}
+
+	updateColumns := []string{}
+	if config.UpdateColumns != nil {
+		updateColumns = *config.UpdateColumns
+	} else {
+		for _, column := range columns {
+			if !isKey[strings.ToLower(column)] {
+				updateColumns = append(updateColumns, column)
+			}
+		}
+	}
+
+	statement := strings.Builder{}
+	statement.WriteString(fmt.Sprintf("MERGE %s T\nUSING %s S\nON %s", quoteTableReference(config.Target), quoteTableReference(staging), strings.Join(on, " AND ")))
+
+	if config.DeleteCondition != nil {
+		statement.WriteString(fmt.Sprintf("\nWHEN MATCHED AND (%s) THEN DELETE", *config.DeleteCondition))
+	}
+
+	if !config.DisableUpdate && len(updateColumns) > 0 {
+		set := []string{}
+		for _, column := range updateColumns {
+			set = append(set, fmt.Sprintf("%s = S.%s", quoteIdentifier(column), quoteIdentifier(column)))
+		}
+		statement.WriteString(fmt.Sprintf("\nWHEN MATCHED THEN UPDATE SET %s", strings.Join(set, ", ")))
+	}
+
+	if !config.DisableInsert {
+		insert := []string{}
+		values := []string{}
+		for _, column := range columns {
+			insert = append(insert, quoteIdentifier(column))
+			values = append(values, fmt.Sprintf("S.%s", quoteIdentifier(column)))
+		}
+		statement.WriteString(fmt.Sprintf("\nWHEN NOT MATCHED BY TARGET THEN INSERT (%s) VALUES (%s)", strings.Join(insert, ", "), strings.Join(values, ", ")))
+	}
+
+	if config.DeleteNotMatched {
+		statement.WriteString("\nWHEN NOT MATCHED BY SOURCE THEN DELETE")
+	}
+
+	if !strings.Contains(statement.String(), "\nWHEN ") {
+		return "", errortools.ErrorMessage("the upsert neither updates, inserts nor deletes rows")
+	}
+
+	return statement.String(), nil
+}
+
+func quoteIdentifier(identifier string) string {
+	return fmt.Sprintf("`%s`", strings.ReplaceAll(identifier, "`", "\\`"))
+}
+
+// quoteTableReference quotes the table as project.dataset.table, leaving out a project or dataset that is not set,
+// e.g. for a table parsed from DDL that relies on the default project
+func quoteTableReference(tableReference TableReference) string {
+	parts := []string{}
+	for _, part := range []string{tableReference.ProjectID, tableReference.DatasetID, tableReference.TableID} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return fmt.Sprintf("`%s`", strings.Join(parts, "."))
+}
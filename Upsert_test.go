@@ -0,0 +1,59 @@
+package googlebigquery
+
+import "testing"
+
+func TestUpsertColumnsSchemaDrift(t *testing.T) {
+	customer := func(fields ...TableFieldSchema) TableFieldSchema {
+		return TableFieldSchema{Name: "customer", Type: "RECORD", Fields: fields}
+	}
+	target := &TableSchema{Fields: []TableFieldSchema{
+		{Name: "id", Type: "INTEGER", Mode: "REQUIRED"},
+		{Name: "tags", Type: "STRING", Mode: "REPEATED"},
+		customer(
+			TableFieldSchema{Name: "name", Type: "STRING"},
+			TableFieldSchema{Name: "address", Type: "RECORD", Fields: []TableFieldSchema{{Name: "city", Type: "STRING"}}},
+		),
+	}}
+
+	for _, test := range []struct {
+		name    string
+		staging []TableFieldSchema
+		want    string
+	}{
+		{"same schema", target.Fields, ""},
+		{"standard SQL type names", []TableFieldSchema{
+			{Name: "id", Type: "INT64"},
+			{Name: "tags", Type: "STRING", Mode: "REPEATED"},
+			customer(
+				TableFieldSchema{Name: "NAME", Type: "STRING", Mode: "REQUIRED"},
+				TableFieldSchema{Name: "address", Type: "STRUCT", Fields: []TableFieldSchema{{Name: "city", Type: "STRING"}}},
+			),
+		}, ""},
+		{"top-level type", []TableFieldSchema{{Name: "id", Type: "STRING"}}, "column id is of type STRING but of type INTEGER in table t"},
+		{"repeated", []TableFieldSchema{{Name: "id", Type: "INTEGER"}, {Name: "tags", Type: "STRING"}}, "column tags is of mode NULLABLE but of mode REPEATED in table t"},
+		{"nested type", []TableFieldSchema{{Name: "id", Type: "INTEGER"}, customer(
+			TableFieldSchema{Name: "name", Type: "STRING"},
+			TableFieldSchema{Name: "address", Type: "RECORD", Fields: []TableFieldSchema{{Name: "city", Type: "INTEGER"}}},
+		)}, "column customer.address.city is of type INTEGER but of type STRING in table t"},
+		{"nested field missing", []TableFieldSchema{{Name: "id", Type: "INTEGER"}, customer(
+			TableFieldSchema{Name: "name", Type: "STRING"},
+		)}, "column customer has 1 fields but 2 fields in table t"},
+		{"nested field order", []TableFieldSchema{{Name: "id", Type: "INTEGER"}, customer(
+			TableFieldSchema{Name: "address", Type: "RECORD", Fields: []TableFieldSchema{{Name: "city", Type: "STRING"}}},
+			TableFieldSchema{Name: "name", Type: "STRING"},
+		)}, "field 1 of column customer is address but name in table t"},
+	} {
+		config := UpsertConfig{
+			Target:     TableReference{ProjectID: "p", DatasetID: "d", TableID: "t"},
+			KeyColumns: []string{"id"},
+		}
+		_, e := upsertColumns(&config, target, &TableSchema{Fields: test.staging})
+		got := ""
+		if e != nil {
+			got = e.Message()
+		}
+		if got != test.want {
+			t.Errorf("%s: error %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package googlebigquery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+)
+
+// Watermark is the high-water mark up to which the rows of a table have been read
+type Watermark struct {
+	Value     string    `json:"value"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// WatermarkStore persists watermarks by key, GetWatermark returns nil if no watermark was stored for the key
+type WatermarkStore interface {
+	GetWatermark(ctx context.Context, key string) (*Watermark, *errortools.Error)
+	SetWatermark(ctx context.Context, key string, watermark Watermark) *errortools.Error
+}
+
+// MemoryWatermarkStore keeps watermarks in memory, e.g. for tests
+type MemoryWatermarkStore struct {
+	mutex      sync.Mutex
+	watermarks map[string]Watermark
+}
+
+func NewMemoryWatermarkStore() *MemoryWatermarkStore {
+	return &MemoryWatermarkStore{watermarks: map[string]Watermark{}}
+}
+
+func (store *MemoryWatermarkStore) GetWatermark(ctx context.Context, key string) (*Watermark, *errortools.Error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	watermark, ok := store.watermarks[key]
+	if !ok {
+		return nil, nil
+	}
+
+	return &watermark, nil
+}
+
+func (store *MemoryWatermarkStore) SetWatermark(ctx context.Context, key string, watermark Watermark) *errortools.Error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.watermarks[key] = watermark
+
+	return nil
+}
+
+// FileWatermarkStore keeps all watermarks in a single local JSON file
+type FileWatermarkStore struct {
+	mutex sync.Mutex
+	path  string
+}
+
+func NewFileWatermarkStore(path string) *FileWatermarkStore {
+	return &FileWatermarkStore{path: path}
+}
+
+func (store *FileWatermarkStore) GetWatermark(ctx context.Context, key string) (*Watermark, *errortools.Error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	watermarks, e := store.read()
+	if e != nil {
+		return nil, e
+	}
+
+	watermark, ok := watermarks[key]
+	if !ok {
+		return nil, nil
+	}
+
+	return &watermark, nil
+}
+
+func (store *FileWatermarkStore) SetWatermark(ctx context.Context, key string, watermark Watermark) *errortools.Error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	watermarks, e := store.read()
+	if e != nil {
+		return e
+	}
+
+	watermarks[key] = watermark
+
+	b, err := json.MarshalIndent(watermarks, "", "  ")
+	if err != nil {
+		return errortools.ErrorMessage(err)
+	}
+
+	// write to a temporary file first so that an interrupted write does not corrupt the store
+	tmp, err := os.CreateTemp(filepath.Dir(store.path), filepath.Base(store.path)+".*")
+	if err != nil {
+		return errortools.ErrorMessage(err)
+	}
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), store.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return errortools.ErrorMessage(err)
+	}
+
+	return nil
+}
+
+func (store *FileWatermarkStore) read() (map[string]Watermark, *errortools.Error) {
+	watermarks := map[string]Watermark{}
+
+	b, err := os.ReadFile(store.path)
+	if os.IsNotExist(err) {
+		return watermarks, nil
+	}
+	if err != nil {
+		return nil, errortools.ErrorMessage(err)
+	}
+
+	err = json.Unmarshal(b, &watermarks)
+	if err != nil {
+		return nil, errortools.ErrorMessagef("invalid watermark file %s: %s", store.path, err.Error())
+	}
+
+	return watermarks, nil
+}
+
+// TableWatermarkStore keeps watermarks in a BigQuery table, which is created if it does not exist
+type TableWatermarkStore struct {
+	service   *Service
+	projectId string
+	table     TableReference
+	mutex     sync.Mutex
+	created   bool
+}
+
+// NewTableWatermarkStore returns a store that keeps its watermarks in table,
+// queries run in projectId
+func (service *Service) NewTableWatermarkStore(projectId string, table TableReference) *TableWatermarkStore {
+	return &TableWatermarkStore{
+		service:   service,
+		projectId: projectId,
+		table:     table,
+	}
+}
+
+func (store *TableWatermarkStore) GetWatermark(ctx context.Context, key string) (*Watermark, *errortools.Error) {
+	e := store.createTable(ctx)
+	if e != nil {
+		return nil, e
+	}
+
+	job, e := store.query(ctx, fmt.Sprintf("SELECT value, UNIX_MICROS(updated_at) FROM %s WHERE key = @key", quoteTableReference(store.table)), map[string]interface{}{"key": key})
+	if e != nil {
+		return nil, e
+	}
+
+	rows, e := store.service.queryResultRows(ctx, job)
+	if e != nil {
+		return nil, e
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	row := rows[0]
+	if len(row.F) != 2 {
+		return nil, errortools.ErrorMessagef("unexpected number of columns (%v) in watermark row", len(row.F))
+	}
+
+	value, _ := row.F[0].V.(string)
+	watermark := Watermark{
+		Value: value,
+	}
+
+	updatedAt, e := int64Cell(row.F[1])
+	if e != nil {
+		return nil, e
+	}
+	if updatedAt != nil {
+		watermark.UpdatedAt = time.UnixMicro(*updatedAt).UTC()
+	}
+
+	return &watermark, nil
+}
+
+func (store *TableWatermarkStore) SetWatermark(ctx context.Context, key string, watermark Watermark) *errortools.Error {
+	e := store.createTable(ctx)
+	if e != nil {
+		return e
+	}
+
+	_, e = store.query(ctx, fmt.Sprintf(`MERGE %s T
+USING (SELECT @key AS key, @value AS value, @updated_at AS updated_at) S
+ON T.key = S.key
+WHEN MATCHED THEN UPDATE SET value = S.value, updated_at = S.updated_at
+WHEN NOT MATCHED THEN INSERT (key, value, updated_at) VALUES (S.key, S.value, S.updated_at)`, quoteTableReference(store.table)), map[string]interface{}{
+		"key":        key,
+		"value":      watermark.Value,
+		"updated_at": watermark.UpdatedAt,
+	})
+
+	return e
+}
+
+func (store *TableWatermarkStore) createTable(ctx context.Context) *errortools.Error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.created {
+		return nil
+	}
+
+	_, e := store.query(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (key STRING NOT NULL, value STRING, updated_at TIMESTAMP)", quoteTableReference(store.table)), nil)
+	if e != nil {
+		return e
+	}
+
+	store.created = true
+
+	return nil
+}
+
+func (store *TableWatermarkStore) query(ctx context.Context, statement string, parameters map[string]interface{}) (*Job, *errortools.Error) {
+	query := JobConfigurationQuery{
+		Query: statement,
+		DefaultDataset: &DatasetReference{
+			ProjectID: store.table.ProjectID,
+			DatasetID: store.table.DatasetID,
+		},
+	}
+	if len(parameters) > 0 {
+		e := query.SetNamedParameters(parameters)
+		if e != nil {
+			return nil, e
+		}
+	}
+
+	return store.service.RunJobWithContext(ctx, &InsertJobConfig{
+		ProjectId: store.projectId,
+		Configuration: JobConfiguration{
+			Query: &query,
+		},
+	})
+}
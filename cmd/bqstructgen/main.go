@@ -0,0 +1,116 @@
+// Command bqstructgen generates Go structs from the schemas of BigQuery tables.
+//
+// Usage:
+//
+//	bqstructgen [-project id] [-package name] [-out file] [project.]dataset.table ...
+//
+// The access token is read from the GOOGLE_ACCESS_TOKEN environment variable unless -token is given,
+// e.g. bqstructgen -token "$(gcloud auth print-access-token)" my_dataset.orders.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	errortools "github.com/leapforce-libraries/go_errortools"
+	google "github.com/leapforce-libraries/go_google"
+	bigquery "github.com/leapforce-libraries/go_googlebigquery"
+	tokenfixed "github.com/leapforce-libraries/go_oauth2/tokenfixed"
+)
+
+func main() {
+	token := flag.String("token", os.Getenv("GOOGLE_ACCESS_TOKEN"), "OAuth2 access token")
+	projectId := flag.String("project", "", "project of tables given as dataset.table")
+	packageName := flag.String("package", "tables", "package name of the generated file")
+	out := flag.String("out", "", "output file, defaults to stdout")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: bqstructgen [flags] [project.]dataset.table ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	e := run(*token, *projectId, *packageName, *out, flag.Args())
+	if e != nil {
+		fmt.Fprintln(os.Stderr, e.Message())
+		os.Exit(1)
+	}
+}
+
+func run(token string, projectId string, packageName string, out string, tableNames []string) *errortools.Error {
+	if token == "" {
+		return errortools.ErrorMessage("no access token, set GOOGLE_ACCESS_TOKEN or -token")
+	}
+
+	// the fixed token is never refreshed, so the client id is only a label
+	tokenSource, e := tokenfixed.NewTokenFixed(token)
+	if e != nil {
+		return e
+	}
+
+	service, e := bigquery.NewServiceWithOAuth2(&google.ServiceWithOAuth2Config{
+		ClientId:    "bqstructgen",
+		TokenSource: tokenSource,
+	})
+	if e != nil {
+		return e
+	}
+
+	tables := []bigquery.Table{}
+
+	for _, tableName := range tableNames {
+		config, e := getTableConfig(tableName, projectId)
+		if e != nil {
+			return e
+		}
+
+		table, e := service.GetTable(config)
+		if e != nil {
+			return errortools.ErrorMessagef("%s: %s", tableName, e.Message())
+		}
+		tables = append(tables, *table)
+	}
+
+	b, e := bigquery.GenerateGoStructs(packageName, tables)
+	if e != nil {
+		return e
+	}
+
+	if out == "" {
+		_, err := os.Stdout.Write(b)
+		if err != nil {
+			return errortools.ErrorMessage(err)
+		}
+		return nil
+	}
+
+	err := os.WriteFile(out, b, 0644)
+	if err != nil {
+		return errortools.ErrorMessage(err)
+	}
+
+	return nil
+}
+
+// getTableConfig parses project.dataset.table, project:dataset.table or dataset.table
+func getTableConfig(tableName string, projectId string) (*bigquery.GetTableConfig, *errortools.Error) {
+	parts := strings.Split(strings.Replace(tableName, ":", ".", 1), ".")
+
+	switch len(parts) {
+	case 2:
+		if projectId == "" {
+			return nil, errortools.ErrorMessagef("%s: -project is required for tables without project", tableName)
+		}
+		return &bigquery.GetTableConfig{ProjectId: projectId, DatasetId: parts[0], TableId: parts[1]}, nil
+	case 3:
+		return &bigquery.GetTableConfig{ProjectId: parts[0], DatasetId: parts[1], TableId: parts[2]}, nil
+	}
+
+	return nil, errortools.ErrorMessagef("invalid table name %s, expected [project.]dataset.table", tableName)
+}
@@ -3,21 +3,25 @@ module github.com/leapforce-libraries/go_googlebigquery
 go 1.20
 
 require (
+	cloud.google.com/go v0.112.0
 	cloud.google.com/go/bigquery v1.57.1
+	github.com/apache/arrow/go/v12 v12.0.0
 	github.com/leapforce-libraries/go_errortools v0.0.0-20230306211452-9ccee0cdafe8
 	github.com/leapforce-libraries/go_google v0.0.0-20240112120231-44746007e34d
 	github.com/leapforce-libraries/go_http v0.0.0-20230420114702-86cc77fcf983
+	github.com/leapforce-libraries/go_oauth2 v0.0.0-20230623131113-82064e679034
 	github.com/leapforce-libraries/go_types v0.0.0-20230425074203-34c9cae0aa4e
+	google.golang.org/grpc v1.60.1
+	google.golang.org/protobuf v1.32.0
 )
 
 require (
-	cloud.google.com/go v0.112.0 // indirect
 	cloud.google.com/go/compute v1.23.3 // indirect
 	cloud.google.com/go/compute/metadata v0.2.3 // indirect
 	cloud.google.com/go/iam v1.1.5 // indirect
 	cloud.google.com/go/storage v1.36.0 // indirect
+	github.com/JohnCGriffin/overflow v0.0.0-20211019200055-46fa312c352c // indirect
 	github.com/andybalholm/brotli v1.0.4 // indirect
-	github.com/apache/arrow/go/v12 v12.0.0 // indirect
 	github.com/apache/thrift v0.16.0 // indirect
 	github.com/felixge/httpsnoop v1.0.4 // indirect
 	github.com/getsentry/sentry-go v0.19.0 // indirect
@@ -38,7 +42,6 @@ require (
 	github.com/klauspost/cpuid/v2 v2.0.9 // indirect
 	github.com/leapforce-libraries/go_googlecloudstorage v0.0.0-20230621111300-7ee17b7a4982 // indirect
 	github.com/leapforce-libraries/go_integration v0.0.0-20221219180324-1d9ce9d12c4c // indirect
-	github.com/leapforce-libraries/go_oauth2 v0.0.0-20230623131113-82064e679034 // indirect
 	github.com/leapforce-libraries/go_utilities v0.0.0-20230320164646-a793abe241b2 // indirect
 	github.com/minio/asm2plan9s v0.0.0-20200509001527-cdd76441f9d8 // indirect
 	github.com/minio/c2goasm v0.0.0-20190812172519-36a3d3bbc4f3 // indirect
@@ -51,6 +54,7 @@ require (
 	go.opentelemetry.io/otel/metric v1.21.0 // indirect
 	go.opentelemetry.io/otel/trace v1.21.0 // indirect
 	golang.org/x/crypto v0.17.0 // indirect
+	golang.org/x/exp v0.0.0-20220827204233-334a2380cb91 // indirect
 	golang.org/x/mod v0.10.0 // indirect
 	golang.org/x/net v0.19.0 // indirect
 	golang.org/x/oauth2 v0.15.0 // indirect
@@ -65,6 +69,4 @@ require (
 	google.golang.org/genproto v0.0.0-20231212172506-995d672761c0 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20240102182953-50ed04b92917 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240102182953-50ed04b92917 // indirect
-	google.golang.org/grpc v1.60.1 // indirect
-	google.golang.org/protobuf v1.32.0 // indirect
 )
@@ -28,3 +28,10 @@ type ModelReference struct {
 	DatasetID string `json:"datasetId"`
 	ModelID   string `json:"modelId"`
 }
+
+type RowAccessPolicyReference struct {
+	ProjectID string `json:"projectId"`
+	DatasetID string `json:"datasetId"`
+	TableID   string `json:"tableId"`
+	PolicyID  string `json:"policyId"`
+}